@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"mcp-feishu/internal/audit"
+	"mcp-feishu/internal/config"
+	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// 子命令退出码
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2 // 与flag包解析失败时的退出码一致
+)
+
+// doctorMessage doctor发送的测试消息内容
+const doctorMessage = "mcp-feishu doctor 测试消息"
+
+// subcommand 命令行子命令
+type subcommand struct {
+	summary string
+	run     func(args []string) int
+}
+
+// subcommands 可用的子命令，未匹配时按服务器模式运行
+var subcommands = map[string]subcommand{
+	"send":            {summary: "发送消息，用于脚本和CI: send text \"内容\" | send card -f card.json", run: runSend},
+	"validate-config": {summary: "完整校验配置后退出，不启动服务器", run: runValidateConfig},
+	"doctor":          {summary: "检查配置、签名生成，--dry-run时在本地端点校验消息负载", run: runDoctor},
+	"mock-server":     {summary: "启动模拟飞书自定义机器人Webhook的本地服务器", run: runMockServer},
+	"audit-verify":    {summary: "校验审计日志的哈希链: audit-verify audit.jsonl", run: runAuditVerify},
+}
+
+// usage 输出服务器模式和子命令的用法
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "用法:\n  %s [参数]              以MCP服务器模式运行\n  %s <子命令> [参数]\n\n子命令:\n", os.Args[0], os.Args[0])
+
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-16s %s\n", name, subcommands[name].summary)
+	}
+
+	fmt.Fprintln(out, "\n参数:")
+	flag.PrintDefaults()
+}
+
+// newSubcommandFlags 创建子命令的参数集合，包含配置相关的公共参数
+func newSubcommandFlags(name, args string) (*flag.FlagSet, *commonFlags) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s %s [参数] %s\n\n参数:\n", os.Args[0], name, args)
+		fs.PrintDefaults()
+	}
+	return fs, registerCommonFlags(fs)
+}
+
+// runSend 发送文本或卡片消息，成功时将飞书响应输出到标准输出
+func runSend(args []string) int {
+	if len(args) == 0 || (args[0] != "text" && args[0] != "card") {
+		fmt.Fprintf(os.Stderr, "用法: %s send text [参数] \"内容\"\n      %s send card [参数] -f card.json\n", os.Args[0], os.Args[0])
+		return exitUsage
+	}
+	kind := args[0]
+
+	fs, common := newSubcommandFlags("send "+kind, "")
+	var cardFile *string
+	if kind == "card" {
+		cardFile = fs.String("f", "", "卡片JSON文件路径，- 表示从标准输入读取")
+	}
+	dryRun := fs.Bool("dry-run", false, "只输出签名后的最终请求体，不发送消息")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	setupLogging(*common.debug)
+
+	cfg, _, err := common.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return exitError
+	}
+	client := feishu.NewClient(cfg.Feishu)
+
+	builder := client.MessageBuilder()
+	var req *types.FeishuWebhookRequest
+	switch kind {
+	case "text":
+		text := strings.Join(fs.Args(), " ")
+		if text == "" {
+			// 未提供内容时从标准输入读取，便于在管道中使用
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "读取标准输入失败: %v\n", err)
+				return exitError
+			}
+			text = strings.TrimSpace(string(data))
+		}
+		if text == "" {
+			fmt.Fprintln(os.Stderr, "消息内容不能为空")
+			return exitUsage
+		}
+
+		text, err = client.ResolveTextMentions(text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "解析@提及失败: %v\n", err)
+			return exitError
+		}
+		req, err = builder.BuildTextMessage(text)
+	case "card":
+		if *cardFile == "" {
+			fmt.Fprintln(os.Stderr, "请通过 -f 指定卡片JSON文件")
+			return exitUsage
+		}
+		card, loadErr := loadCardFile(*cardFile)
+		if loadErr != nil {
+			fmt.Fprintf(os.Stderr, "读取卡片失败: %v\n", loadErr)
+			return exitError
+		}
+		elements, _ := card["elements"].([]interface{})
+		req, err = builder.BuildInteractiveMessage(card["config"], elements, card["header"])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "构建消息失败: %v\n", err)
+		return exitError
+	}
+
+	// 预览模式下输出请求体，与MCP工具的dry_run行为一致
+	if *dryRun || client.DryRun() {
+		printJSON(req)
+		return exitOK
+	}
+
+	resp, err := client.SendMessage(context.Background(), req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "发送消息失败: %v\n", err)
+		return exitError
+	}
+
+	printJSON(resp)
+	return exitOK
+}
+
+// printJSON 以缩进格式将对象输出到标准输出
+func printJSON(v interface{}) {
+	output, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(output))
+}
+
+// loadCardFile 读取卡片JSON，支持卡片对象本身或包含card字段的完整Webhook请求体
+func loadCardFile(path string) (map[string]interface{}, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var card map[string]interface{}
+	if err := json.Unmarshal(data, &card); err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
+	}
+	if inner, ok := card["card"].(map[string]interface{}); ok {
+		card = inner
+	}
+	if _, ok := card["elements"].([]interface{}); !ok {
+		return nil, fmt.Errorf("卡片缺少elements数组")
+	}
+	return card, nil
+}
+
+// runValidateConfig 加载并完整校验配置，不启动服务器
+func runValidateConfig(args []string) int {
+	fs, common := newSubcommandFlags("validate-config", "")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	setupLogging(*common.debug)
+
+	cfg, _, err := common.load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "配置无效: %v\n", err)
+		return exitError
+	}
+
+	fmt.Println("配置有效")
+	fmt.Printf("  webhook_url: %s\n", config.RedactWebhookURL(cfg.Feishu.WebhookURL))
+	fmt.Printf("  安全策略: %s\n", formatPolicies(cfg.Feishu.EnabledSecurityPolicies()))
+	if cfg.Feishu.AppID != "" {
+		fmt.Println("  应用机器人: 已配置")
+	}
+	return exitOK
+}
+
+// formatPolicies 格式化安全策略列表，未启用时返回none
+func formatPolicies(policies []types.SecurityType) string {
+	if len(policies) == 0 {
+		return string(types.SecurityTypeNone)
+	}
+	names := make([]string, len(policies))
+	for i, policy := range policies {
+		names[i] = string(policy)
+	}
+	return strings.Join(names, ",")
+}
+
+// checkStatus 检查结果
+type checkStatus string
+
+const (
+	checkPass checkStatus = "通过"
+	checkFail checkStatus = "失败"
+	checkSkip checkStatus = "跳过"
+)
+
+// checkReport 收集并输出doctor的检查结果
+type checkReport struct {
+	failed bool
+}
+
+// add 输出一项检查结果
+func (r *checkReport) add(status checkStatus, name, detail string) {
+	if status == checkFail {
+		r.failed = true
+	}
+	fmt.Printf("[%s] %s: %s\n", status, name, detail)
+}
+
+// runDoctor 依次检查配置、签名生成、关键词策略和用户目录，--dry-run时将测试消息发送到本地端点校验负载
+func runDoctor(args []string) int {
+	fs, common := newSubcommandFlags("doctor", "")
+	dryRun := fs.Bool("dry-run", false, "将测试消息发送到本地端点并校验负载，不会发送到飞书")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	setupLogging(*common.debug)
+
+	report := &checkReport{}
+
+	cfg, _, err := common.load()
+	if err != nil {
+		report.add(checkFail, "配置", err.Error())
+		return exitError
+	}
+	report.add(checkPass, "配置", fmt.Sprintf("安全策略: %s", formatPolicies(cfg.Feishu.EnabledSecurityPolicies())))
+
+	client := feishu.NewClient(cfg.Feishu)
+	security := client.GetSecurityManager()
+	builder := feishu.NewMessageBuilder(security)
+
+	// 签名生成
+	if security.HasPolicy(types.SecurityTypeSignature) {
+		req, err := builder.BuildTextMessage(doctorText(cfg.Feishu))
+		switch {
+		case err != nil:
+			report.add(checkFail, "签名生成", err.Error())
+		case req.Sign == "" || time.Since(time.Unix(req.Timestamp, 0)).Abs() > time.Minute:
+			report.add(checkFail, "签名生成", "签名或时间戳缺失")
+		default:
+			report.add(checkPass, "签名生成", fmt.Sprintf("timestamp=%d", req.Timestamp))
+		}
+	} else {
+		report.add(checkSkip, "签名生成", "未启用签名校验")
+	}
+
+	// 关键词策略：使用不含关键词的消息检查处理方式
+	if security.HasPolicy(types.SecurityTypeKeyword) {
+		req, err := builder.BuildTextMessage(doctorMessage)
+		switch {
+		case err != nil && keywordPolicy(cfg.Feishu) == types.KeywordPolicyReject:
+			report.add(checkPass, "关键词", "缺少关键词的消息会被拒绝，发送的内容需包含关键词之一")
+		case err != nil:
+			report.add(checkFail, "关键词", err.Error())
+		default:
+			text := req.Content.(*types.TextMessage).Text
+			report.add(checkPass, "关键词", fmt.Sprintf("缺少关键词时自动注入: %q", text))
+		}
+	} else {
+		report.add(checkSkip, "关键词", "未启用关键词校验")
+	}
+
+	// 用户目录加载失败时，包含@提及的消息会发送失败
+	if cfg.Feishu.MentionDirectory != "" {
+		if _, err := client.ResolveTextMentions("@all"); err != nil {
+			report.add(checkFail, "用户目录", err.Error())
+		} else {
+			report.add(checkPass, "用户目录", cfg.Feishu.MentionDirectory)
+		}
+	} else {
+		report.add(checkSkip, "用户目录", "未配置mention_directory")
+	}
+
+	if *dryRun {
+		if err := doctorDryRun(cfg.Feishu); err != nil {
+			report.add(checkFail, "消息负载", err.Error())
+		} else {
+			report.add(checkPass, "消息负载", "本地端点校验通过")
+		}
+	} else {
+		report.add(checkSkip, "消息负载", "使用 --dry-run 在本地端点校验")
+	}
+
+	if report.failed {
+		return exitError
+	}
+	return exitOK
+}
+
+// keywordPolicy 获取关键词策略，未配置时为reject
+func keywordPolicy(cfg types.FeishuConfig) types.KeywordPolicy {
+	if cfg.KeywordPolicy == "" {
+		return types.KeywordPolicyReject
+	}
+	return types.KeywordPolicy(cfg.KeywordPolicy)
+}
+
+// doctorText 生成能通过关键词校验的测试消息
+func doctorText(cfg types.FeishuConfig) string {
+	if len(cfg.Keywords) > 0 {
+		return cfg.Keywords[0] + " " + doctorMessage
+	}
+	return doctorMessage
+}
+
+// doctorDryRun 启动本地模拟Webhook，通过飞书客户端发送测试消息，由模拟服务器按飞书的规则校验请求
+func doctorDryRun(cfg types.FeishuConfig) error {
+	opts := feishutest.Options{DisableRateLimit: true}
+	for _, policy := range cfg.EnabledSecurityPolicies() {
+		switch policy {
+		case types.SecurityTypeSignature:
+			opts.Secret = cfg.Secret
+		case types.SecurityTypeKeyword:
+			opts.Keywords = cfg.Keywords
+		}
+	}
+
+	mock := feishutest.Start(opts)
+	defer mock.Close()
+
+	cfg.WebhookURL = mock.URL()
+	_, err := feishu.NewClient(cfg).SendTextMessage(context.Background(), doctorText(cfg))
+	return err
+}
+
+// runMockServer 启动模拟飞书自定义机器人Webhook的本地服务器，用于离线开发
+func runMockServer(args []string) int {
+	fs := flag.NewFlagSet("mock-server", flag.ContinueOnError)
+	var (
+		addr        = fs.String("addr", "127.0.0.1:8089", "监听地址")
+		token       = fs.String("token", "", "访问令牌，为空时接受任意令牌")
+		secret      = fs.String("secret", "", "签名密钥，设置后校验签名")
+		keywords    = fs.String("keywords", "", "自定义关键词，逗号分隔，设置后校验关键词")
+		maxBody     = fs.Int("max-body", feishutest.DefaultMaxBodyBytes, "请求体大小上限（字节）")
+		noRateLimit = fs.Bool("no-rate-limit", false, "关闭频率限制（默认每秒5次、每分钟100次）")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	setupLogging(false)
+
+	opts := feishutest.Options{
+		Token:            *token,
+		Secret:           *secret,
+		MaxBodyBytes:     *maxBody,
+		DisableRateLimit: *noRateLimit,
+		OnRequest: func(req feishutest.Request) {
+			event := log.Info()
+			if req.Code != feishutest.CodeSuccess {
+				event = log.Warn()
+			}
+			event.Str("msg_type", req.MsgType).Int("code", req.Code).Str("msg", req.Msg).Str("body", req.Body).Msg("收到Webhook请求")
+		},
+	}
+	for _, keyword := range strings.Split(*keywords, ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			opts.Keywords = append(opts.Keywords, keyword)
+		}
+	}
+
+	hookToken := *token
+	if hookToken == "" {
+		hookToken = "mock-token"
+	}
+	log.Info().
+		Str("webhook_url", "http://"+*addr+feishutest.HookPath+hookToken).
+		Str("requests", "http://"+*addr+feishutest.AdminPath).
+		Msg("模拟飞书Webhook服务器已启动")
+
+	if err := http.ListenAndServe(*addr, feishutest.NewServer(opts)); err != nil {
+		log.Error().Err(err).Msg("模拟服务器运行失败")
+		return exitError
+	}
+	return exitOK
+}
+
+// runAuditVerify 校验审计日志及其历史文件的哈希链
+func runAuditVerify(args []string) int {
+	fs := flag.NewFlagSet("audit-verify", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s audit-verify <审计日志文件>\n\n按从旧到新的顺序校验文件及其现存的历史文件(.N ... .1)\n", os.Args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	files := audit.Files(fs.Arg(0))
+	if len(files) == 0 {
+		fmt.Fprintf(os.Stderr, "审计日志不存在: %s\n", fs.Arg(0))
+		return exitError
+	}
+
+	result, err := audit.Verify(files)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "校验失败（已通过%d条记录）: %v\n", result.Entries, err)
+		return exitError
+	}
+
+	fmt.Printf("校验通过: %d个文件, %d条记录\n", len(result.Files), result.Entries)
+	if result.FirstPrev != "" {
+		fmt.Printf("  第一条记录接续更早的记录(prev_hash=%s)，更早的历史文件已被轮转删除\n", result.FirstPrev)
+	}
+	if result.LastHash != "" {
+		fmt.Printf("  最后一条记录的哈希: %s\n", result.LastHash)
+	}
+	return exitOK
+}
@@ -0,0 +1,228 @@
+// Package acl 按MCP客户端或API密钥限制可调用的工具和消息目标。
+//
+// 策略文件为每个主体列出允许的工具和目标，支持 * 通配符：
+//
+//	default:
+//	  tools: [send_text_message]
+//	  targets: [webhook]
+//	clients:
+//	  claude-ai:
+//	    tools: ["*"]
+//	    targets: ["webhook", "webhook:release-*", "direct:*@example.com"]
+//	api_keys:
+//	  deploy-bot:
+//	    tools: [send_text_message, send_post_message]
+//	    targets: [webhook]
+//
+// 认证过的主体按 API密钥名称 > default 匹配，客户端上报的名称不可信，不参与匹配；
+// 未认证的主体按 客户端名称 > default 匹配。都未匹配时拒绝全部工具。
+package acl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// 消息目标
+const (
+	TargetWebhook       = "webhook"  // 自定义机器人所在的群
+	TargetWebhookPrefix = "webhook:" // 命名群机器人目标前缀，后接目标名称，如 webhook:release-cn
+	TargetDirectPrefix  = "direct:"  // 私聊目标前缀，后接receive_id，如 direct:someone@example.com
+)
+
+// WebhookTarget 返回广播目标对应的目标标识，默认Webhook为webhook
+func WebhookTarget(name string) string {
+	if name == TargetWebhook {
+		return TargetWebhook
+	}
+	return TargetWebhookPrefix + name
+}
+
+// DirectTarget 返回私聊接收者对应的目标标识
+func DirectTarget(receiveID string) string {
+	return TargetDirectPrefix + receiveID
+}
+
+// Rule 一个主体允许的工具和目标
+type Rule struct {
+	Tools   []string `json:"tools"`
+	Targets []string `json:"targets"`
+}
+
+// AllowsTool 工具名称是否匹配任一允许的模式
+func (r *Rule) AllowsTool(name string) bool {
+	return r != nil && matchAny(r.Tools, name)
+}
+
+// AllowsTarget 目标是否匹配任一允许的模式
+func (r *Rule) AllowsTarget(target string) bool {
+	return r != nil && matchAny(r.Targets, target)
+}
+
+// matchAny 按path.Match通配符规则匹配，* 匹配任意字符序列（不含/）
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == value {
+			return true
+		}
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy 访问控制策略
+type Policy struct {
+	Default *Rule           `json:"default,omitempty"`
+	Clients map[string]Rule `json:"clients,omitempty"`
+	APIKeys map[string]Rule `json:"api_keys,omitempty"`
+}
+
+// Principal 发起调用的主体
+type Principal struct {
+	Client string // initialize请求中clientInfo.name
+	APIKey string // HTTP传输认证通过的密钥名称
+}
+
+// String 返回用于日志和错误信息的主体描述
+func (p Principal) String() string {
+	switch {
+	case p.APIKey != "":
+		return "api_key:" + p.APIKey
+	case p.Client != "":
+		return "client:" + p.Client
+	default:
+		return "未知客户端"
+	}
+}
+
+// RuleFor 返回主体适用的规则，未匹配任何规则时返回nil。
+// 认证过的主体只匹配api_keys，不使用客户端自行上报的名称，避免通过伪造clientInfo.name获得其他客户端的权限
+func (p *Policy) RuleFor(principal Principal) *Rule {
+	if principal.APIKey != "" {
+		if rule, ok := p.APIKeys[principal.APIKey]; ok {
+			return &rule
+		}
+		return p.Default
+	}
+	if principal.Client != "" {
+		if rule, ok := p.Clients[principal.Client]; ok {
+			return &rule
+		}
+	}
+	return p.Default
+}
+
+// ToolPatterns 返回策略中出现的全部工具模式，用于检查拼写错误，nil策略返回空
+func (p *Policy) ToolPatterns() []string {
+	if p == nil {
+		return nil
+	}
+	var patterns []string
+	if p.Default != nil {
+		patterns = append(patterns, p.Default.Tools...)
+	}
+	for _, rule := range p.Clients {
+		patterns = append(patterns, rule.Tools...)
+	}
+	for _, rule := range p.APIKeys {
+		patterns = append(patterns, rule.Tools...)
+	}
+	return patterns
+}
+
+// Load 读取策略文件，按扩展名解析JSON、YAML(.yaml/.yml)或TOML(.toml)，拒绝未知字段
+func Load(policyPath string) (*Policy, error) {
+	data, err := os.ReadFile(policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取访问控制策略失败: %w", err)
+	}
+
+	var raw interface{}
+	switch strings.ToLower(filepath.Ext(policyPath)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		raw = table
+	default:
+		err = json.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析访问控制策略%s失败: %w", policyPath, err)
+	}
+
+	// 各种格式统一转换为JSON后解析，共用json标签作为字段名
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("转换访问控制策略失败: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+
+	var policy Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("解析访问控制策略%s失败: %w", policyPath, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("访问控制策略%s无效: %w", policyPath, err)
+	}
+	return &policy, nil
+}
+
+// validate 检查通配符模式是否有效
+func (p *Policy) validate() error {
+	check := func(subject string, rule Rule) error {
+		for _, pattern := range append(append([]string{}, rule.Tools...), rule.Targets...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: 无效的通配符模式 %q", subject, pattern)
+			}
+		}
+		return nil
+	}
+
+	if p.Default != nil {
+		if err := check("default", *p.Default); err != nil {
+			return err
+		}
+	}
+	for name, rule := range p.Clients {
+		if err := check("clients."+name, rule); err != nil {
+			return err
+		}
+	}
+	for name, rule := range p.APIKeys {
+		if err := check("api_keys."+name, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// principalKey context中保存调用主体的key
+type principalKey struct{}
+
+// WithPrincipal 返回携带调用主体的context
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext 返回context中的调用主体
+func PrincipalFromContext(ctx context.Context) Principal {
+	if ctx == nil {
+		return Principal{}
+	}
+	principal, _ := ctx.Value(principalKey{}).(Principal)
+	return principal
+}
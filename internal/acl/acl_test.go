@@ -0,0 +1,41 @@
+package acl
+
+import "testing"
+
+func TestPolicyRuleFor(t *testing.T) {
+	defaultRule := &Rule{Tools: []string{"send_text_message"}, Targets: []string{"webhook"}}
+	policy := &Policy{
+		Default: defaultRule,
+		Clients: map[string]Rule{
+			"claude-ai": {Tools: []string{"*"}, Targets: []string{"*"}},
+		},
+		APIKeys: map[string]Rule{
+			"deploy-bot": {Tools: []string{"send_post_message"}, Targets: []string{"webhook"}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		wantTool  string
+	}{
+		{"未知客户端使用default", Principal{Client: "other"}, "send_text_message"},
+		{"客户端名称", Principal{Client: "claude-ai"}, "*"},
+		{"API密钥", Principal{APIKey: "deploy-bot", Client: "claude-ai"}, "send_post_message"},
+		// 认证过的调用不能通过上报的客户端名称获得其他客户端的权限
+		{"未配置的API密钥不使用客户端名称", Principal{APIKey: "unknown", Client: "claude-ai"}, "send_text_message"},
+		{"无身份", Principal{}, "send_text_message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := policy.RuleFor(tt.principal)
+			if rule == nil || len(rule.Tools) != 1 || rule.Tools[0] != tt.wantTool {
+				t.Errorf("RuleFor(%+v) = %+v, want tools [%s]", tt.principal, rule, tt.wantTool)
+			}
+		})
+	}
+
+	if rule := (&Policy{}).RuleFor(Principal{APIKey: "deploy-bot"}); rule != nil {
+		t.Errorf("没有default时RuleFor() = %+v, want nil", rule)
+	}
+}
@@ -0,0 +1,446 @@
+// Package audit 将每条发出的消息追加写入JSONL审计日志。
+//
+// 每条记录包含上一条记录的哈希，形成哈希链：修改、删除或插入任意一条记录都会导致
+// 之后的校验失败。日志按大小轮转，轮转后哈希链在新文件中继续。
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mcp-feishu/internal/redact"
+	"mcp-feishu/internal/types"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 默认轮转参数
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 10
+)
+
+// redacted 脱敏后的占位值
+const redacted = "[REDACTED]"
+
+// ClientInfo MCP客户端在initialize请求中上报的信息
+type ClientInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// Response 飞书返回的结果
+type Response struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message,omitempty"`
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// Entry 一条审计记录，字段顺序固定，保证同一记录的序列化结果稳定
+type Entry struct {
+	Time     string          `json:"time"`
+	Client   *ClientInfo     `json:"client,omitempty"`
+	APIKey   string          `json:"api_key,omitempty"`
+	Tool     string          `json:"tool,omitempty"`
+	Target   string          `json:"target"`
+	Receiver string          `json:"receiver,omitempty"`
+	MsgType  string          `json:"msg_type"`
+	TraceID  string          `json:"trace_id,omitempty"`
+	Payload  json.RawMessage `json:"payload"`
+	Response *Response       `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	PrevHash string          `json:"prev_hash"`
+	Hash     string          `json:"hash,omitempty"`
+}
+
+// computeHash 计算记录的哈希，覆盖除hash以外的全部字段（包括prev_hash）
+func computeHash(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Logger 追加写入审计日志并按大小轮转
+type Logger struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	lastHash   string
+}
+
+// Open 打开审计日志，maxSizeMB为单个文件的大小上限，maxBackups为保留的历史文件数，
+// 从已有日志的最后一条记录继续哈希链
+func Open(path string, maxSizeMB, maxBackups int) (*Logger, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = DefaultMaxSizeMB
+	}
+	if maxBackups < 0 {
+		maxBackups = 0
+	}
+
+	lastHash, err := lastHashOf(path)
+	if err != nil {
+		return nil, err
+	}
+	if lastHash == "" {
+		// 当前文件为空时（如刚轮转后），从最近的历史文件继续
+		if lastHash, err = lastHashOf(backupPath(path, 1)); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &Logger{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		lastHash:   lastHash,
+	}
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// openFile 以追加方式打开当前文件，权限为0600
+func (l *Logger) openFile() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取审计日志信息失败: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// Write 填充哈希链字段后追加一条记录，写入前文件超过大小上限时先轮转
+func (l *Logger) Write(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("审计日志已关闭")
+	}
+
+	entry.PrevHash = l.lastHash
+	hash, err := computeHash(entry)
+	if err != nil {
+		return fmt.Errorf("计算审计记录哈希失败: %w", err)
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("序列化审计记录失败: %w", err)
+	}
+	line = append(line, '\n')
+
+	if l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	// 审计记录需要落盘后才算完成
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("同步审计日志失败: %w", err)
+	}
+
+	l.lastHash = hash
+	return nil
+}
+
+// rotate 将当前文件依次重命名为 .1、.2 ...，超出maxBackups的历史文件被删除
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("关闭审计日志失败: %w", err)
+	}
+	l.file = nil
+
+	if l.maxBackups == 0 {
+		if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("删除审计日志失败: %w", err)
+		}
+	} else {
+		os.Remove(backupPath(l.path, l.maxBackups))
+		for i := l.maxBackups - 1; i >= 1; i-- {
+			if err := os.Rename(backupPath(l.path, i), backupPath(l.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("轮转审计日志失败: %w", err)
+			}
+		}
+		if err := os.Rename(l.path, backupPath(l.path, 1)); err != nil {
+			return fmt.Errorf("轮转审计日志失败: %w", err)
+		}
+	}
+
+	return l.openFile()
+}
+
+// Close 关闭审计日志
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// backupPath 返回第n个历史文件的路径，n越大越旧
+func backupPath(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// lastHashOf 返回文件中最后一条记录的哈希，文件不存在或为空时返回空字符串
+func lastHashOf(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("读取审计日志失败: %w", err)
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	last := lines[len(lines)-1]
+	if len(last) == 0 {
+		return "", nil
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(last, &entry); err != nil {
+		return "", fmt.Errorf("审计日志%s的最后一条记录无法解析，拒绝继续写入: %w", path, err)
+	}
+	return entry.Hash, nil
+}
+
+// RedactPayload 序列化消息请求体，签名替换为占位值，消息内容中的密钥和个人信息（手机号、邮箱、身份证号等）替换为脱敏占位符
+//
+// 无论是否启用内容过滤都会执行，审计日志保存时间长、接触的人多，不应保存原文。
+func RedactPayload(req *types.FeishuWebhookRequest) (json.RawMessage, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if _, ok := payload["sign"]; ok {
+		payload["sign"] = redacted
+	}
+	if content, ok := payload["content"]; ok {
+		payload["content"] = redactStrings(content)
+	}
+	return json.Marshal(payload)
+}
+
+// redactStrings 对内容中的每个字符串执行内置敏感信息检测，按钮回调值等不可见字段同样处理
+func redactStrings(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return redact.Text(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = redactStrings(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactStrings(item)
+		}
+	}
+	return value
+}
+
+// callerKey context中保存调用方信息的key
+type callerKey struct{}
+
+// Caller 发起发送的MCP客户端和工具，由MCP服务器放入context
+type Caller struct {
+	Client *ClientInfo
+	APIKey string // HTTP传输认证通过的身份名称
+	Tool   string
+}
+
+// WithCaller 返回携带调用方信息的context
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext 返回context中的调用方信息
+func CallerFromContext(ctx context.Context) Caller {
+	if ctx == nil {
+		return Caller{}
+	}
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// Message 一次发送的内容和结果
+type Message struct {
+	Target   string // webhook或direct
+	Receiver string // 私聊接收者，如 email:someone@example.com
+	Request  *types.FeishuWebhookRequest
+	Response *Response // 未收到飞书响应时为nil
+	Err      error
+}
+
+var (
+	globalMu sync.RWMutex
+	global   *Logger
+)
+
+// SetLogger 设置全局审计日志，传入nil关闭审计
+func SetLogger(l *Logger) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	global = l
+}
+
+// Record 将一次发送写入全局审计日志，未启用审计时为空操作；
+// 消息已经发出，写入失败只能记录错误日志
+func Record(ctx context.Context, msg Message) {
+	globalMu.RLock()
+	l := global
+	globalMu.RUnlock()
+	if l == nil {
+		return
+	}
+
+	caller := CallerFromContext(ctx)
+	entry := Entry{
+		Time:     time.Now().UTC().Format(time.RFC3339Nano),
+		Client:   caller.Client,
+		APIKey:   caller.APIKey,
+		Tool:     caller.Tool,
+		Target:   msg.Target,
+		Receiver: msg.Receiver,
+		MsgType:  msg.Request.MsgType,
+		Response: msg.Response,
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		entry.TraceID = sc.TraceID().String()
+	}
+	if msg.Err != nil {
+		entry.Error = msg.Err.Error()
+	}
+
+	payload, err := RedactPayload(msg.Request)
+	if err != nil {
+		log.Error().Err(err).Msg("序列化审计记录的消息内容失败")
+		payload = json.RawMessage("null")
+	}
+	entry.Payload = payload
+
+	if err := l.Write(entry); err != nil {
+		log.Error().Err(err).Str("target", msg.Target).Str("msg_type", entry.MsgType).Msg("写入审计日志失败")
+	}
+}
+
+// VerifyResult 哈希链校验结果
+type VerifyResult struct {
+	Files     []string
+	Entries   int
+	FirstPrev string // 第一条记录的prev_hash，非空说明更早的历史文件已被轮转删除
+	LastHash  string
+}
+
+// Files 返回审计日志及其现存的历史文件，按从旧到新排列
+func Files(path string) []string {
+	var files []string
+	for i := 1; ; i++ {
+		if _, err := os.Stat(backupPath(path, i)); err != nil {
+			break
+		}
+		files = append([]string{backupPath(path, i)}, files...)
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// Verify 按顺序校验文件中每条记录的哈希以及与上一条记录的链接，返回第一个问题的位置
+func Verify(files []string) (*VerifyResult, error) {
+	result := &VerifyResult{Files: files}
+	first := true
+	for _, path := range files {
+		if err := verifyFile(path, result, &first); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// verifyFile 校验单个文件，哈希链从result.LastHash继续
+func verifyFile(path string, result *VerifyResult, first *bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开审计日志失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// 单条记录可能包含较大的卡片内容
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%s:%d: 记录无法解析: %w", path, lineNo, err)
+		}
+
+		if *first {
+			result.FirstPrev = entry.PrevHash
+			*first = false
+		} else if entry.PrevHash != result.LastHash {
+			return fmt.Errorf("%s:%d: prev_hash与上一条记录不一致，记录可能被删除或插入", path, lineNo)
+		}
+
+		hash, err := computeHash(entry)
+		if err != nil {
+			return fmt.Errorf("%s:%d: 计算哈希失败: %w", path, lineNo, err)
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("%s:%d: 哈希不匹配，记录可能被修改", path, lineNo)
+		}
+
+		result.LastHash = entry.Hash
+		result.Entries++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取审计日志%s失败: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"mcp-feishu/internal/types"
+	"strings"
+	"testing"
+)
+
+func TestRedactPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *types.FeishuWebhookRequest
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "签名和文本中的个人信息",
+			req:     &types.FeishuWebhookRequest{MsgType: "text", Content: &types.TextMessage{Text: "请联系 13812345678"}, Timestamp: 1700000000, Sign: "abc="},
+			want:    []string{`"sign":"[REDACTED]"`, `"text":"请联系 [已脱敏]"`, `"timestamp":1700000000`},
+			notWant: []string{"13812345678", "abc="},
+		},
+		{
+			name: "卡片中的链接和按钮回调值",
+			req: &types.FeishuWebhookRequest{MsgType: "interactive", Content: map[string]interface{}{
+				"elements": []interface{}{map[string]interface{}{
+					"tag":   "button",
+					"url":   "https://open.feishu.cn/open-apis/bot/v2/hook/secret-token",
+					"value": map[string]interface{}{"email": "bob@example.com"},
+				}},
+			}},
+			want:    []string{`"tag":"button"`, `"url":"[已脱敏]"`},
+			notWant: []string{"secret-token", "bob@example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := RedactPayload(tt.req)
+			if err != nil {
+				t.Fatalf("RedactPayload() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(payload), want) {
+					t.Errorf("payload %s 缺少 %s", payload, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(payload), notWant) {
+					t.Errorf("payload %s 仍包含 %s", payload, notWant)
+				}
+			}
+		})
+	}
+}
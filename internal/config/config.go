@@ -5,155 +5,147 @@ import (
 	"fmt"
 	"mcp-feishu/internal/types"
 	"os"
-	"strconv"
 )
 
 // Config 应用配置
 type Config struct {
-	Feishu types.FeishuConfig `json:"feishu"`
-	Server ServerConfig       `json:"server"`
+	Feishu  types.FeishuConfig `json:"feishu"`
+	Server  ServerConfig       `json:"server"`
+	Tracing TracingConfig      `json:"tracing"`
+	Audit   AuditConfig        `json:"audit"`
+	Quota   QuotaConfig        `json:"quota"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
+	// 传输方式: stdio（默认）或 http，http在Host:Port上监听
+	Transport string `json:"transport,omitempty"`
+
 	Port int    `json:"port"`
 	Host string `json:"host"`
-}
-
-// LoadConfig 加载配置文件
-func LoadConfig(configPath string) (*Config, error) {
-	if configPath == "" {
-		configPath = "config.json"
-	}
 
-	data, err := os.ReadFile(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("读取配置文件失败: %w", err)
-	}
+	// HTTP传输接受的API密钥，客户端通过 Authorization: Bearer <key> 认证
+	APIKeys []APIKey `json:"api_keys,omitempty"`
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("解析配置文件失败: %w", err)
-	}
+	// HTTP传输的TLS配置
+	TLS ServerTLSConfig `json:"tls"`
 
-	// 验证配置
-	if err := validateConfig(&config); err != nil {
-		return nil, fmt.Errorf("配置验证失败: %w", err)
-	}
+	// Prometheus指标端点监听地址，如 127.0.0.1:9464，为空时不启用
+	MetricsAddr string `json:"metrics_addr,omitempty"`
 
-	return &config, nil
+	// 访问控制策略文件路径（JSON/YAML/TOML），为空时不限制客户端可调用的工具和目标
+	PolicyFile string `json:"policy_file,omitempty"`
 }
 
-// LoadFromEnv 从环境变量加载配置
-func LoadFromEnv() *Config {
-	config := &Config{
-		Feishu: types.FeishuConfig{
-			WebhookURL:   os.Getenv("FEISHU_WEBHOOK_URL"),
-			Secret:       os.Getenv("FEISHU_SECRET"),
-			SecurityType: getEnvOrDefault("FEISHU_SECURITY_TYPE", "none"),
-		},
-		Server: ServerConfig{
-			Port: getEnvAsIntOrDefault("SERVER_PORT", 3000),
-			Host: getEnvOrDefault("SERVER_HOST", "localhost"),
-		},
-	}
+// APIKey 一个命名的API密钥，名称出现在日志、审计日志和访问控制策略中
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
 
-	// 处理关键词
-	keywords := os.Getenv("FEISHU_KEYWORDS")
-	if keywords != "" {
-		var keywordList []string
-		if err := json.Unmarshal([]byte(keywords), &keywordList); err == nil {
-			config.Feishu.Keywords = keywordList
-		}
-	}
+// ServerTLSConfig HTTP传输的TLS配置
+type ServerTLSConfig struct {
+	// 服务器证书和私钥（PEM），都为空时使用明文HTTP
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
 
-	return config
+	// 校验客户端证书的CA（PEM），配置后要求客户端出示证书(mTLS)
+	ClientCAFile string `json:"client_ca_file,omitempty"`
 }
 
-// MergeConfig 合并配置，env配置优先，file配置作为fallback
-func MergeConfig(envConfig, fileConfig *Config) *Config {
-	merged := &Config{}
+// TracingConfig 链路追踪配置
+type TracingConfig struct {
+	// 导出方式: otlp 发送到OTLP/HTTP接收端，file 写入本地文件，为空时不启用
+	Exporter string `json:"exporter,omitempty"`
 
-	// 合并飞书配置
-	merged.Feishu.WebhookURL = envConfig.Feishu.WebhookURL
-	if merged.Feishu.WebhookURL == "" {
-		merged.Feishu.WebhookURL = fileConfig.Feishu.WebhookURL
-	}
+	// OTLP/HTTP接收端地址，如 http://localhost:4318
+	Endpoint string `json:"endpoint,omitempty"`
 
-	merged.Feishu.Secret = envConfig.Feishu.Secret
-	if merged.Feishu.Secret == "" {
-		merged.Feishu.Secret = fileConfig.Feishu.Secret
-	}
+	// file导出方式写入的文件路径，每个span一个JSON对象
+	File string `json:"file,omitempty"`
 
-	merged.Feishu.SecurityType = envConfig.Feishu.SecurityType
-	if merged.Feishu.SecurityType == "none" && fileConfig.Feishu.SecurityType != "" {
-		merged.Feishu.SecurityType = fileConfig.Feishu.SecurityType
-	}
+	// 上报的服务名称，默认mcp-feishu
+	ServiceName string `json:"service_name,omitempty"`
 
-	// 关键词优先使用环境变量，否则使用文件配置
-	if len(envConfig.Feishu.Keywords) > 0 {
-		merged.Feishu.Keywords = envConfig.Feishu.Keywords
-	} else {
-		merged.Feishu.Keywords = fileConfig.Feishu.Keywords
-	}
+	// 新trace的采样比例（0到1），默认1即全部采样；接入调用方trace时沿用调用方的采样决定
+	SampleRatio float64 `json:"sample_ratio"`
+}
 
-	// 合并服务器配置
-	merged.Server.Port = envConfig.Server.Port
-	if merged.Server.Port == 3000 && fileConfig.Server.Port != 0 {
-		merged.Server.Port = fileConfig.Server.Port
-	}
+// AuditConfig 审计日志配置
+type AuditConfig struct {
+	// 审计日志文件路径，为空时不启用
+	File string `json:"file,omitempty"`
 
-	merged.Server.Host = envConfig.Server.Host
-	if merged.Server.Host == "localhost" && fileConfig.Server.Host != "" {
-		merged.Server.Host = fileConfig.Server.Host
-	}
+	// 单个文件的大小上限（MB），超过后轮转
+	MaxSizeMB int `json:"max_size_mb"`
 
-	return merged
+	// 保留的历史文件数量，0表示轮转时直接删除旧文件
+	MaxBackups int `json:"max_backups"`
 }
 
-// validateConfig 验证配置
-func validateConfig(config *Config) error {
-	if config.Feishu.WebhookURL == "" {
-		return fmt.Errorf("飞书Webhook URL不能为空")
-	}
+// QuotaConfig 发送配额和熔断配置，配额按调用主体（客户端名称或API密钥名称）和目标分别计数，0表示不限制
+type QuotaConfig struct {
+	PerMinute int `json:"per_minute"`
+	PerHour   int `json:"per_hour"`
+	PerDay    int `json:"per_day"`
 
-	switch types.SecurityType(config.Feishu.SecurityType) {
-	case types.SecurityTypeSignature:
-		if config.Feishu.Secret == "" {
-			return fmt.Errorf("签名校验模式下密钥不能为空")
-		}
-	case types.SecurityTypeKeyword:
-		if len(config.Feishu.Keywords) == 0 {
-			return fmt.Errorf("关键词模式下关键词列表不能为空")
-		}
-	case types.SecurityTypeNone:
-		// 无安全设置，不需要验证
-	default:
-		return fmt.Errorf("不支持的安全类型: %s", config.Feishu.SecurityType)
-	}
+	// 目标连续发送失败多少次后暂停发送，0表示不启用熔断
+	BreakerThreshold int `json:"breaker_threshold"`
 
-	return nil
+	// 熔断后暂停的秒数
+	BreakerCooldownSeconds int `json:"breaker_cooldown_seconds"`
 }
 
-// getEnvOrDefault 获取环境变量或默认值
-func getEnvOrDefault(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// 传输方式
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
+// 链路追踪导出方式
+const (
+	TraceExporterOTLP = "otlp"
+	TraceExporterFile = "file"
+)
+
+// Defaults 返回默认配置
+func Defaults() *Config {
+	return &Config{
+		Feishu: types.FeishuConfig{
+			SecurityType: string(types.SecurityTypeNone),
+		},
+		Server: ServerConfig{
+			Port: 3000,
+			Host: "localhost",
+		},
+		Tracing: TracingConfig{
+			ServiceName: "mcp-feishu",
+			SampleRatio: 1,
+		},
+		Audit: AuditConfig{
+			MaxSizeMB:  100,
+			MaxBackups: 10,
+		},
+		Quota: QuotaConfig{
+			BreakerCooldownSeconds: 60,
+		},
 	}
-	return defaultValue
 }
 
-// getEnvAsIntOrDefault 获取环境变量并转换为整数，失败时返回默认值
-func getEnvAsIntOrDefault(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
+// LoadConfig 仅从配置文件加载配置（不读取环境变量），支持JSON、YAML(.yaml/.yml)和TOML(.toml)格式
+func LoadConfig(configPath string) (*Config, error) {
+	if configPath == "" {
+		configPath = "config.json"
 	}
-	return defaultValue
+
+	config, _, err := Load(LoadOptions{ConfigPath: configPath, IgnoreEnv: true})
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-// SaveConfig 保存配置到文件
+// SaveConfig 保存配置到文件，文件可能包含密钥，权限为0600
 func SaveConfig(config *Config, configPath string) error {
 	if configPath == "" {
 		configPath = "config.json"
@@ -164,9 +156,14 @@ func SaveConfig(config *Config, configPath string) error {
 		return fmt.Errorf("序列化配置失败: %w", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
 		return fmt.Errorf("保存配置文件失败: %w", err)
 	}
 
+	// WriteFile不会修改已存在文件的权限
+	if err := os.Chmod(configPath, 0600); err != nil {
+		return fmt.Errorf("设置配置文件权限失败: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format 配置文件格式
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// schemaKey 配置文件中用于声明JSON Schema的字段，解析时忽略
+const schemaKey = "$schema"
+
+// DetectFormat 根据文件扩展名判断配置格式，无法识别时按JSON处理
+func DetectFormat(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// decodedFile 解析后的配置文件
+type decodedFile struct {
+	config  *Config
+	present []string // 文件中出现的已知字段路径
+	unknown []string // 文件中出现的未知字段路径
+}
+
+// decodeConfig 解析配置文件内容，同时记录文件中出现的字段
+//
+// 各种格式先解析为通用结构，检查未知字段后再通过JSON转换到Config，
+// 这样所有格式共用同一套json标签作为字段名。
+func decodeConfig(data []byte, format Format) (*decodedFile, error) {
+	var raw map[string]interface{}
+
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("解析YAML失败: %w", err)
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("解析TOML失败: %w", err)
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("解析JSON失败: %w", err)
+		}
+	}
+
+	delete(raw, schemaKey)
+
+	decoded := &decodedFile{}
+	classifyFields(raw, reflect.TypeOf(Config{}), "", decoded)
+	sort.Strings(decoded.present)
+	sort.Strings(decoded.unknown)
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("转换配置失败: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(normalized, &config); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			verr := &ValidationError{}
+			verr.Add(typeErr.Field, fmt.Sprintf("类型错误，期望%s，实际为%s", typeErr.Type, typeErr.Value))
+			return nil, verr
+		}
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+	decoded.config = &config
+
+	return decoded, nil
+}
+
+// classifyFields 递归对比结构体字段，记录出现的已知字段和未知字段路径
+func classifyFields(raw map[string]interface{}, t reflect.Type, prefix string, decoded *decodedFile) {
+	known := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonFieldName(field)
+		if name == "" {
+			continue
+		}
+		known[name] = field.Type
+	}
+
+	for key, value := range raw {
+		path := joinPath(prefix, key)
+
+		fieldType, ok := known[key]
+		if !ok {
+			decoded.unknown = append(decoded.unknown, path)
+			continue
+		}
+
+		if nested, ok := value.(map[string]interface{}); ok && fieldType.Kind() == reflect.Struct {
+			classifyFields(nested, fieldType, path, decoded)
+			continue
+		}
+		decoded.present = append(decoded.present, path)
+	}
+}
+
+// jsonFieldName 获取字段的json名称，忽略的字段返回空字符串
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// joinPath 拼接字段路径
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
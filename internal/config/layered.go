@@ -0,0 +1,341 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Layer 配置来源层级，后面的层级覆盖前面的层级
+type Layer string
+
+const (
+	LayerDefault Layer = "default"
+	LayerFile    Layer = "file"
+	LayerEnv     Layer = "env"
+	LayerFlag    Layer = "flag"
+)
+
+// Source 字段的配置来源
+type Source struct {
+	Layer  Layer
+	Detail string // 文件路径、环境变量名或命令行参数名
+}
+
+// String 返回来源描述，如 env (FEISHU_SECRET)
+func (s Source) String() string {
+	if s.Detail == "" {
+		return string(s.Layer)
+	}
+	return fmt.Sprintf("%s (%s)", s.Layer, s.Detail)
+}
+
+// Provenance 记录每个字段路径的配置来源
+type Provenance map[string]Source
+
+// envVars 字段路径对应的环境变量
+var envVars = map[string]string{
+	"feishu.webhook_url":                "FEISHU_WEBHOOK_URL",
+	"feishu.secret":                     "FEISHU_SECRET",
+	"feishu.secret_file":                "FEISHU_SECRET_FILE",
+	"feishu.secret_command":             "FEISHU_SECRET_COMMAND",
+	"feishu.keywords":                   "FEISHU_KEYWORDS",
+	"feishu.security_type":              "FEISHU_SECURITY_TYPE",
+	"feishu.security_policies":          "FEISHU_SECURITY_POLICIES",
+	"feishu.keyword_policy":             "FEISHU_KEYWORD_POLICY",
+	"feishu.app_id":                     "FEISHU_APP_ID",
+	"feishu.app_secret":                 "FEISHU_APP_SECRET",
+	"feishu.api_base_url":               "FEISHU_API_BASE_URL",
+	"feishu.mention_directory":          "FEISHU_MENTION_DIRECTORY",
+	"feishu.dry_run":                    "FEISHU_DRY_RUN",
+	"feishu.content_filter.credentials": "FEISHU_CONTENT_FILTER_CREDENTIALS",
+	"feishu.content_filter.id_card":     "FEISHU_CONTENT_FILTER_ID_CARD",
+	"feishu.content_filter.phone":       "FEISHU_CONTENT_FILTER_PHONE",
+	"feishu.content_filter.email":       "FEISHU_CONTENT_FILTER_EMAIL",
+	"feishu.digest.window_seconds":      "FEISHU_DIGEST_WINDOW_SECONDS",
+	"feishu.digest.format":              "FEISHU_DIGEST_FORMAT",
+	"feishu.digest.max_messages":        "FEISHU_DIGEST_MAX_MESSAGES",
+	"feishu.broadcast_concurrency":      "FEISHU_BROADCAST_CONCURRENCY",
+	"server.transport":                  "SERVER_TRANSPORT",
+	"server.port":                       "SERVER_PORT",
+	"server.host":                       "SERVER_HOST",
+	"server.metrics_addr":               "SERVER_METRICS_ADDR",
+	"server.policy_file":                "SERVER_POLICY_FILE",
+	"server.tls.cert_file":              "SERVER_TLS_CERT_FILE",
+	"server.tls.key_file":               "SERVER_TLS_KEY_FILE",
+	"server.tls.client_ca_file":         "SERVER_TLS_CLIENT_CA_FILE",
+	"tracing.exporter":                  "TRACING_EXPORTER",
+	"tracing.endpoint":                  "TRACING_ENDPOINT",
+	"tracing.file":                      "TRACING_FILE",
+	"tracing.service_name":              "TRACING_SERVICE_NAME",
+	"tracing.sample_ratio":              "TRACING_SAMPLE_RATIO",
+	"audit.file":                        "AUDIT_FILE",
+	"audit.max_size_mb":                 "AUDIT_MAX_SIZE_MB",
+	"audit.max_backups":                 "AUDIT_MAX_BACKUPS",
+	"quota.per_minute":                  "QUOTA_PER_MINUTE",
+	"quota.per_hour":                    "QUOTA_PER_HOUR",
+	"quota.per_day":                     "QUOTA_PER_DAY",
+	"quota.breaker_threshold":           "QUOTA_BREAKER_THRESHOLD",
+	"quota.breaker_cooldown_seconds":    "QUOTA_BREAKER_COOLDOWN_SECONDS",
+}
+
+// fieldGroups 互相替代的字段组：高层级设置了组内任一字段时，低层级设置的组内字段全部失效。
+// 例如环境变量设置了FEISHU_SECRET_FILE时，配置文件中的secret不应再生效。
+var fieldGroups = [][]string{
+	{"feishu.secret", "feishu.secret_file", "feishu.secret_command"},
+	{"feishu.security_type", "feishu.security_policies"},
+}
+
+// LoadOptions 配置加载选项
+type LoadOptions struct {
+	ConfigPath string            // 配置文件路径，为空时不使用配置文件
+	IgnoreEnv  bool              // 忽略环境变量层
+	Flags      map[string]string // 命令行参数显式设置的字段，key为字段路径
+	FlagNames  map[string]string // 字段路径对应的命令行参数名，用于来源描述
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的优先级加载配置并验证
+//
+// 每一层只覆盖该层显式设置的字段，因此可以通过环境变量把文件中的值改回默认值。
+func Load(opts LoadOptions) (*Config, Provenance, error) {
+	cfg := Defaults()
+	provenance := make(Provenance)
+	for _, path := range leafPaths(reflect.TypeOf(Config{}), "") {
+		provenance[path] = Source{Layer: LayerDefault}
+	}
+
+	verr := &ValidationError{}
+
+	if opts.ConfigPath != "" {
+		decoded, err := readConfigFile(opts.ConfigPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, path := range decoded.unknown {
+			verr.Add(path, "未知字段，请检查拼写")
+		}
+		applyLayer(cfg, decoded.config, decoded.present, provenance, func(string) Source {
+			return Source{Layer: LayerFile, Detail: opts.ConfigPath}
+		})
+	}
+
+	if !opts.IgnoreEnv {
+		values := make(map[string]string)
+		for path, env := range envVars {
+			if value := os.Getenv(env); value != "" {
+				values[path] = value
+			}
+		}
+		if err := applyStringLayer(cfg, values, provenance, func(path string) Source {
+			return Source{Layer: LayerEnv, Detail: envVars[path]}
+		}); err != nil {
+			return nil, nil, fmt.Errorf("解析环境变量失败: %w", err)
+		}
+	}
+
+	if len(opts.Flags) > 0 {
+		if err := applyStringLayer(cfg, opts.Flags, provenance, func(path string) Source {
+			return Source{Layer: LayerFlag, Detail: "-" + opts.FlagNames[path]}
+		}); err != nil {
+			return nil, nil, fmt.Errorf("解析命令行参数失败: %w", err)
+		}
+	}
+
+	if err := resolveSecret(cfg); err != nil {
+		return nil, nil, fmt.Errorf("加载密钥失败: %w", err)
+	}
+
+	collectValidationErrors(cfg, verr)
+	if err := verr.errOrNil(); err != nil {
+		return nil, nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	return cfg, provenance, nil
+}
+
+// readConfigFile 读取并解析配置文件，完成环境变量插值
+func readConfigFile(configPath string) (*decodedFile, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	// 根据扩展名选择JSON、YAML或TOML格式
+	decoded, err := decodeConfig(data, DetectFormat(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件%s失败: %w", configPath, err)
+	}
+
+	// 支持 ${ENV} 引用环境变量，避免在配置文件中明文保存密钥
+	if err := interpolateConfig(decoded.config); err != nil {
+		return nil, fmt.Errorf("配置文件环境变量插值失败: %w", err)
+	}
+
+	return decoded, nil
+}
+
+// applyLayer 将layer中显式设置的字段复制到cfg，并记录来源
+func applyLayer(cfg, layer *Config, paths []string, provenance Provenance, source func(string) Source) {
+	clearOverriddenGroups(cfg, paths, provenance)
+
+	dst := reflect.ValueOf(cfg).Elem()
+	src := reflect.ValueOf(layer).Elem()
+	for _, path := range paths {
+		fieldByPath(dst, path).Set(fieldByPath(src, path))
+		provenance[path] = source(path)
+	}
+}
+
+// applyStringLayer 将字符串形式的值（环境变量、命令行参数）写入cfg，并记录来源
+func applyStringLayer(cfg *Config, values map[string]string, provenance Provenance, source func(string) Source) error {
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+	clearOverriddenGroups(cfg, paths, provenance)
+
+	dst := reflect.ValueOf(cfg).Elem()
+	for _, path := range paths {
+		field := fieldByPath(dst, path)
+		if !field.IsValid() {
+			return fmt.Errorf("未知配置字段: %s", path)
+		}
+		if err := setFromString(field, values[path]); err != nil {
+			return fmt.Errorf("%s: %w", source(path).Detail, err)
+		}
+		provenance[path] = source(path)
+	}
+	return nil
+}
+
+// clearOverriddenGroups 当前层设置了字段组中的字段时，清空组内其他字段的旧值
+func clearOverriddenGroups(cfg *Config, paths []string, provenance Provenance) {
+	set := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		set[path] = true
+	}
+
+	dst := reflect.ValueOf(cfg).Elem()
+	for _, group := range fieldGroups {
+		touched := false
+		for _, path := range group {
+			touched = touched || set[path]
+		}
+		if !touched {
+			continue
+		}
+		for _, path := range group {
+			field := fieldByPath(dst, path)
+			field.Set(reflect.Zero(field.Type()))
+			provenance[path] = Source{Layer: LayerDefault}
+		}
+	}
+}
+
+// setFromString 按字段类型解析字符串值
+func setFromString(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("无效的整数: %s", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("无效的数字: %s", value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("无效的布尔值: %s", value)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的字段类型: %s", field.Type())
+		}
+		// 支持JSON数组或逗号分隔的列表
+		var list []string
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			if err := json.Unmarshal([]byte(value), &list); err != nil {
+				return fmt.Errorf("无效的JSON数组: %w", err)
+			}
+		} else {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("不支持的字段类型: %s", field.Type())
+	}
+	return nil
+}
+
+// fieldByPath 按json字段路径查找结构体字段，找不到时返回无效值
+func fieldByPath(v reflect.Value, path string) reflect.Value {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}
+		}
+		found := reflect.Value{}
+		for i := 0; i < v.NumField(); i++ {
+			if jsonFieldName(v.Type().Field(i)) == name {
+				found = v.Field(i)
+				break
+			}
+		}
+		if !found.IsValid() {
+			return found
+		}
+		v = found
+	}
+	return v
+}
+
+// leafPaths 按结构体定义顺序列出所有叶子字段路径
+func leafPaths(t reflect.Type, prefix string) []string {
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := jsonFieldName(field)
+		if name == "" {
+			continue
+		}
+		path := joinPath(prefix, name)
+		if field.Type.Kind() == reflect.Struct {
+			paths = append(paths, leafPaths(field.Type, path)...)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// WriteEffectiveConfig 输出生效的配置及每个字段的来源，密钥会被脱敏
+func WriteEffectiveConfig(w io.Writer, cfg *Config, provenance Provenance) error {
+	redacted := reflect.ValueOf(cfg.Redacted()).Elem()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "字段\t值\t来源")
+	for _, path := range leafPaths(reflect.TypeOf(Config{}), "") {
+		value, err := json.Marshal(fieldByPath(redacted, path).Interface())
+		if err != nil {
+			return fmt.Errorf("序列化字段%s失败: %w", path, err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", path, value, provenance[path])
+	}
+	return tw.Flush()
+}
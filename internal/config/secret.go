@@ -0,0 +1,258 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+)
+
+// secretCommandTimeout 执行secret_command的超时时间
+const secretCommandTimeout = 10 * time.Second
+
+// secretCommandStderrLimit 调试日志中记录的secret_command标准错误的最大字节数
+const secretCommandStderrLimit = 512
+
+// redactedValue 脱敏后的占位符
+const redactedValue = "****"
+
+// envRefPattern 匹配 ${VAR} 形式的环境变量引用
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolateEnv 将字符串中的 ${VAR} 替换为环境变量的值，引用未定义的变量时返回错误
+func interpolateEnv(value string) (string, error) {
+	var missing []string
+	result := envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return envValue
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("引用了未定义的环境变量: %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// interpolateConfig 对配置文件中的字符串字段进行环境变量插值
+func interpolateConfig(config *Config) error {
+	fields := []*string{
+		&config.Feishu.WebhookURL,
+		&config.Feishu.Secret,
+		&config.Feishu.SecretFile,
+		&config.Feishu.SecretCommand,
+		&config.Feishu.AppID,
+		&config.Feishu.AppSecret,
+		&config.Feishu.APIBaseURL,
+		&config.Feishu.MentionDirectory,
+		&config.Server.Host,
+		&config.Server.MetricsAddr,
+		&config.Server.PolicyFile,
+		&config.Server.TLS.CertFile,
+		&config.Server.TLS.KeyFile,
+		&config.Server.TLS.ClientCAFile,
+		&config.Tracing.Endpoint,
+		&config.Tracing.File,
+		&config.Tracing.ServiceName,
+		&config.Audit.File,
+	}
+	for i := range config.Feishu.Keywords {
+		fields = append(fields, &config.Feishu.Keywords[i])
+	}
+	for i := range config.Server.APIKeys {
+		fields = append(fields, &config.Server.APIKeys[i].Key)
+	}
+	for i := range config.Feishu.Targets {
+		target := &config.Feishu.Targets[i]
+		fields = append(fields, &target.WebhookURL, &target.Secret)
+		for j := range target.Keywords {
+			fields = append(fields, &target.Keywords[j])
+		}
+	}
+
+	for _, field := range fields {
+		value, err := interpolateEnv(*field)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+
+	return nil
+}
+
+// resolveSecret 按 secret > secret_file > secret_command 的顺序确定签名密钥
+func resolveSecret(config *Config) error {
+	if config.Feishu.Secret != "" {
+		return nil
+	}
+
+	switch {
+	case config.Feishu.SecretFile != "":
+		data, err := os.ReadFile(config.Feishu.SecretFile)
+		if err != nil {
+			return fmt.Errorf("读取密钥文件失败: %w", err)
+		}
+		config.Feishu.Secret = strings.TrimSpace(string(data))
+	case config.Feishu.SecretCommand != "":
+		secret, err := runSecretCommand(config.Feishu.SecretCommand)
+		if err != nil {
+			return err
+		}
+		config.Feishu.Secret = secret
+	}
+
+	return nil
+}
+
+// runSecretCommand 执行外部命令（如vault CLI）获取密钥，使用标准输出的内容
+func runSecretCommand(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	// 错误信息中不包含命令的标准输出和标准错误，避免泄露密钥；
+	// 标准错误截断后只写入调试日志，用于排查命令失败的原因
+	if err := cmd.Run(); err != nil {
+		log.Debug().
+			Str("command", RedactSecret(command)).
+			Str("stderr", truncateOutput(stderr.String(), secretCommandStderrLimit)).
+			Msg("secret_command执行失败")
+		return "", fmt.Errorf("执行secret_command失败: %w", err)
+	}
+
+	secret := strings.TrimSpace(stdout.String())
+	if secret == "" {
+		return "", fmt.Errorf("secret_command输出为空")
+	}
+	return secret, nil
+}
+
+// truncateOutput 截断命令输出，按UTF-8字符边界截断并标注原始长度
+func truncateOutput(output string, limit int) string {
+	output = strings.TrimSpace(output)
+	if len(output) <= limit {
+		return output
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s...(共%d字节)", output[:cut], len(output))
+}
+
+// LoadEnvFile 从.env文件加载环境变量，已存在的环境变量不会被覆盖
+//
+// 支持 KEY=VALUE、export KEY=VALUE、# 注释以及单双引号包裹的值。
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取env文件失败: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("env文件第%d行格式无效，应为KEY=VALUE", lineNum)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("设置环境变量%s失败: %w", key, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取env文件失败: %w", err)
+	}
+	return nil
+}
+
+// RedactSecret 脱敏密钥，空值保持为空以便区分是否已配置
+func RedactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// RedactWebhookURL 脱敏Webhook URL中的token部分，只保留前4个字符
+func RedactWebhookURL(webhookURL string) string {
+	if webhookURL == "" {
+		return ""
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Path == "" {
+		return redactedValue
+	}
+
+	idx := strings.LastIndex(u.Path, "/")
+	token := u.Path[idx+1:]
+	if len(token) > 4 {
+		token = token[:4]
+	}
+	// 手动拼接，避免url.String对占位符进行转义
+	return u.Scheme + "://" + u.Host + u.Path[:idx+1] + token + redactedValue
+}
+
+// Redacted 返回脱敏后的配置副本，用于日志输出
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Feishu.Keywords = append([]string(nil), c.Feishu.Keywords...)
+	redacted.Feishu.WebhookURL = RedactWebhookURL(c.Feishu.WebhookURL)
+	redacted.Feishu.Secret = RedactSecret(c.Feishu.Secret)
+	redacted.Feishu.AppSecret = RedactSecret(c.Feishu.AppSecret)
+	redacted.Feishu.SecretCommand = RedactSecret(c.Feishu.SecretCommand) // 命令参数中可能包含访问令牌
+	redacted.Feishu.Targets = nil
+	for _, target := range c.Feishu.Targets {
+		target.WebhookURL = RedactWebhookURL(target.WebhookURL)
+		target.Secret = RedactSecret(target.Secret)
+		redacted.Feishu.Targets = append(redacted.Feishu.Targets, target)
+	}
+	redacted.Server.APIKeys = nil
+	for _, key := range c.Server.APIKeys {
+		redacted.Server.APIKeys = append(redacted.Server.APIKeys, APIKey{Name: key.Name, Key: RedactSecret(key.Key)})
+	}
+	return &redacted
+}
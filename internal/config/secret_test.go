@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestRunSecretCommandLogsStderr(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf).Level(zerolog.DebugLevel)
+	defer func() { log.Logger = original }()
+
+	command := "echo leaked-stdout; echo 'permission denied: token expired' >&2; head -c 2000 /dev/zero | tr '\\0' x >&2; exit 1"
+	_, err := runSecretCommand(command)
+	if err == nil {
+		t.Fatal("runSecretCommand() error = nil")
+	}
+	if strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("错误信息包含标准错误: %v", err)
+	}
+
+	logged := buf.String()
+	if !strings.Contains(logged, "permission denied: token expired") {
+		t.Errorf("调试日志缺少标准错误: %s", logged)
+	}
+	if strings.Contains(logged, "leaked-stdout") || strings.Contains(logged, "token expired' >&2") {
+		t.Errorf("调试日志包含标准输出或原始命令: %s", logged)
+	}
+	if strings.Contains(logged, strings.Repeat("x", secretCommandStderrLimit)) {
+		t.Errorf("调试日志中的标准错误没有截断: %d字节", len(logged))
+	}
+}
+
+func TestRunSecretCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		wantErr string
+	}{
+		{"使用标准输出", "echo '  s3cret  '", "s3cret", ""},
+		{"输出为空", "true", "", "secret_command输出为空"},
+		{"失败时不包含输出", "echo leaked-stdout; echo leaked-stderr >&2; exit 3", "", "exit status 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runSecretCommand(tt.command)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runSecretCommand() error = %v, want %q", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "leaked") {
+					t.Errorf("错误信息包含命令输出: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runSecretCommand() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("runSecretCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.Feishu.WebhookURL = "https://open.feishu.cn/open-apis/bot/v2/hook/abcdef123456"
+	cfg.Feishu.Secret = "s3cret"
+	cfg.Feishu.SecretCommand = "vault kv get -field=secret -token=hvs.leaked feishu/bot"
+	cfg.Feishu.AppSecret = "app-s3cret"
+	cfg.Server.APIKeys = []APIKey{{Name: "ci", Key: "key-s3cret"}}
+
+	redacted := cfg.Redacted()
+	for _, value := range []string{
+		redacted.Feishu.WebhookURL,
+		redacted.Feishu.Secret,
+		redacted.Feishu.SecretCommand,
+		redacted.Feishu.AppSecret,
+		redacted.Server.APIKeys[0].Key,
+	} {
+		if strings.Contains(value, "s3cret") || strings.Contains(value, "leaked") || strings.Contains(value, "123456") {
+			t.Errorf("脱敏后的配置仍包含敏感内容: %q", value)
+		}
+	}
+	if cfg.Feishu.SecretCommand == redacted.Feishu.SecretCommand {
+		t.Error("Redacted() 修改了原配置或未脱敏secret_command")
+	}
+}
@@ -0,0 +1,398 @@
+package config
+
+import (
+	"fmt"
+	"mcp-feishu/internal/acl"
+	"mcp-feishu/internal/types"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// webhookURLPattern 飞书和Lark自定义机器人Webhook地址格式
+var webhookURLPattern = regexp.MustCompile(`^https://open\.(feishu\.cn|larksuite\.com)/open-apis/bot/v2/hook/[^/?#\s]+$`)
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	Path    string // 字段路径，如 feishu.webhook_url
+	Message string
+}
+
+// ValidationError 配置校验错误，汇总所有字段的问题一次性报告
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Add 添加一个字段错误
+func (e *ValidationError) Add(path, message string) {
+	e.Errors = append(e.Errors, FieldError{Path: path, Message: message})
+}
+
+// Error 实现error接口，每个字段错误占一行
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		lines = append(lines, fmt.Sprintf("  - %s: %s", fe.Path, fe.Message))
+	}
+	return fmt.Sprintf("发现%d个配置问题:\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// errOrNil 没有错误时返回nil，避免返回非nil的空接口
+func (e *ValidationError) errOrNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate 验证配置，用于合并后的配置或重新加载时的检查
+func Validate(config *Config) error {
+	return validateConfig(config)
+}
+
+// validateConfig 验证配置，返回包含全部问题的*ValidationError
+func validateConfig(config *Config) error {
+	verr := &ValidationError{}
+	collectValidationErrors(config, verr)
+	return verr.errOrNil()
+}
+
+// collectValidationErrors 检查配置的所有字段，将问题追加到verr
+func collectValidationErrors(config *Config, verr *ValidationError) {
+	validateWebhookURL("feishu.webhook_url", config.Feishu.WebhookURL, verr)
+	validateSecurityPolicies("feishu", config.Feishu, verr)
+	validateKeywordPolicy("feishu.keyword_policy", config.Feishu.KeywordPolicy, verr)
+
+	validateContentFilter(config.Feishu.ContentFilter, verr)
+	validateDigest("feishu.digest", config.Feishu.Digest, verr)
+	validateTargets(config.Feishu, verr)
+
+	// 应用机器人配置需要同时提供app_id和app_secret
+	if config.Feishu.AppID != "" && config.Feishu.AppSecret == "" {
+		verr.Add("feishu.app_secret", "配置了app_id时app_secret不能为空")
+	}
+	if config.Feishu.AppSecret != "" && config.Feishu.AppID == "" {
+		verr.Add("feishu.app_id", "配置了app_secret时app_id不能为空")
+	}
+
+	if config.Feishu.APIBaseURL != "" {
+		if u, err := url.Parse(config.Feishu.APIBaseURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			verr.Add("feishu.api_base_url", "必须是https地址，如 https://open.feishu.cn/open-apis")
+		}
+	}
+
+	if config.Feishu.MentionDirectory != "" {
+		if _, err := os.Stat(config.Feishu.MentionDirectory); err != nil {
+			verr.Add("feishu.mention_directory", fmt.Sprintf("用户目录文件不可用: %v", err))
+		}
+	}
+
+	if config.Server.Port < 0 || config.Server.Port > 65535 {
+		verr.Add("server.port", fmt.Sprintf("端口%d超出范围，应为0-65535", config.Server.Port))
+	}
+
+	validateServerAuth(config.Server, verr)
+
+	if config.Server.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(config.Server.MetricsAddr); err != nil {
+			verr.Add("server.metrics_addr", fmt.Sprintf("监听地址格式无效，应为 host:port: %v", err))
+		}
+	}
+
+	if config.Server.PolicyFile != "" {
+		if _, err := acl.Load(config.Server.PolicyFile); err != nil {
+			verr.Add("server.policy_file", err.Error())
+		}
+	}
+
+	switch config.Tracing.Exporter {
+	case "":
+	case TraceExporterOTLP:
+		if u, err := url.Parse(config.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			verr.Add("tracing.endpoint", "otlp导出方式需要http(s)接收端地址，如 http://localhost:4318")
+		}
+	case TraceExporterFile:
+		if config.Tracing.File == "" {
+			verr.Add("tracing.file", "file导出方式需要指定文件路径")
+		}
+	default:
+		verr.Add("tracing.exporter", fmt.Sprintf("不支持的导出方式: %s，可选值: otlp, file", config.Tracing.Exporter))
+	}
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		verr.Add("tracing.sample_ratio", fmt.Sprintf("采样比例应在0到1之间: %g", config.Tracing.SampleRatio))
+	}
+
+	if config.Audit.MaxSizeMB <= 0 {
+		verr.Add("audit.max_size_mb", fmt.Sprintf("文件大小上限必须大于0，当前为%d", config.Audit.MaxSizeMB))
+	}
+	if config.Audit.MaxBackups < 0 {
+		verr.Add("audit.max_backups", fmt.Sprintf("历史文件数量不能为负数，当前为%d", config.Audit.MaxBackups))
+	}
+
+	quotas := []struct {
+		path  string
+		value int
+	}{
+		{"quota.per_minute", config.Quota.PerMinute},
+		{"quota.per_hour", config.Quota.PerHour},
+		{"quota.per_day", config.Quota.PerDay},
+		{"quota.breaker_threshold", config.Quota.BreakerThreshold},
+	}
+	for _, q := range quotas {
+		if q.value < 0 {
+			verr.Add(q.path, fmt.Sprintf("不能为负数，当前为%d", q.value))
+		}
+	}
+	if config.Quota.BreakerThreshold > 0 && config.Quota.BreakerCooldownSeconds <= 0 {
+		verr.Add("quota.breaker_cooldown_seconds", fmt.Sprintf("启用熔断时暂停时长必须大于0，当前为%d", config.Quota.BreakerCooldownSeconds))
+	}
+}
+
+// minAPIKeyLength API密钥的最小长度，避免使用容易猜测的短密钥
+const minAPIKeyLength = 16
+
+// validateServerAuth 校验传输方式、API密钥和TLS配置，HTTP传输必须启用认证
+func validateServerAuth(server ServerConfig, verr *ValidationError) {
+	switch server.Transport {
+	case "", TransportStdio:
+	case TransportHTTP:
+		if server.Port == 0 {
+			verr.Add("server.port", "http传输需要指定监听端口")
+		}
+		if len(server.APIKeys) == 0 && server.TLS.ClientCAFile == "" {
+			verr.Add("server.api_keys", "http传输必须配置API密钥或客户端证书(tls.client_ca_file)认证")
+		}
+	default:
+		verr.Add("server.transport", fmt.Sprintf("不支持的传输方式: %s，可选值: stdio, http", server.Transport))
+	}
+
+	names := make(map[string]bool)
+	keys := make(map[string]bool)
+	for i, key := range server.APIKeys {
+		path := fmt.Sprintf("server.api_keys[%d]", i)
+		switch {
+		case key.Name == "":
+			verr.Add(path+".name", "API密钥名称不能为空")
+		case names[key.Name]:
+			verr.Add(path+".name", fmt.Sprintf("API密钥名称重复: %s", key.Name))
+		}
+		names[key.Name] = true
+
+		switch {
+		case len(key.Key) < minAPIKeyLength:
+			verr.Add(path+".key", fmt.Sprintf("API密钥长度不能少于%d个字符", minAPIKeyLength))
+		case keys[key.Key]:
+			verr.Add(path+".key", "与其他API密钥重复")
+		}
+		keys[key.Key] = true
+	}
+
+	tlsConfig := server.TLS
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		verr.Add("server.tls", "cert_file和key_file需要同时配置")
+	}
+	if tlsConfig.ClientCAFile != "" && tlsConfig.CertFile == "" {
+		verr.Add("server.tls.client_ca_file", "客户端证书认证需要同时配置服务器证书cert_file和key_file")
+	}
+	files := []struct {
+		path string
+		file string
+	}{
+		{"server.tls.cert_file", tlsConfig.CertFile},
+		{"server.tls.key_file", tlsConfig.KeyFile},
+		{"server.tls.client_ca_file", tlsConfig.ClientCAFile},
+	}
+	for _, f := range files {
+		if f.file == "" {
+			continue
+		}
+		if _, err := os.Stat(f.file); err != nil {
+			verr.Add(f.path, fmt.Sprintf("文件不可用: %v", err))
+		}
+	}
+}
+
+// validateKeywordPolicy 校验关键词缺失时的处理策略
+func validateKeywordPolicy(path, policy string, verr *ValidationError) {
+	switch types.KeywordPolicy(policy) {
+	case "", types.KeywordPolicyReject, types.KeywordPolicyPrepend, types.KeywordPolicyAppend:
+	default:
+		verr.Add(path, fmt.Sprintf("不支持的关键词策略: %s，可选值为reject、prepend、append", policy))
+	}
+}
+
+// validateTargets 校验广播使用的命名目标和目标组
+func validateTargets(feishu types.FeishuConfig, verr *ValidationError) {
+	names := map[string]bool{types.DefaultTargetName: true}
+	for i, target := range feishu.Targets {
+		path := fmt.Sprintf("feishu.targets[%d]", i)
+		switch {
+		case target.Name == "":
+			verr.Add(path+".name", "目标名称不能为空")
+		case target.Name == types.DefaultTargetName:
+			verr.Add(path+".name", "webhook表示默认Webhook，不能用作目标名称")
+		case target.Name == "direct":
+			// 指标的target标签用direct表示应用机器人私聊
+			verr.Add(path+".name", "direct表示应用机器人私聊，不能用作目标名称")
+		case strings.ContainsAny(target.Name, ":/*?[]"):
+			verr.Add(path+".name", fmt.Sprintf("目标名称%q不能包含 : / * ? [ ]", target.Name))
+		case names[target.Name]:
+			verr.Add(path+".name", fmt.Sprintf("目标名称重复: %s", target.Name))
+		}
+		names[target.Name] = true
+
+		validateWebhookURL(path+".webhook_url", target.WebhookURL, verr)
+		validateSecurityPolicies(path, target.FeishuConfig(feishu), verr)
+		validateKeywordPolicy(path+".keyword_policy", target.KeywordPolicy, verr)
+		validateDigest(path+".digest", target.Digest, verr)
+	}
+
+	// 按组名顺序检查，错误信息的顺序保持稳定
+	groups := make([]string, 0, len(feishu.TargetGroups))
+	for group := range feishu.TargetGroups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		members := feishu.TargetGroups[group]
+		path := "feishu.target_groups." + group
+		if len(members) == 0 {
+			verr.Add(path, "目标组不能为空")
+		}
+		for _, member := range members {
+			if !names[member] {
+				verr.Add(path, fmt.Sprintf("目标组包含未定义的目标: %s", member))
+			}
+		}
+	}
+
+	if feishu.BroadcastConcurrency < 0 {
+		verr.Add("feishu.broadcast_concurrency", fmt.Sprintf("不能为负数，当前为%d", feishu.BroadcastConcurrency))
+	}
+}
+
+// validateDigest 校验摘要模式的窗口、格式和合并上限
+func validateDigest(path string, digest types.DigestConfig, verr *ValidationError) {
+	if digest.WindowSeconds < 0 {
+		verr.Add(path+".window_seconds", fmt.Sprintf("不能为负数，当前为%d", digest.WindowSeconds))
+	}
+	switch types.DigestFormat(digest.Format) {
+	case "", types.DigestFormatPost, types.DigestFormatCard:
+	default:
+		verr.Add(path+".format", fmt.Sprintf("不支持的摘要格式: %s，可选值: post, card", digest.Format))
+	}
+	if digest.MaxMessages < 0 {
+		verr.Add(path+".max_messages", fmt.Sprintf("不能为负数，当前为%d", digest.MaxMessages))
+	}
+}
+
+// validateContentFilter 校验内容过滤的处理动作和自定义规则
+func validateContentFilter(filter types.ContentFilterConfig, verr *ValidationError) {
+	builtins := []struct {
+		path   string
+		action string
+	}{
+		{"feishu.content_filter.credentials", filter.Credentials},
+		{"feishu.content_filter.id_card", filter.IDCard},
+		{"feishu.content_filter.phone", filter.Phone},
+		{"feishu.content_filter.email", filter.Email},
+	}
+	for _, builtin := range builtins {
+		if builtin.action != "" && !validFilterAction(builtin.action) {
+			verr.Add(builtin.path, fmt.Sprintf("不支持的处理动作: %s，可选值为block、redact、warn", builtin.action))
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range filter.Rules {
+		path := fmt.Sprintf("feishu.content_filter.rules[%d]", i)
+		if rule.Name == "" {
+			verr.Add(path+".name", "规则名称不能为空")
+		} else if names[rule.Name] {
+			verr.Add(path+".name", fmt.Sprintf("规则名称重复: %s", rule.Name))
+		}
+		names[rule.Name] = true
+
+		if rule.Pattern == "" {
+			verr.Add(path+".pattern", "正则表达式不能为空")
+		} else if _, err := regexp.Compile(rule.Pattern); err != nil {
+			verr.Add(path+".pattern", fmt.Sprintf("正则表达式无效: %v", err))
+		}
+		if !validFilterAction(rule.Action) {
+			verr.Add(path+".action", fmt.Sprintf("不支持的处理动作: %s，可选值为block、redact、warn", rule.Action))
+		}
+	}
+}
+
+// validFilterAction 是否为支持的内容过滤处理动作
+func validFilterAction(action string) bool {
+	switch types.FilterAction(action) {
+	case types.FilterActionBlock, types.FilterActionRedact, types.FilterActionWarn:
+		return true
+	}
+	return false
+}
+
+// validateWebhookURL 校验Webhook地址格式
+func validateWebhookURL(path, webhookURL string, verr *ValidationError) {
+	if webhookURL == "" {
+		verr.Add(path, "飞书Webhook URL不能为空")
+		return
+	}
+
+	if !webhookURLPattern.MatchString(webhookURL) && !isLoopbackWebhookURL(webhookURL) {
+		verr.Add(path, "格式无效，应为 https://open.feishu.cn/open-apis/bot/v2/hook/<token> 或 https://open.larksuite.com/open-apis/bot/v2/hook/<token>，本机模拟服务器可使用 http://127.0.0.1:<port>/open-apis/bot/v2/hook/<token>")
+	}
+}
+
+// validateSecurityPolicies 校验每个启用的安全策略所需的配置，prefix为字段路径前缀，如feishu或feishu.targets[0]
+func validateSecurityPolicies(prefix string, feishu types.FeishuConfig, verr *ValidationError) {
+	for _, name := range feishu.SecurityPolicies {
+		if types.SecurityType(name) == types.SecurityTypeNone && len(feishu.SecurityPolicies) > 1 {
+			verr.Add(prefix+".security_policies", "安全策略none不能与其他策略组合")
+		}
+	}
+
+	policyPath := prefix + ".security_type"
+	if len(feishu.SecurityPolicies) > 0 {
+		policyPath = prefix + ".security_policies"
+	}
+
+	for _, policy := range feishu.EnabledSecurityPolicies() {
+		switch policy {
+		case types.SecurityTypeSignature:
+			if feishu.Secret == "" {
+				verr.Add(prefix+".secret", "签名校验模式下密钥不能为空（可使用secret、secret_file或secret_command）")
+			}
+		case types.SecurityTypeKeyword:
+			if len(feishu.Keywords) == 0 {
+				verr.Add(prefix+".keywords", "关键词模式下关键词列表不能为空")
+			}
+		case types.SecurityTypeIPAllowlist:
+			// IP白名单由飞书服务端校验，只需保证出口IP已加入白名单
+		default:
+			verr.Add(policyPath, fmt.Sprintf("不支持的安全类型: %s，可选值为none、signature、keyword、ip_allowlist", policy))
+		}
+	}
+}
+
+// isLoopbackWebhookURL 是否为本机上的Webhook地址，允许使用feishutest模拟服务器进行离线开发
+func isLoopbackWebhookURL(webhookURL string) bool {
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if !strings.HasPrefix(u.Path, "/open-apis/bot/v2/hook/") || len(u.Path) == len("/open-apis/bot/v2/hook/") {
+		return false
+	}
+
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"mcp-feishu/internal/types"
+	"testing"
+)
+
+func TestValidateDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		digest   types.DigestConfig
+		target   types.DigestConfig
+		wantPath []string
+	}{
+		{"未启用", types.DigestConfig{}, types.DigestConfig{}, nil},
+		{"默认Webhook", types.DigestConfig{WindowSeconds: -1, Format: "markdown"}, types.DigestConfig{}, []string{"feishu.digest.window_seconds", "feishu.digest.format"}},
+		{"命名目标", types.DigestConfig{WindowSeconds: 60}, types.DigestConfig{WindowSeconds: 60, MaxMessages: -1}, []string{"feishu.targets[0].digest.max_messages"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verr := &ValidationError{}
+			validateDigest("feishu.digest", tt.digest, verr)
+			validateTargets(types.FeishuConfig{
+				Targets: []types.WebhookTarget{{
+					Name:       "alerts",
+					WebhookURL: "https://open.feishu.cn/open-apis/bot/v2/hook/alerts",
+					Digest:     tt.target,
+				}},
+			}, verr)
+
+			var paths []string
+			for _, fe := range verr.Errors {
+				paths = append(paths, fe.Path)
+			}
+			if len(paths) != len(tt.wantPath) {
+				t.Fatalf("错误字段 = %v, want %v", paths, tt.wantPath)
+			}
+			for i := range paths {
+				if paths[i] != tt.wantPath[i] {
+					t.Errorf("错误字段 = %v, want %v", paths, tt.wantPath)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval 配置文件轮询间隔
+const DefaultWatchInterval = 2 * time.Second
+
+// FileWatcher 通过轮询修改时间和大小监听配置文件变化
+//
+// 使用轮询而不是inotify等系统接口，避免引入额外依赖，并且能正确处理
+// 编辑器"写临时文件再重命名"的保存方式以及Kubernetes ConfigMap的符号链接切换。
+type FileWatcher struct {
+	path     string
+	interval time.Duration
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// fileState 文件状态快照
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// NewFileWatcher 创建配置文件监听器，interval为0时使用默认间隔
+func NewFileWatcher(path string, interval time.Duration) *FileWatcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	return &FileWatcher{
+		path:     path,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start 在后台开始监听，文件内容发生变化时调用onChange
+func (w *FileWatcher) Start(onChange func()) {
+	last := w.stat()
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+				current := w.stat()
+				// 文件被删除时等待其重新出现，不触发重载
+				if current != last && current.exists {
+					onChange()
+				}
+				last = current
+			}
+		}
+	}()
+}
+
+// Stop 停止监听
+func (w *FileWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+}
+
+// stat 获取文件当前状态，os.Stat会跟随符号链接
+func (w *FileWatcher) stat() fileState {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return fileState{}
+	}
+
+	return fileState{
+		exists:  true,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	}
+}
@@ -0,0 +1,422 @@
+package feishu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mcp-feishu/internal/types"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAPIBaseURL 飞书开放平台API默认地址
+const DefaultAPIBaseURL = "https://open.feishu.cn/open-apis"
+
+const (
+	// tokenRefreshMargin 在令牌过期前提前刷新的时间
+	tokenRefreshMargin = 5 * time.Minute
+	// userIDCacheTTL 邮箱到open_id映射的缓存时间
+	userIDCacheTTL = 24 * time.Hour
+	// maxChatPageSize 群聊接口单页最大数量
+	maxChatPageSize = 100
+	// maxChatSearchPages 按名字查找群聊时最多翻页数
+	maxChatSearchPages = 5
+)
+
+// tokenInvalidCodes 表示tenant_access_token无效或已过期的错误码，令牌在本地缓存过期前被吊销时会出现，
+// 收到后刷新令牌重试一次；此时请求在鉴权阶段就被拒绝，重试不会导致重复发送
+var tokenInvalidCodes = map[int]bool{
+	99991663: true,
+	99991668: true,
+}
+
+// ErrUserNotFound 通讯录中没有与邮箱对应的用户
+var ErrUserNotFound = errors.New("未找到邮箱对应的用户")
+
+// AppClient 飞书应用机器人客户端，通过开放平台API发送消息
+type AppClient struct {
+	appID      string
+	appSecret  string
+	baseURL    string
+	httpClient *http.Client
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+
+	cacheMu     sync.RWMutex
+	userIDCache map[string]cachedUserID
+}
+
+// cachedUserID 缓存的用户ID
+type cachedUserID struct {
+	openID    string
+	expiresAt time.Time
+}
+
+// apiResponse 开放平台API通用响应
+type apiResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"msg"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// APIError 开放平台API返回的业务错误
+type APIError struct {
+	Code    int
+	Message string
+}
+
+// Error 实现error接口
+func (e *APIError) Error() string {
+	return fmt.Sprintf("飞书API返回错误: code=%d, message=%s", e.Code, e.Message)
+}
+
+// NewAppClient 创建应用机器人客户端
+func NewAppClient(config types.FeishuConfig) *AppClient {
+	baseURL := strings.TrimRight(config.APIBaseURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultAPIBaseURL
+	}
+
+	return &AppClient{
+		appID:     config.AppID,
+		appSecret: config.AppSecret,
+		baseURL:   baseURL,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		userIDCache: make(map[string]cachedUserID),
+	}
+}
+
+// tenantAccessToken 获取tenant_access_token，过期前自动刷新
+func (ac *AppClient) tenantAccessToken() (string, error) {
+	ac.tokenMu.Lock()
+	defer ac.tokenMu.Unlock()
+
+	if ac.token != "" && time.Now().Before(ac.tokenExpiry) {
+		return ac.token, nil
+	}
+
+	body := map[string]string{
+		"app_id":     ac.appID,
+		"app_secret": ac.appSecret,
+	}
+
+	// 该接口的令牌字段位于响应顶层，而不是data中
+	var resp struct {
+		Code              int    `json:"code"`
+		Message           string `json:"msg"`
+		TenantAccessToken string `json:"tenant_access_token"`
+		Expire            int    `json:"expire"`
+	}
+	if err := ac.doJSON("POST", "/auth/v3/tenant_access_token/internal", "", body, &resp); err != nil {
+		return "", fmt.Errorf("获取tenant_access_token失败: %w", err)
+	}
+	if resp.Code != 0 {
+		return "", fmt.Errorf("获取tenant_access_token失败: code=%d, message=%s", resp.Code, resp.Message)
+	}
+
+	ac.token = resp.TenantAccessToken
+	ac.tokenExpiry = time.Now().Add(time.Duration(resp.Expire)*time.Second - tokenRefreshMargin)
+	return ac.token, nil
+}
+
+// invalidateToken 丢弃被飞书拒绝的令牌，令牌已被其他请求刷新时保留新令牌
+func (ac *AppClient) invalidateToken(token string) {
+	ac.tokenMu.Lock()
+	defer ac.tokenMu.Unlock()
+
+	if ac.token == token {
+		ac.token = ""
+	}
+}
+
+// call 调用需要鉴权的开放平台API，并将data字段解析到out
+func (ac *AppClient) call(method, path string, body interface{}, out interface{}) error {
+	_, err := ac.callWithRetry(method, path, body, out)
+	return err
+}
+
+// callWithRetry 同call，令牌失效时刷新令牌后重试一次，返回重试次数
+func (ac *AppClient) callWithRetry(method, path string, body interface{}, out interface{}) (int, error) {
+	retries := 0
+	for {
+		err := ac.callOnce(method, path, body, out)
+		var apiErr *APIError
+		if retries == 0 && errors.As(err, &apiErr) && tokenInvalidCodes[apiErr.Code] {
+			retries++
+			continue
+		}
+		return retries, err
+	}
+}
+
+// callOnce 使用当前令牌调用一次API，令牌被拒绝时将其丢弃
+func (ac *AppClient) callOnce(method, path string, body interface{}, out interface{}) error {
+	token, err := ac.tenantAccessToken()
+	if err != nil {
+		return err
+	}
+
+	var resp apiResponse
+	if err := ac.doJSON(method, path, token, body, &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		if tokenInvalidCodes[resp.Code] {
+			ac.invalidateToken(token)
+		}
+		return &APIError{Code: resp.Code, Message: resp.Message}
+	}
+
+	if out != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return fmt.Errorf("解析响应数据失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// doJSON 发送JSON请求并解析JSON响应
+func (ac *AppClient) doJSON(method, path, token string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+		reader = bytes.NewReader(jsonData)
+	}
+
+	httpReq, err := http.NewRequest(method, ac.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := ac.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w, 响应内容: %s", err, string(respBody))
+	}
+
+	return nil
+}
+
+// ResolveOpenIDByEmail 通过邮箱查询用户open_id，结果会被缓存
+func (ac *AppClient) ResolveOpenIDByEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	ac.cacheMu.RLock()
+	cached, ok := ac.userIDCache[email]
+	ac.cacheMu.RUnlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.openID, nil
+	}
+
+	body := map[string]interface{}{
+		"emails": []string{email},
+	}
+
+	var data struct {
+		UserList []struct {
+			UserID string `json:"user_id"`
+			Email  string `json:"email"`
+		} `json:"user_list"`
+	}
+	if err := ac.call("POST", "/contact/v3/users/batch_get_id?user_id_type=open_id", body, &data); err != nil {
+		return "", fmt.Errorf("查询用户ID失败: %w", err)
+	}
+
+	for _, user := range data.UserList {
+		if strings.EqualFold(user.Email, email) && user.UserID != "" {
+			ac.cacheMu.Lock()
+			ac.userIDCache[email] = cachedUserID{
+				openID:    user.UserID,
+				expiresAt: time.Now().Add(userIDCacheTTL),
+			}
+			ac.cacheMu.Unlock()
+			return user.UserID, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUserNotFound, email)
+}
+
+// SendMessage 通过im/v1/messages接口向指定接收者发送消息
+func (ac *AppClient) SendMessage(receiveIDType types.ReceiveIDType, receiveID string, req *types.FeishuWebhookRequest) (string, error) {
+	messageID, _, err := ac.sendMessage(receiveIDType, receiveID, req)
+	return messageID, err
+}
+
+// sendMessage 同SendMessage，额外返回令牌失效后的重试次数
+func (ac *AppClient) sendMessage(receiveIDType types.ReceiveIDType, receiveID string, req *types.FeishuWebhookRequest) (string, int, error) {
+	receiveIDType, body, err := ac.DirectMessageRequest(receiveIDType, receiveID, req)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var data struct {
+		MessageID string `json:"message_id"`
+	}
+	path := "/im/v1/messages?receive_id_type=" + string(receiveIDType)
+	retries, err := ac.callWithRetry("POST", path, body, &data)
+	if err != nil {
+		return "", retries, err
+	}
+
+	return data.MessageID, retries, nil
+}
+
+// DirectMessageRequest 构建im/v1/messages接口的请求体，返回实际使用的接收者ID类型
+func (ac *AppClient) DirectMessageRequest(receiveIDType types.ReceiveIDType, receiveID string, req *types.FeishuWebhookRequest) (types.ReceiveIDType, *types.DirectMessageRequest, error) {
+	// 邮箱先解析为open_id，便于缓存和统一处理
+	if receiveIDType == types.ReceiveIDTypeEmail {
+		openID, err := ac.ResolveOpenIDByEmail(receiveID)
+		if err != nil {
+			return "", nil, err
+		}
+		receiveIDType = types.ReceiveIDTypeOpenID
+		receiveID = openID
+	}
+
+	content, err := directMessageContent(req)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return receiveIDType, &types.DirectMessageRequest{
+		ReceiveID: receiveID,
+		MsgType:   req.MsgType,
+		Content:   content,
+	}, nil
+}
+
+// ListChats 分页列出机器人所在的群聊
+func (ac *AppClient) ListChats(pageSize int, pageToken string) (*types.ChatList, error) {
+	query := chatPageQuery(pageSize, pageToken)
+
+	var list types.ChatList
+	if err := ac.call("GET", "/im/v1/chats?"+query.Encode(), nil, &list); err != nil {
+		return nil, fmt.Errorf("获取群聊列表失败: %w", err)
+	}
+	return &list, nil
+}
+
+// SearchChats 按关键词搜索机器人可见的群聊
+func (ac *AppClient) SearchChats(keyword string, pageSize int, pageToken string) (*types.ChatList, error) {
+	query := chatPageQuery(pageSize, pageToken)
+	query.Set("query", keyword)
+
+	var list types.ChatList
+	if err := ac.call("GET", "/im/v1/chats/search?"+query.Encode(), nil, &list); err != nil {
+		return nil, fmt.Errorf("搜索群聊失败: %w", err)
+	}
+	return &list, nil
+}
+
+// FindChats 按名字查找群聊，名字完全相同的结果排在前面
+func (ac *AppClient) FindChats(name string) ([]types.ChatInfo, error) {
+	var exact, partial []types.ChatInfo
+
+	pageToken := ""
+	for page := 0; page < maxChatSearchPages; page++ {
+		list, err := ac.SearchChats(name, maxChatPageSize, pageToken)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, chat := range list.Items {
+			if strings.EqualFold(chat.Name, name) {
+				exact = append(exact, chat)
+			} else {
+				partial = append(partial, chat)
+			}
+		}
+
+		if !list.HasMore || list.PageToken == "" {
+			break
+		}
+		pageToken = list.PageToken
+	}
+
+	return append(exact, partial...), nil
+}
+
+// chatPageQuery 构造群聊接口的分页参数
+func chatPageQuery(pageSize int, pageToken string) url.Values {
+	if pageSize <= 0 || pageSize > maxChatPageSize {
+		pageSize = maxChatPageSize
+	}
+
+	query := url.Values{}
+	query.Set("page_size", strconv.Itoa(pageSize))
+	if pageToken != "" {
+		query.Set("page_token", pageToken)
+	}
+	return query
+}
+
+// directMessageContent 将Webhook消息内容转换为im/v1接口要求的JSON字符串
+func directMessageContent(req *types.FeishuWebhookRequest) (string, error) {
+	var content interface{}
+
+	switch v := req.Content.(type) {
+	case *types.PostMessage:
+		// im/v1接口的富文本内容不需要外层的post字段
+		content = v.Post
+	case *types.ShareChatMessage:
+		// im/v1接口使用chat_id字段
+		content = map[string]string{"chat_id": v.ShareChatID}
+	default:
+		content = v
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", fmt.Errorf("序列化消息内容失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// ParseReceiveIDType 解析接收者ID类型，未指定时根据ID格式推断
+func ParseReceiveIDType(idType, receiveID string) (types.ReceiveIDType, error) {
+	switch types.ReceiveIDType(idType) {
+	case types.ReceiveIDTypeEmail, types.ReceiveIDTypeOpenID, types.ReceiveIDTypeUserID, types.ReceiveIDTypeUnionID:
+		return types.ReceiveIDType(idType), nil
+	case "":
+		switch {
+		case strings.Contains(receiveID, "@"):
+			return types.ReceiveIDTypeEmail, nil
+		case strings.HasPrefix(receiveID, "ou_"):
+			return types.ReceiveIDTypeOpenID, nil
+		case strings.HasPrefix(receiveID, "on_"):
+			return types.ReceiveIDTypeUnionID, nil
+		default:
+			return types.ReceiveIDTypeUserID, nil
+		}
+	default:
+		return "", fmt.Errorf("不支持的接收者ID类型: %s", idType)
+	}
+}
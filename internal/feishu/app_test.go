@@ -0,0 +1,146 @@
+package feishu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mcp-feishu/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAppClientDirectMessageRequest(t *testing.T) {
+	ac := newTestAppClient(t, map[string]string{"alice@example.com": "ou_alice"})
+	builder := NewMessageBuilder(NewSecurityManager(nil, "", nil, ""))
+
+	post, err := builder.BuildPostMessage(map[types.Locale]types.PostLocale{
+		types.LocaleZhCN: {Title: "发布", Content: [][]interface{}{{CreateTextElement("已上线")}}},
+	})
+	if err != nil {
+		t.Fatalf("BuildPostMessage() error = %v", err)
+	}
+	text, err := builder.BuildTextMessage("你好")
+	if err != nil {
+		t.Fatalf("BuildTextMessage() error = %v", err)
+	}
+	shareChat, err := builder.BuildShareChatMessage("oc_123")
+	if err != nil {
+		t.Fatalf("BuildShareChatMessage() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		receiveIDType types.ReceiveIDType
+		receiveID     string
+		req           *types.FeishuWebhookRequest
+		wantIDType    types.ReceiveIDType
+		want          types.DirectMessageRequest
+	}{
+		{
+			name: "邮箱解析为open_id", receiveIDType: types.ReceiveIDTypeEmail, receiveID: "alice@example.com", req: text,
+			wantIDType: types.ReceiveIDTypeOpenID,
+			want:       types.DirectMessageRequest{ReceiveID: "ou_alice", MsgType: "text", Content: `{"text":"你好"}`},
+		},
+		{
+			name: "富文本去掉外层post字段", receiveIDType: types.ReceiveIDTypeUserID, receiveID: "u_1", req: post,
+			wantIDType: types.ReceiveIDTypeUserID,
+			want:       types.DirectMessageRequest{ReceiveID: "u_1", MsgType: "post", Content: `{"zh_cn":{"content":[[{"tag":"text","text":"已上线"}]],"title":"发布"}}`},
+		},
+		{
+			name: "群名片使用chat_id", receiveIDType: types.ReceiveIDTypeOpenID, receiveID: "ou_bob", req: shareChat,
+			wantIDType: types.ReceiveIDTypeOpenID,
+			want:       types.DirectMessageRequest{ReceiveID: "ou_bob", MsgType: "share_chat", Content: `{"chat_id":"oc_123"}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idType, got, err := ac.DirectMessageRequest(tt.receiveIDType, tt.receiveID, tt.req)
+			if err != nil {
+				t.Fatalf("DirectMessageRequest() error = %v", err)
+			}
+			if idType != tt.wantIDType {
+				t.Errorf("receive_id_type = %s, want %s", idType, tt.wantIDType)
+			}
+			if *got != tt.want {
+				t.Errorf("DirectMessageRequest() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendDirectMessageRetriesRevokedToken(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		tokens      int
+		sends       int
+		revokeAll   bool // 为true时所有令牌都被拒绝
+		nextTokenID int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/auth/v3/tenant_access_token/internal":
+			tokens++
+			nextTokenID++
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": fmt.Sprintf("t-%d", nextTokenID), "expire": 7200})
+		case "/im/v1/messages":
+			sends++
+			// 第一个令牌在本地缓存过期前已被吊销
+			if revokeAll || r.Header.Get("Authorization") == "Bearer t-1" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"code": 99991663, "msg": "Invalid access token for authorization"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]interface{}{"message_id": "om_1"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	counts := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		gotTokens, gotSends := tokens, sends
+		tokens, sends = 0, 0
+		return gotTokens, gotSends
+	}
+
+	client := NewClient(types.FeishuConfig{AppID: "cli_test", AppSecret: "secret", APIBaseURL: server.URL})
+	hooks := &recordingHooks{}
+	client.SetHooks(hooks)
+
+	req, err := client.DirectMessageBuilder().BuildTextMessage("你好")
+	if err != nil {
+		t.Fatal(err)
+	}
+	messageID, err := client.SendDirectMessage(context.Background(), types.ReceiveIDTypeOpenID, "ou_alice", req)
+	if err != nil || messageID != "om_1" {
+		t.Fatalf("SendDirectMessage() = %q, %v, want om_1", messageID, err)
+	}
+	if gotTokens, gotSends := counts(); gotTokens != 2 || gotSends != 2 {
+		t.Errorf("获取令牌%d次、发送%d次，want 2、2", gotTokens, gotSends)
+	}
+	if len(hooks.sends) != 1 || hooks.sends[0].Retries != 1 {
+		t.Fatalf("钩子记录的发送 = %+v, want 1次发送且Retries=1", hooks.sends)
+	}
+
+	// 刷新后的令牌仍被拒绝时只重试一次
+	mu.Lock()
+	revokeAll = true
+	mu.Unlock()
+	_, err = client.SendDirectMessage(context.Background(), types.ReceiveIDTypeOpenID, "ou_alice", req)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 99991663 {
+		t.Fatalf("SendDirectMessage() error = %v, want code=99991663", err)
+	}
+	if gotTokens, gotSends := counts(); gotTokens != 1 || gotSends != 2 {
+		t.Errorf("获取令牌%d次、发送%d次，want 1、2", gotTokens, gotSends)
+	}
+	if hooks.sends[1].Retries != 1 {
+		t.Errorf("Retries = %d, want 1", hooks.sends[1].Retries)
+	}
+}
@@ -2,11 +2,16 @@ package feishu
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mcp-feishu/internal/tracing"
 	"mcp-feishu/internal/types"
+	"mcp-feishu/pkg/feishumsg"
 	"net/http"
+	"sort"
 	"time"
 )
 
@@ -16,28 +21,122 @@ type Client struct {
 	httpClient      *http.Client
 	messageBuilder  *MessageBuilder
 	securityManager *SecurityManager
+
+	// 应用机器人相关，未配置app_id时为nil
+	appClient     *AppClient
+	directBuilder *MessageBuilder
+
+	// @提及解析器，用户目录加载失败时记录错误并在使用时返回
+	mentionResolver *MentionResolver
+	mentionErr      error
+
+	// 预览模式，由工具处理器决定只构建不发送
+	dryRun bool
+
+	// 摘要聚合器，未启用摘要模式时为nil
+	digest *Digest
+
+	// 命名目标的名称，默认Webhook的客户端为空
+	name string
+
+	// 命名的群机器人目标，每个目标是使用各自Webhook和安全设置的独立客户端
+	targets      map[string]*Client
+	targetNames  []string // 按配置顺序
+	targetGroups map[string][]string
+
+	// 广播时同时发送的目标数上限
+	broadcastConcurrency int
+
+	// 发送钩子，未设置时为noHooks
+	hooks Hooks
 }
 
+// defaultBroadcastConcurrency 未配置broadcast_concurrency时广播的并发数
+const defaultBroadcastConcurrency = 4
+
 // NewClient 创建飞书客户端
 func NewClient(config types.FeishuConfig) *Client {
-	securityManager := NewSecurityManager(
-		types.SecurityType(config.SecurityType),
-		config.Secret,
-		config.Keywords,
-	)
+	securityManager := newSecurityManagerFromConfig(config)
 
-	return &Client{
+	client := &Client{
 		webhookURL: config.WebhookURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		messageBuilder:  NewMessageBuilder(securityManager),
 		securityManager: securityManager,
+		dryRun:          config.DryRun,
+		hooks:           noHooks{},
+	}
+	client.setupAppClient(config)
+	client.setupContentFilter(config)
+	client.setupMentionResolver(config)
+	client.digest = newDigest(client, config.Digest)
+	client.setupTargets(config)
+
+	return client
+}
+
+// setupTargets 为每个命名目标创建独立的客户端
+func (c *Client) setupTargets(config types.FeishuConfig) {
+	// 发送被替换的目标摘要中剩余的消息
+	for _, target := range c.targets {
+		target.Close()
+	}
+	c.targets = make(map[string]*Client, len(config.Targets))
+	c.targetNames = nil
+	for _, target := range config.Targets {
+		client := NewClient(target.FeishuConfig(config))
+		client.name = target.Name
+		client.hooks = c.hooks
+		c.targets[target.Name] = client
+		c.targetNames = append(c.targetNames, target.Name)
+	}
+	c.targetGroups = config.TargetGroups
+
+	c.broadcastConcurrency = config.BroadcastConcurrency
+	if c.broadcastConcurrency <= 0 {
+		c.broadcastConcurrency = defaultBroadcastConcurrency
+	}
+}
+
+// setupAppClient 根据配置初始化应用机器人客户端
+func (c *Client) setupAppClient(config types.FeishuConfig) {
+	if config.AppID == "" || config.AppSecret == "" {
+		c.appClient = nil
+		c.directBuilder = nil
+		return
+	}
+
+	c.appClient = NewAppClient(config)
+	// 私聊消息不经过自定义机器人，无需签名或关键词
+	c.directBuilder = NewMessageBuilder(NewSecurityManager(nil, "", nil, ""))
+}
+
+// SendMessage 发送消息，发送前后调用注入的钩子，ctx中的trace上下文会传播到请求头
+func (c *Client) SendMessage(ctx context.Context, req *types.FeishuWebhookRequest) (*types.FeishuWebhookResponse, error) {
+	return c.sendWebhook(ctx, &Send{Target: TargetWebhook, Name: c.name, Request: req})
+}
+
+// sendWebhook 发送send中的Webhook消息，发送前后调用注入的钩子
+func (c *Client) sendWebhook(ctx context.Context, send *Send) (*types.FeishuWebhookResponse, error) {
+	req := send.Request
+	ctx = c.hooks.BeforeSend(ctx, send)
+
+	start := time.Now()
+	resp, err := c.postWebhook(ctx, req)
+	send.Duration = time.Since(start)
+	send.Err = err
+	if resp != nil {
+		send.Response = &SendResponse{Code: resp.Code, Message: resp.Message}
 	}
+	c.hooks.AfterSend(ctx, send)
+
+	return resp, err
 }
 
-// SendMessage 发送消息
-func (c *Client) SendMessage(req *types.FeishuWebhookRequest) (*types.FeishuWebhookResponse, error) {
+// postWebhook 将消息发送到Webhook
+func (c *Client) postWebhook(ctx context.Context, req *types.FeishuWebhookRequest) (*types.FeishuWebhookResponse, error) {
 	// 序列化请求
 	jsonData, err := json.Marshal(req)
 	if err != nil {
@@ -45,12 +144,13 @@ func (c *Client) SendMessage(req *types.FeishuWebhookRequest) (*types.FeishuWebh
 	}
 
 	// 创建HTTP请求
-	httpReq, err := http.NewRequest("POST", c.webhookURL, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.webhookURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, httpReq.Header)
 
 	// 发送请求
 	resp, err := c.httpClient.Do(httpReq)
@@ -80,63 +180,218 @@ func (c *Client) SendMessage(req *types.FeishuWebhookRequest) (*types.FeishuWebh
 }
 
 // SendTextMessage 发送文本消息
-func (c *Client) SendTextMessage(text string) (*types.FeishuWebhookResponse, error) {
+func (c *Client) SendTextMessage(ctx context.Context, text string) (*types.FeishuWebhookResponse, error) {
 	req, err := c.messageBuilder.BuildTextMessage(text)
 	if err != nil {
 		return nil, fmt.Errorf("构建文本消息失败: %w", err)
 	}
 
-	return c.SendMessage(req)
+	return c.SendMessage(ctx, req)
 }
 
 // SendRichTextMessage 发送富文本消息
-func (c *Client) SendRichTextMessage(content interface{}) (*types.FeishuWebhookResponse, error) {
+func (c *Client) SendRichTextMessage(ctx context.Context, content interface{}) (*types.FeishuWebhookResponse, error) {
 	req, err := c.messageBuilder.BuildRichTextMessage(content)
 	if err != nil {
 		return nil, fmt.Errorf("构建富文本消息失败: %w", err)
 	}
 
-	return c.SendMessage(req)
+	return c.SendMessage(ctx, req)
 }
 
-// SendPostMessage 发送群名片消息
-func (c *Client) SendPostMessage(title string, content map[string]interface{}) (*types.FeishuWebhookResponse, error) {
-	req, err := c.messageBuilder.BuildPostMessage(title, content)
+// SendPostMessage 发送按语言提供标题和内容的富文本消息
+func (c *Client) SendPostMessage(ctx context.Context, posts map[types.Locale]types.PostLocale) (*types.FeishuWebhookResponse, error) {
+	req, err := c.messageBuilder.BuildPostMessage(posts)
 	if err != nil {
-		return nil, fmt.Errorf("构建群名片消息失败: %w", err)
+		return nil, fmt.Errorf("构建富文本消息失败: %w", err)
 	}
 
-	return c.SendMessage(req)
+	return c.SendMessage(ctx, req)
 }
 
 // SendImageMessage 发送图片消息
-func (c *Client) SendImageMessage(imageKey string) (*types.FeishuWebhookResponse, error) {
+func (c *Client) SendImageMessage(ctx context.Context, imageKey string) (*types.FeishuWebhookResponse, error) {
 	req, err := c.messageBuilder.BuildImageMessage(imageKey)
 	if err != nil {
 		return nil, fmt.Errorf("构建图片消息失败: %w", err)
 	}
 
-	return c.SendMessage(req)
+	return c.SendMessage(ctx, req)
 }
 
 // SendInteractiveMessage 发送交互式消息卡片
-func (c *Client) SendInteractiveMessage(config interface{}, elements []interface{}, header interface{}) (*types.FeishuWebhookResponse, error) {
+func (c *Client) SendInteractiveMessage(ctx context.Context, config interface{}, elements []interface{}, header interface{}) (*types.FeishuWebhookResponse, error) {
 	req, err := c.messageBuilder.BuildInteractiveMessage(config, elements, header)
 	if err != nil {
 		return nil, fmt.Errorf("构建交互式消息失败: %w", err)
 	}
 
-	return c.SendMessage(req)
+	return c.SendMessage(ctx, req)
+}
+
+// SendCardMessage 发送交互式消息卡片，支持按语言提供的i18n_elements
+func (c *Client) SendCardMessage(ctx context.Context, card *types.InteractiveMessage) (*types.FeishuWebhookResponse, error) {
+	req, err := c.messageBuilder.BuildCardMessage(card)
+	if err != nil {
+		return nil, fmt.Errorf("构建交互式消息失败: %w", err)
+	}
+
+	return c.SendMessage(ctx, req)
+}
+
+// Send 发送feishumsg类型化消息
+func (c *Client) Send(ctx context.Context, msg feishumsg.Message) (*types.FeishuWebhookResponse, error) {
+	req, err := c.messageBuilder.BuildMessage(msg)
+	if err != nil {
+		return nil, fmt.Errorf("构建消息失败: %w", err)
+	}
+
+	return c.SendMessage(ctx, req)
 }
 
 // SendShareChatMessage 发送群名片消息
-func (c *Client) SendShareChatMessage(shareChatID string) (*types.FeishuWebhookResponse, error) {
+func (c *Client) SendShareChatMessage(ctx context.Context, shareChatID string) (*types.FeishuWebhookResponse, error) {
 	req, err := c.messageBuilder.BuildShareChatMessage(shareChatID)
 	if err != nil {
 		return nil, fmt.Errorf("构建群名片消息失败: %w", err)
 	}
 
-	return c.SendMessage(req)
+	return c.SendMessage(ctx, req)
+}
+
+// setupContentFilter 为群机器人和私聊消息构建器添加内容过滤，
+// 配置无效时拒绝所有消息，而不是在没有过滤的情况下发送
+func (c *Client) setupContentFilter(config types.FeishuConfig) {
+	var filter ContentFilter
+	policyFilter, err := NewPolicyFilter(config.ContentFilter)
+	switch {
+	case err != nil:
+		filter = errorFilter{err: fmt.Errorf("内容过滤配置无效: %w", err)}
+	case policyFilter != nil:
+		filter = policyFilter
+	default:
+		return
+	}
+
+	c.messageBuilder.AddFilter(filter)
+	if c.directBuilder != nil {
+		c.directBuilder.AddFilter(filter)
+	}
+}
+
+// setupMentionResolver 根据配置初始化@提及解析器，静态目录优先于通讯录API
+func (c *Client) setupMentionResolver(config types.FeishuConfig) {
+	var directories []Directory
+	c.mentionErr = nil
+
+	if config.MentionDirectory != "" {
+		dir, err := LoadStaticDirectory(config.MentionDirectory)
+		if err != nil {
+			c.mentionErr = err
+		} else {
+			directories = append(directories, dir)
+		}
+	}
+
+	if c.appClient != nil {
+		directories = append(directories, &appDirectory{appClient: c.appClient})
+	}
+
+	c.mentionResolver = NewMentionResolver(directories...)
+}
+
+// ResolveTextMentions 将文本中的@名字、@邮箱、@all改写为飞书at标签
+func (c *Client) ResolveTextMentions(text string) (string, error) {
+	if c.mentionErr != nil {
+		return "", c.mentionErr
+	}
+
+	return c.mentionResolver.ResolveText(text)
+}
+
+// ResolvePostMentions 将富文本内容中的@提及拆分为at元素
+func (c *Client) ResolvePostMentions(content interface{}) (interface{}, error) {
+	if c.mentionErr != nil {
+		return nil, c.mentionErr
+	}
+
+	return c.mentionResolver.ResolvePostContent(content)
+}
+
+// DryRun 是否全局开启了预览模式
+func (c *Client) DryRun() bool {
+	return c.dryRun
+}
+
+// MessageBuilder 获取群机器人消息构建器，会应用配置的安全策略
+func (c *Client) MessageBuilder() *MessageBuilder {
+	return c.messageBuilder
+}
+
+// HasAppClient 是否配置了应用机器人
+func (c *Client) HasAppClient() bool {
+	return c.appClient != nil
+}
+
+// DirectMessageBuilder 获取私聊消息构建器，未配置应用机器人时返回nil
+func (c *Client) DirectMessageBuilder() *MessageBuilder {
+	return c.directBuilder
+}
+
+// SendDirectMessage 通过应用机器人向用户发送私聊消息，返回消息ID
+func (c *Client) SendDirectMessage(ctx context.Context, receiveIDType types.ReceiveIDType, receiveID string, req *types.FeishuWebhookRequest) (string, error) {
+	if c.appClient == nil {
+		return "", fmt.Errorf("未配置应用机器人(app_id/app_secret)，无法发送私聊消息")
+	}
+
+	send := &Send{
+		Target:        TargetDirect,
+		ReceiveIDType: receiveIDType,
+		Receiver:      string(receiveIDType) + ":" + receiveID,
+		Request:       req,
+	}
+	ctx = c.hooks.BeforeSend(ctx, send)
+
+	start := time.Now()
+	messageID, retries, err := c.appClient.sendMessage(receiveIDType, receiveID, req)
+	send.Duration = time.Since(start)
+	send.Retries = retries
+	send.Err = err
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		send.Response = &SendResponse{Code: apiErr.Code, Message: apiErr.Message}
+	} else if err == nil {
+		send.Response = &SendResponse{Code: 0, MessageID: messageID}
+	}
+	c.hooks.AfterSend(ctx, send)
+
+	return messageID, err
+}
+
+// DirectMessageRequest 返回SendDirectMessage实际发送的im/v1请求体，用于预览
+func (c *Client) DirectMessageRequest(receiveIDType types.ReceiveIDType, receiveID string, req *types.FeishuWebhookRequest) (types.ReceiveIDType, *types.DirectMessageRequest, error) {
+	if c.appClient == nil {
+		return "", nil, fmt.Errorf("未配置应用机器人(app_id/app_secret)，无法发送私聊消息")
+	}
+	return c.appClient.DirectMessageRequest(receiveIDType, receiveID, req)
+}
+
+// ListChats 列出机器人所在的群聊
+func (c *Client) ListChats(pageSize int, pageToken string) (*types.ChatList, error) {
+	if c.appClient == nil {
+		return nil, fmt.Errorf("未配置应用机器人(app_id/app_secret)，无法查询群聊")
+	}
+
+	return c.appClient.ListChats(pageSize, pageToken)
+}
+
+// FindChats 按名字查找群聊
+func (c *Client) FindChats(name string) ([]types.ChatInfo, error) {
+	if c.appClient == nil {
+		return nil, fmt.Errorf("未配置应用机器人(app_id/app_secret)，无法查询群聊")
+	}
+
+	return c.appClient.FindChats(name)
 }
 
 // GetSecurityManager 获取安全管理器
@@ -144,13 +399,52 @@ func (c *Client) GetSecurityManager() *SecurityManager {
 	return c.securityManager
 }
 
-// UpdateConfig 更新配置
-func (c *Client) UpdateConfig(config types.FeishuConfig) {
-	c.webhookURL = config.WebhookURL
-	c.securityManager = NewSecurityManager(
-		types.SecurityType(config.SecurityType),
-		config.Secret,
-		config.Keywords,
-	)
-	c.messageBuilder = NewMessageBuilder(c.securityManager)
+// Digest 返回摘要聚合器，未启用摘要模式时返回nil
+func (c *Client) Digest() *Digest {
+	return c.digest
+}
+
+// Target 返回命名目标的客户端，名称为webhook时返回默认Webhook的客户端
+func (c *Client) Target(name string) (*Client, bool) {
+	if name == types.DefaultTargetName {
+		return c, true
+	}
+	target, ok := c.targets[name]
+	return target, ok
+}
+
+// TargetNames 返回配置的命名目标，按配置顺序
+func (c *Client) TargetNames() []string {
+	return c.targetNames
+}
+
+// TargetGroup 返回目标组的成员名称
+func (c *Client) TargetGroup(name string) ([]string, bool) {
+	members, ok := c.targetGroups[name]
+	return members, ok
+}
+
+// TargetGroupNames 返回全部目标组名称，按字母顺序
+func (c *Client) TargetGroupNames() []string {
+	names := make([]string, 0, len(c.targetGroups))
+	for name := range c.targetGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// BroadcastConcurrency 返回广播时同时发送的目标数上限
+func (c *Client) BroadcastConcurrency() int {
+	return c.broadcastConcurrency
+}
+
+// Close 发送摘要（包括命名目标的摘要）中剩余的消息，客户端被替换或服务器退出时调用
+func (c *Client) Close() {
+	if c.digest != nil {
+		c.digest.Close()
+	}
+	for _, target := range c.targets {
+		target.Close()
+	}
 }
@@ -0,0 +1,118 @@
+package feishu
+
+import (
+	"context"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"mcp-feishu/pkg/feishumsg"
+	"strings"
+	"testing"
+)
+
+func TestClientSendThroughMock(t *testing.T) {
+	tests := []struct {
+		name        string
+		send        func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error)
+		wantMsgType string
+		wantText    string // 消息中可见的文本，为空时不检查
+	}{
+		{
+			name: "文本",
+			send: func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error) {
+				return c.SendTextMessage(ctx, "服务已恢复")
+			},
+			wantMsgType: "text",
+			wantText:    "服务已恢复",
+		},
+		{
+			name: "富文本",
+			send: func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error) {
+				return c.SendPostMessage(ctx, map[types.Locale]types.PostLocale{
+					types.DefaultLocale: {Title: "发布公告", Content: []interface{}{
+						[]interface{}{map[string]interface{}{"tag": "text", "text": "v2.3.0 已上线"}},
+					}},
+				})
+			},
+			wantMsgType: "post",
+			wantText:    "v2.3.0 已上线",
+		},
+		{
+			name: "图片",
+			send: func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error) {
+				return c.SendImageMessage(ctx, "img_v2_test")
+			},
+			wantMsgType: "image",
+		},
+		{
+			name: "卡片",
+			send: func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error) {
+				return c.SendCardMessage(ctx, &types.InteractiveMessage{Elements: []interface{}{CreateDivElement("请确认操作")}})
+			},
+			wantMsgType: "interactive",
+			wantText:    "请确认操作",
+		},
+		{
+			name: "类型化卡片",
+			send: func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error) {
+				return c.Send(ctx, feishumsg.NewCard().Add(feishumsg.Div(feishumsg.PlainText("订单服务已恢复"))))
+			},
+			wantMsgType: "interactive",
+			wantText:    "订单服务已恢复",
+		},
+		{
+			name: "群名片",
+			send: func(ctx context.Context, c *Client) (*types.FeishuWebhookResponse, error) {
+				return c.SendShareChatMessage(ctx, "oc_a0553eda9014c201e6969b478895c230")
+			},
+			wantMsgType: "share_chat",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mock := feishutest.Start(feishutest.Options{})
+			defer mock.Close()
+
+			resp, err := tt.send(context.Background(), NewClient(types.FeishuConfig{WebhookURL: mock.URL()}))
+			if err != nil {
+				t.Fatalf("发送失败: %v", err)
+			}
+			if resp.Code != feishutest.CodeSuccess {
+				t.Errorf("resp.Code = %d, want 0", resp.Code)
+			}
+
+			messages := mock.Messages()
+			if len(messages) != 1 || messages[0].MsgType != tt.wantMsgType {
+				t.Fatalf("Messages() = %+v, want 1条%s", messages, tt.wantMsgType)
+			}
+			if text := visibleText(messages[0].Content); !strings.Contains(text, tt.wantText) {
+				t.Errorf("消息文本 = %q, want 包含 %q", text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestClientSendError(t *testing.T) {
+	t.Parallel()
+	mock := feishutest.Start(feishutest.Options{})
+	defer mock.Close()
+	client := NewClient(types.FeishuConfig{WebhookURL: mock.URL()})
+
+	mock.FailNext(feishutest.CodeRateLimited, "frequency limited psm")
+	resp, err := client.SendTextMessage(context.Background(), "第一条")
+	if err == nil || resp == nil || resp.Code != feishutest.CodeRateLimited {
+		t.Fatalf("SendTextMessage() = %+v, %v, want code=%d", resp, err, feishutest.CodeRateLimited)
+	}
+	if !strings.Contains(err.Error(), "code=11232") {
+		t.Errorf("错误信息 = %v, want 包含飞书错误码", err)
+	}
+
+	// 预设的失败只生效一次
+	if _, err := client.SendTextMessage(context.Background(), "第二条"); err != nil {
+		t.Fatalf("SendTextMessage() error = %v", err)
+	}
+	if messages := mock.Messages(); len(messages) != 1 || messages[0].Content["text"] != "第二条" {
+		t.Errorf("Messages() = %+v, want 只有第二条", messages)
+	}
+}
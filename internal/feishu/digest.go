@@ -0,0 +1,207 @@
+package feishu
+
+import (
+	"context"
+	"fmt"
+	"mcp-feishu/internal/types"
+	"mcp-feishu/pkg/feishumsg"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultDigestMaxMessages 单条摘要默认最多合并的消息数
+const defaultDigestMaxMessages = 50
+
+// digestTimeFormat 摘要中每条消息的时间格式
+const digestTimeFormat = "15:04:05"
+
+// digestEntry 等待合并的一条文本
+type digestEntry struct {
+	text string
+	time time.Time
+	// ctx 加入摘要时的context，携带调用方、配额占用和trace上下文，发送后据此报告每条消息的结果
+	ctx context.Context
+}
+
+// Digest 摘要聚合器，窗口内加入的文本消息合并为一条富文本或卡片消息发送到Webhook
+//
+// 窗口从第一条消息加入时开始，到期、达到合并上限或Close时发送。
+type Digest struct {
+	client      *Client
+	window      time.Duration
+	format      types.DigestFormat
+	maxMessages int
+
+	mu      sync.Mutex
+	entries []digestEntry
+	timer   *time.Timer
+	closed  bool
+	// generation 当前窗口的序号，每次发送后加一；已经触发的定时器可能在窗口提前发送后才拿到锁，
+	// 按序号判断是否仍是创建它的窗口，避免提前发送下一个窗口
+	generation uint64
+
+	// sending 正在后台发送的摘要，Close时等待完成
+	sending sync.WaitGroup
+}
+
+// newDigest 根据配置创建摘要聚合器，未启用时返回nil
+func newDigest(client *Client, config types.DigestConfig) *Digest {
+	if config.WindowSeconds <= 0 {
+		return nil
+	}
+
+	format := types.DigestFormat(config.Format)
+	if format == "" {
+		format = types.DigestFormatPost
+	}
+	maxMessages := config.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = defaultDigestMaxMessages
+	}
+
+	return &Digest{
+		client:      client,
+		window:      time.Duration(config.WindowSeconds) * time.Second,
+		format:      format,
+		maxMessages: maxMessages,
+	}
+}
+
+// Add 加入一条文本，返回当前窗口内的消息数和预计发送时间
+//
+// 加入前按单条文本消息执行内容过滤和关键词检查，不能发送的消息立即返回错误，不会影响同一窗口的其他消息。
+// ctx中的值在合并发送时传给钩子，ctx被取消不影响摘要发送。
+func (d *Digest) Add(ctx context.Context, text string) (int, time.Time, error) {
+	if _, err := d.client.messageBuilder.BuildTextMessage(text); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return 0, time.Time{}, fmt.Errorf("摘要已关闭，飞书客户端已被替换或关闭")
+	}
+
+	now := time.Now()
+	d.entries = append(d.entries, digestEntry{text: text, time: now, ctx: context.WithoutCancel(ctx)})
+	d.client.hooks.Queued(1)
+	count := len(d.entries)
+
+	if count >= d.maxMessages {
+		d.flushLocked()
+		return count, now, nil
+	}
+	if d.timer == nil {
+		generation := d.generation
+		d.timer = time.AfterFunc(d.window, func() { d.flushWindow(generation) })
+	}
+	return count, d.entries[0].time.Add(d.window), nil
+}
+
+// Flush 立即在后台发送当前窗口内的消息
+func (d *Digest) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flushLocked()
+}
+
+// flushWindow 窗口到期时发送，窗口已经发送过时不做任何事
+func (d *Digest) flushWindow(generation uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if generation != d.generation {
+		return
+	}
+	d.flushLocked()
+}
+
+// flushLocked 取出窗口内的消息并在后台发送，调用方需持有d.mu
+func (d *Digest) flushLocked() {
+	d.generation++
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if len(d.entries) == 0 {
+		return
+	}
+
+	entries := d.entries
+	d.entries = nil
+	d.client.hooks.Queued(-len(entries))
+
+	d.sending.Add(1)
+	go func() {
+		defer d.sending.Done()
+		d.send(entries)
+	}()
+}
+
+// send 构建并发送摘要，失败时记录错误日志（调用方早已收到加入摘要的结果）
+//
+// 发送请求沿用窗口内第一条消息的context，发送结果通过钩子报告给每条消息。
+func (d *Digest) send(entries []digestEntry) {
+	req, err := d.build(entries)
+	if err != nil {
+		// 合并后超出消息大小等限制时逐条发送，每条消息单独通过了检查
+		log.Error().Err(err).Int("messages", len(entries)).Msg("构建摘要消息失败，改为逐条发送")
+		for _, entry := range entries {
+			if _, err := d.client.SendTextMessage(entry.ctx, entry.text); err != nil {
+				log.Error().Err(err).Msg("发送摘要中的消息失败")
+			}
+		}
+		return
+	}
+
+	merged := make([]context.Context, len(entries))
+	for i, entry := range entries {
+		merged[i] = entry.ctx
+	}
+	send := &Send{Target: TargetWebhook, Name: d.client.name, Request: req, Merged: merged}
+	if _, err := d.client.sendWebhook(entries[0].ctx, send); err != nil {
+		log.Error().Err(err).Int("messages", len(entries)).Msg("发送摘要消息失败")
+		return
+	}
+	log.Info().Int("messages", len(entries)).Str("format", string(d.format)).Msg("摘要消息已发送")
+}
+
+// build 构建摘要消息，只有一条时按原文本消息发送
+func (d *Digest) build(entries []digestEntry) (*types.FeishuWebhookRequest, error) {
+	if len(entries) == 1 {
+		return d.client.messageBuilder.BuildTextMessage(entries[0].text)
+	}
+
+	first := entries[0].time.Format(digestTimeFormat)
+	last := entries[len(entries)-1].time.Format(digestTimeFormat)
+
+	if d.format == types.DigestFormatCard {
+		header := feishumsg.NewHeader(fmt.Sprintf("消息摘要（%d条）", len(entries))).
+			WithSubtitle(first + " - " + last).
+			WithTemplate(feishumsg.TemplateBlue)
+		card := feishumsg.NewCard().WithHeader(header)
+		for _, entry := range entries {
+			card.Add(feishumsg.Div(feishumsg.PlainText(fmt.Sprintf("[%s] %s", entry.time.Format(digestTimeFormat), entry.text))))
+		}
+		card.Add(feishumsg.Note(feishumsg.PlainText(fmt.Sprintf("共%d条，%s - %s", len(entries), first, last))))
+		return d.client.messageBuilder.BuildMessage(card)
+	}
+
+	post := feishumsg.NewPost().Title(fmt.Sprintf("消息摘要（%d条，%s - %s）", len(entries), first, last))
+	for _, entry := range entries {
+		post.Paragraph(feishumsg.Text(fmt.Sprintf("[%s] %s", entry.time.Format(digestTimeFormat), entry.text)))
+	}
+	return d.client.messageBuilder.BuildMessage(post)
+}
+
+// Close 发送窗口内剩余的消息并等待发送完成，之后加入的消息返回错误
+func (d *Digest) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.flushLocked()
+	d.mu.Unlock()
+
+	d.sending.Wait()
+}
@@ -0,0 +1,127 @@
+package feishu
+
+import (
+	"context"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"testing"
+)
+
+type callerKey struct{}
+
+func TestDigestMergedSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      types.DigestConfig
+		texts       []string
+		wantMsgType string
+	}{
+		{"富文本", types.DigestConfig{WindowSeconds: 60}, []string{"告警1", "告警2"}, "post"},
+		{"卡片", types.DigestConfig{WindowSeconds: 60, Format: "card"}, []string{"告警1", "告警2"}, "interactive"},
+		{"单条按原文本发送", types.DigestConfig{WindowSeconds: 60}, []string{"告警1"}, "text"},
+		{"达到合并上限立即发送", types.DigestConfig{WindowSeconds: 60, MaxMessages: 3}, []string{"告警1", "告警2", "告警3"}, "post"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mock := feishutest.Start(feishutest.Options{})
+			defer mock.Close()
+
+			client := NewClient(types.FeishuConfig{WebhookURL: mock.URL(), Digest: tt.config})
+			hooks := &recordingHooks{}
+			client.SetHooks(hooks)
+
+			for i, text := range tt.texts {
+				// 工具调用返回后ctx被取消，摘要仍然按加入时的调用方报告结果
+				ctx, cancel := context.WithCancel(context.WithValue(context.Background(), callerKey{}, i))
+				if _, _, err := client.Digest().Add(ctx, text); err != nil {
+					t.Fatalf("Add(%q) error = %v", text, err)
+				}
+				cancel()
+			}
+			client.Close()
+
+			messages := mock.Messages()
+			if len(messages) != 1 || messages[0].MsgType != tt.wantMsgType {
+				t.Fatalf("收到的消息 = %+v, want 1条%s", messages, tt.wantMsgType)
+			}
+			if len(hooks.sends) != 1 {
+				t.Fatalf("钩子记录了%d次发送，want 1", len(hooks.sends))
+			}
+			send := hooks.sends[0]
+			if send.Err != nil || len(send.Merged) != len(tt.texts) {
+				t.Fatalf("发送 = %+v, want %d条合并的消息", send, len(tt.texts))
+			}
+			for i, ctx := range send.Merged {
+				if got := ctx.Value(callerKey{}); got != i {
+					t.Errorf("Merged[%d]的调用方 = %v, want %d", i, got, i)
+				}
+			}
+			if hooks.queued != 0 {
+				t.Errorf("等待发送的消息数 = %d, want 0", hooks.queued)
+			}
+		})
+	}
+}
+
+func TestTargetDigest(t *testing.T) {
+	t.Parallel()
+	mock := feishutest.Start(feishutest.Options{})
+	defer mock.Close()
+
+	client := NewClient(types.FeishuConfig{
+		WebhookURL: mock.URL(),
+		Targets: []types.WebhookTarget{
+			{Name: "alerts", WebhookURL: mock.URL(), Digest: types.DigestConfig{WindowSeconds: 60}},
+			{Name: "release", WebhookURL: mock.URL()},
+		},
+	})
+	hooks := &recordingHooks{}
+	client.SetHooks(hooks)
+
+	if client.Digest() != nil {
+		t.Error("默认Webhook未配置摘要模式，Digest()应为nil")
+	}
+	if release, _ := client.Target("release"); release.Digest() != nil {
+		t.Error("release未配置摘要模式，Digest()应为nil")
+	}
+	alerts, _ := client.Target("alerts")
+	for _, text := range []string{"告警1", "告警2"} {
+		if _, _, err := alerts.Digest().Add(context.Background(), text); err != nil {
+			t.Fatalf("Add(%q) error = %v", text, err)
+		}
+	}
+	// 关闭默认客户端时发送命名目标摘要中剩余的消息
+	client.Close()
+
+	if len(hooks.sends) != 1 || hooks.sends[0].Name != "alerts" || len(hooks.sends[0].Merged) != 2 {
+		t.Fatalf("发送 = %+v, want alerts的1条摘要", hooks.sends)
+	}
+}
+
+func TestDigestStaleTimer(t *testing.T) {
+	t.Parallel()
+	mock := feishutest.Start(feishutest.Options{})
+	defer mock.Close()
+
+	client := NewClient(types.FeishuConfig{WebhookURL: mock.URL(), Digest: types.DigestConfig{WindowSeconds: 60, MaxMessages: 2}})
+	defer client.Close()
+	digest := client.Digest()
+
+	// 第一个窗口的定时器已经触发，但在达到合并上限提前发送之后才拿到锁
+	stale := digest.generation
+	for _, text := range []string{"告警1", "告警2", "告警3"} {
+		if _, _, err := digest.Add(context.Background(), text); err != nil {
+			t.Fatalf("Add(%q) error = %v", text, err)
+		}
+	}
+	digest.flushWindow(stale)
+
+	digest.mu.Lock()
+	pending := len(digest.entries)
+	digest.mu.Unlock()
+	if pending != 1 {
+		t.Errorf("过期的定时器触发后窗口内剩余%d条消息, want 1", pending)
+	}
+}
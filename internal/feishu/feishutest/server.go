@@ -0,0 +1,400 @@
+// Package feishutest 提供模拟飞书自定义机器人Webhook的本地服务器，
+// 用于在没有真实机器人的情况下验证feishu.Client发出的请求以及离线开发。
+//
+// 服务器按飞书的规则校验访问令牌、请求体大小、签名和关键词，执行频率限制，
+// 返回与飞书一致的错误码，并记录收到的每个请求供断言使用。
+package feishutest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HookPath Webhook地址的路径前缀，后接访问令牌
+const HookPath = "/open-apis/bot/v2/hook/"
+
+// AdminPath 查询和清空请求记录的管理接口，GET返回记录，DELETE清空记录
+const AdminPath = "/_mock/requests"
+
+// 飞书自定义机器人的限制
+const (
+	DefaultMaxBodyBytes = 20 * 1024 // 请求体不超过20KB
+	DefaultPerSecond    = 5         // 每秒最多5次
+	DefaultPerMinute    = 100       // 每分钟最多100次
+	SignatureWindow     = time.Hour // 签名时间戳与当前时间相差不超过1小时
+)
+
+// 飞书返回的错误码
+const (
+	CodeSuccess        = 0
+	CodeBadRequest     = 9499
+	CodeRateLimited    = 11232
+	CodeTokenInvalid   = 19001
+	CodeParamsError    = 19002
+	CodeSignMismatch   = 19021
+	CodeKeywordMissing = 19024
+)
+
+// 飞书返回的错误信息
+const (
+	msgSuccess        = "success"
+	msgBadRequest     = "Bad Request"
+	msgBodyTooLarge   = "request body too large, limit is 20KB"
+	msgRateLimited    = "frequency limited psm"
+	msgTokenInvalid   = "param invalid: incoming webhook access token invalid"
+	msgMsgTypeMissing = "params error, msg_type need"
+	msgMsgTypeInvalid = "params error, msg_type invalid"
+	msgContentMissing = "params error, content need"
+	msgSignMismatch   = "sign match fail or timestamp is not within one hour from current time"
+	msgKeywordMissing = "Key Words Not Found"
+)
+
+// msgTypes 飞书支持的消息类型
+var msgTypes = map[string]bool{
+	"text":        true,
+	"post":        true,
+	"image":       true,
+	"interactive": true,
+	"share_chat":  true,
+}
+
+// Options 模拟服务器选项
+type Options struct {
+	Token    string   // 访问令牌，为空时接受任意令牌
+	Secret   string   // 签名密钥，非空时校验签名
+	Keywords []string // 自定义关键词，非空时要求消息包含其中之一
+
+	MaxBodyBytes     int  // 请求体大小上限，0表示使用默认的20KB
+	PerSecond        int  // 每秒请求上限，0表示使用默认值
+	PerMinute        int  // 每分钟请求上限，0表示使用默认值
+	DisableRateLimit bool // 关闭频率限制
+
+	Now       func() time.Time // 当前时间，默认time.Now，用于测试时间戳过期
+	OnRequest func(Request)    // 每个请求处理完成后回调，可用于输出日志
+}
+
+// Request 收到的请求及返回结果
+type Request struct {
+	Token      string                 `json:"token"`
+	MsgType    string                 `json:"msg_type"`
+	Content    map[string]interface{} `json:"content,omitempty"` // content或card字段
+	Timestamp  string                 `json:"timestamp,omitempty"`
+	Sign       string                 `json:"sign,omitempty"`
+	Body       string                 `json:"body"`
+	Code       int                    `json:"code"` // 返回的错误码，0表示成功
+	Msg        string                 `json:"msg"`
+	ReceivedAt time.Time              `json:"received_at"`
+}
+
+// failure 预设的失败响应
+type failure struct {
+	code int
+	msg  string
+}
+
+// Server 模拟飞书自定义机器人Webhook的HTTP处理器
+type Server struct {
+	opts Options
+
+	mu       sync.Mutex
+	requests []Request
+	accepted []time.Time // 通过频率限制的请求时间，用于滑动窗口计数
+	failures []failure
+
+	httpServer *httptest.Server
+}
+
+// NewServer 创建模拟服务器，可作为http.Handler挂载到任意监听地址
+func NewServer(opts Options) *Server {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	if opts.PerSecond <= 0 {
+		opts.PerSecond = DefaultPerSecond
+	}
+	if opts.PerMinute <= 0 {
+		opts.PerMinute = DefaultPerMinute
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Server{opts: opts}
+}
+
+// Start 创建模拟服务器并在随机的本地端口上启动，使用完毕后调用Close
+func Start(opts Options) *Server {
+	s := NewServer(opts)
+	s.httpServer = httptest.NewServer(s)
+	return s
+}
+
+// URL 返回可直接用作webhook_url的地址，仅在通过Start启动时可用
+func (s *Server) URL() string {
+	if s.httpServer == nil {
+		return ""
+	}
+	token := s.opts.Token
+	if token == "" {
+		token = "mock-token"
+	}
+	return s.httpServer.URL + HookPath + token
+}
+
+// Close 关闭通过Start启动的服务器
+func (s *Server) Close() {
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+}
+
+// Requests 返回收到的全部请求，包括被拒绝的请求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// Messages 返回成功接收的消息
+func (s *Server) Messages() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var messages []Request
+	for _, req := range s.requests {
+		if req.Code == CodeSuccess {
+			messages = append(messages, req)
+		}
+	}
+	return messages
+}
+
+// Reset 清空请求记录、频率限制计数和预设的失败响应
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = nil
+	s.accepted = nil
+	s.failures = nil
+}
+
+// FailNext 让接下来的一个请求返回指定的错误码，可多次调用依次生效，用于测试错误处理
+func (s *Server) FailNext(code int, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, failure{code: code, msg: msg})
+}
+
+// ServeHTTP 处理Webhook请求和管理接口请求
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == AdminPath {
+		s.serveAdmin(w, r)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, HookPath) {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := s.handle(r)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.mu.Unlock()
+
+	if s.opts.OnRequest != nil {
+		s.opts.OnRequest(req)
+	}
+
+	// 飞书对业务错误同样返回HTTP 200，错误信息在code和msg中
+	resp := map[string]interface{}{
+		"code": req.Code,
+		"msg":  req.Msg,
+		"data": map[string]interface{}{},
+	}
+	if req.Code == CodeSuccess {
+		resp["StatusCode"] = 0
+		resp["StatusMessage"] = msgSuccess
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handle 按飞书的顺序依次校验令牌、请求体、频率、消息类型、签名和关键词
+func (s *Server) handle(r *http.Request) Request {
+	req := Request{
+		Token:      strings.TrimPrefix(r.URL.Path, HookPath),
+		ReceivedAt: s.opts.Now(),
+	}
+	reject := func(code int, msg string) Request {
+		req.Code = code
+		req.Msg = msg
+		return req
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(s.opts.MaxBodyBytes)+1))
+	if err != nil {
+		return reject(CodeBadRequest, msgBadRequest)
+	}
+	req.Body = string(body)
+
+	if req.Token == "" || (s.opts.Token != "" && req.Token != s.opts.Token) {
+		return reject(CodeTokenInvalid, msgTokenInvalid)
+	}
+	if len(body) > s.opts.MaxBodyBytes {
+		return reject(CodeBadRequest, msgBodyTooLarge)
+	}
+	if !s.allow(req.ReceivedAt) {
+		return reject(CodeRateLimited, msgRateLimited)
+	}
+	if f, ok := s.nextFailure(); ok {
+		return reject(f.code, f.msg)
+	}
+
+	var payload struct {
+		MsgType   string                 `json:"msg_type"`
+		Content   map[string]interface{} `json:"content"`
+		Card      map[string]interface{} `json:"card"`
+		Timestamp json.RawMessage        `json:"timestamp"`
+		Sign      string                 `json:"sign"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return reject(CodeBadRequest, msgBadRequest)
+	}
+	req.MsgType = payload.MsgType
+	req.Sign = payload.Sign
+	// 飞书文档中timestamp为字符串，同时兼容数字形式
+	req.Timestamp = strings.Trim(string(payload.Timestamp), `"`)
+	// 卡片消息放在card字段中，同时兼容放在content中的写法
+	req.Content = payload.Content
+	if payload.Card != nil {
+		req.Content = payload.Card
+	}
+
+	if req.MsgType == "" {
+		return reject(CodeParamsError, msgMsgTypeMissing)
+	}
+	if !msgTypes[req.MsgType] {
+		return reject(CodeParamsError, msgMsgTypeInvalid)
+	}
+	if req.Content == nil {
+		return reject(CodeParamsError, msgContentMissing)
+	}
+
+	if s.opts.Secret != "" && !s.validSignature(req.Timestamp, req.Sign, req.ReceivedAt) {
+		return reject(CodeSignMismatch, msgSignMismatch)
+	}
+	if len(s.opts.Keywords) > 0 && !containsKeyword(messageText(req.MsgType, req.Content), s.opts.Keywords) {
+		return reject(CodeKeywordMissing, msgKeywordMissing)
+	}
+
+	return reject(CodeSuccess, msgSuccess)
+}
+
+// allow 按每秒和每分钟两个滑动窗口执行频率限制
+func (s *Server) allow(now time.Time) bool {
+	if s.opts.DisableRateLimit {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 只保留最近一分钟内的请求
+	kept := s.accepted[:0]
+	for _, t := range s.accepted {
+		if now.Sub(t) < time.Minute {
+			kept = append(kept, t)
+		}
+	}
+	s.accepted = kept
+
+	inSecond := 0
+	for _, t := range s.accepted {
+		if now.Sub(t) < time.Second {
+			inSecond++
+		}
+	}
+	if inSecond >= s.opts.PerSecond || len(s.accepted) >= s.opts.PerMinute {
+		return false
+	}
+
+	s.accepted = append(s.accepted, now)
+	return true
+}
+
+// nextFailure 取出一个预设的失败响应
+func (s *Server) nextFailure() (failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.failures) == 0 {
+		return failure{}, false
+	}
+	f := s.failures[0]
+	s.failures = s.failures[1:]
+	return f, true
+}
+
+// validSignature 校验签名和时间戳：以 timestamp + "\n" + secret 为密钥对空内容做HMAC-SHA256
+func (s *Server) validSignature(timestamp, sign string, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sign == "" {
+		return false
+	}
+
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff > SignatureWindow || diff < -SignatureWindow {
+		return false
+	}
+
+	h := hmac.New(sha256.New, []byte(fmt.Sprintf("%d\n%s", ts, s.opts.Secret)))
+	expected := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(sign))
+}
+
+// containsKeyword 任意一段文本是否包含任意一个关键词，关键词不跨段匹配
+func containsKeyword(texts []string, keywords []string) bool {
+	for _, text := range texts {
+		for _, keyword := range keywords {
+			if keyword != "" && strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveAdmin 查询或清空请求记录
+func (s *Server) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]interface{}{"requests": s.Requests()})
+	case http.MethodDelete:
+		s.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
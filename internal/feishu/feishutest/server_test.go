@@ -0,0 +1,73 @@
+package feishutest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestKeywordCheck 按飞书的消息格式逐项确认关键词校验的范围：可见文本中的关键词放行，
+// 只出现在链接、回调数据、图片key等不可见字段中的关键词返回19024
+func TestKeywordCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    int
+	}{
+		{"文本正文", `{"msg_type":"text","content":{"text":"KW 发布完成"}}`, CodeSuccess},
+		{"文本缺少关键词", `{"msg_type":"text","content":{"text":"发布完成"}}`, CodeKeywordMissing},
+
+		{"富文本标题", `{"msg_type":"post","content":{"post":{"zh_cn":{"title":"KW 周报","content":[]}}}}`, CodeSuccess},
+		{"富文本text元素", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"text","text":"含KW"}]]}}}}`, CodeSuccess},
+		{"富文本链接文本", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"a","text":"KW","href":"https://example.com"}]]}}}}`, CodeSuccess},
+		{"富文本@名字", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"at","user_id":"ou_1","user_name":"KW"}]]}}}}`, CodeSuccess},
+		{"富文本代码块", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"code_block","language":"GO","text":"// KW"}]]}}}}`, CodeSuccess},
+		{"富文本其他语言", `{"msg_type":"post","content":{"post":{"zh_cn":{"title":"周报"},"en_us":{"title":"KW report"}}}}`, CodeSuccess},
+		{"富文本只在链接地址中", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"a","text":"详情","href":"https://example.com/KW"}]]}}}}`, CodeKeywordMissing},
+		{"富文本只在@的user_id中", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"at","user_id":"KW"}]]}}}}`, CodeKeywordMissing},
+		{"富文本只在图片key中", `{"msg_type":"post","content":{"post":{"zh_cn":{"content":[[{"tag":"img","image_key":"img_KW"}]]}}}}`, CodeKeywordMissing},
+
+		{"卡片标题", `{"msg_type":"interactive","card":{"header":{"title":{"tag":"plain_text","content":"KW 告警"}}}}`, CodeSuccess},
+		{"卡片多语言标题", `{"msg_type":"interactive","card":{"header":{"title":{"tag":"plain_text","content":"告警","i18n":{"en_us":"KW alert"}}}}}`, CodeSuccess},
+		{"卡片div文本", `{"msg_type":"interactive","card":{"elements":[{"tag":"div","text":{"tag":"lark_md","content":"**KW**"}}]}}`, CodeSuccess},
+		{"卡片div字段", `{"msg_type":"interactive","card":{"elements":[{"tag":"div","fields":[{"is_short":true,"text":{"tag":"lark_md","content":"KW"}}]}]}}`, CodeSuccess},
+		{"卡片markdown", `{"msg_type":"interactive","card":{"elements":[{"tag":"markdown","content":"KW"}]}}`, CodeSuccess},
+		{"卡片备注", `{"msg_type":"interactive","card":{"elements":[{"tag":"note","elements":[{"tag":"plain_text","content":"KW"}]}]}}`, CodeSuccess},
+		{"卡片按钮文本", `{"msg_type":"interactive","card":{"elements":[{"tag":"action","actions":[{"tag":"button","text":{"tag":"plain_text","content":"KW"}}]}]}}`, CodeSuccess},
+		{"卡片多列", `{"msg_type":"interactive","card":{"elements":[{"tag":"column_set","columns":[{"tag":"column","elements":[{"tag":"markdown","content":"KW"}]}]}]}}`, CodeSuccess},
+		{"卡片多语言组件", `{"msg_type":"interactive","card":{"i18n_elements":{"en_us":[{"tag":"markdown","content":"KW"}]}}}`, CodeSuccess},
+		{"卡片放在content中", `{"msg_type":"interactive","content":{"header":{"title":{"tag":"plain_text","content":"KW"}}}}`, CodeSuccess},
+		{"卡片只在按钮回调数据中", `{"msg_type":"interactive","card":{"elements":[{"tag":"action","actions":[{"tag":"button","text":{"tag":"plain_text","content":"确认"},"value":{"key":"KW"}}]}]}}`, CodeKeywordMissing},
+		{"卡片只在按钮链接中", `{"msg_type":"interactive","card":{"elements":[{"tag":"action","actions":[{"tag":"button","text":{"tag":"plain_text","content":"打开"},"url":"https://example.com/KW"}]}]}}`, CodeKeywordMissing},
+		{"卡片只在标题颜色中", `{"msg_type":"interactive","card":{"header":{"template":"KW","title":{"tag":"plain_text","content":"告警"}}}}`, CodeKeywordMissing},
+
+		{"图片消息", `{"msg_type":"image","content":{"image_key":"img_KW"}}`, CodeKeywordMissing},
+	}
+
+	server := NewServer(Options{Keywords: []string{"KW"}, DisableRateLimit: true})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := post(t, server, tt.payload); got != tt.want {
+				t.Errorf("code = %d, want %d\npayload: %s", got, tt.want, tt.payload)
+			}
+		})
+	}
+}
+
+// post 向服务器发送一个Webhook请求，返回飞书错误码
+func post(t *testing.T, server *Server, payload string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, HookPath+"token", strings.NewReader(payload))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	var resp struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v: %s", err, rec.Body.String())
+	}
+	return resp.Code
+}
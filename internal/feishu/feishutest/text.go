@@ -0,0 +1,144 @@
+package feishutest
+
+// 本文件按飞书消息格式逐类型提取关键词校验的文本范围，
+// 刻意不复用客户端在internal/feishu中的提取逻辑，使客户端的提取逻辑出错时能在模拟服务器上暴露出来。
+
+// messageText 返回消息中参与关键词校验的全部文本
+func messageText(msgType string, content map[string]interface{}) []string {
+	switch msgType {
+	case "text":
+		return nonEmpty(stringField(content, "text"))
+	case "post":
+		return postText(content)
+	case "interactive":
+		return cardText(content)
+	default:
+		// 图片和群名片消息没有文本
+		return nil
+	}
+}
+
+// postText 富文本：每种语言的标题以及text、a、at、code_block元素的文本
+//
+// 格式为 {"post": {"zh_cn": {"title": "...", "content": [[{"tag": "text", "text": "..."}]]}}}
+func postText(content map[string]interface{}) []string {
+	var texts []string
+	for _, body := range objectField(content, "post") {
+		locale, ok := body.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		texts = append(texts, nonEmpty(stringField(locale, "title"))...)
+		for _, paragraph := range arrayField(locale, "content") {
+			line, _ := paragraph.([]interface{})
+			for _, item := range line {
+				element, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				switch stringField(element, "tag") {
+				case "text", "a", "code_block":
+					texts = append(texts, nonEmpty(stringField(element, "text"))...)
+				case "at":
+					texts = append(texts, nonEmpty(stringField(element, "user_name"))...)
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// cardText 卡片：标题栏的标题和副标题，elements和i18n_elements中各组件的文本
+func cardText(card map[string]interface{}) []string {
+	var texts []string
+	if header, ok := card["header"].(map[string]interface{}); ok {
+		texts = append(texts, textObject(header["title"])...)
+		texts = append(texts, textObject(header["subtitle"])...)
+	}
+	texts = append(texts, cardElements(arrayField(card, "elements"))...)
+	for _, elements := range objectField(card, "i18n_elements") {
+		list, _ := elements.([]interface{})
+		texts = append(texts, cardElements(list)...)
+	}
+	return texts
+}
+
+// cardElements 提取卡片组件列表的文本，未知的组件不含文本
+func cardElements(elements []interface{}) []string {
+	var texts []string
+	for _, item := range elements {
+		element, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch stringField(element, "tag") {
+		case "plain_text", "lark_md":
+			texts = append(texts, textObject(element)...)
+		case "markdown":
+			texts = append(texts, nonEmpty(stringField(element, "content"))...)
+		case "div":
+			texts = append(texts, textObject(element["text"])...)
+			for _, field := range arrayField(element, "fields") {
+				if f, ok := field.(map[string]interface{}); ok {
+					texts = append(texts, textObject(f["text"])...)
+				}
+			}
+			if extra, ok := element["extra"]; ok {
+				texts = append(texts, cardElements([]interface{}{extra})...)
+			}
+		case "button":
+			// 按钮的url和回调数据value不展示给用户
+			texts = append(texts, textObject(element["text"])...)
+		case "img":
+			texts = append(texts, textObject(element["alt"])...)
+		case "action":
+			texts = append(texts, cardElements(arrayField(element, "actions"))...)
+		case "note":
+			texts = append(texts, cardElements(arrayField(element, "elements"))...)
+		case "column_set":
+			for _, column := range arrayField(element, "columns") {
+				if c, ok := column.(map[string]interface{}); ok {
+					texts = append(texts, cardElements(arrayField(c, "elements"))...)
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// textObject 卡片文本对象 {"tag": "plain_text", "content": "...", "i18n": {"en_us": "..."}} 的全部文本
+func textObject(value interface{}) []string {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	texts := nonEmpty(stringField(object, "content"))
+	for _, text := range objectField(object, "i18n") {
+		if s, ok := text.(string); ok {
+			texts = append(texts, nonEmpty(s)...)
+		}
+	}
+	return texts
+}
+
+func stringField(object map[string]interface{}, key string) string {
+	s, _ := object[key].(string)
+	return s
+}
+
+func arrayField(object map[string]interface{}, key string) []interface{} {
+	a, _ := object[key].([]interface{})
+	return a
+}
+
+func objectField(object map[string]interface{}, key string) map[string]interface{} {
+	m, _ := object[key].(map[string]interface{})
+	return m
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
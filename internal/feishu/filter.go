@@ -0,0 +1,181 @@
+package feishu
+
+import (
+	"encoding/json"
+	"fmt"
+	"mcp-feishu/internal/redact"
+	"mcp-feishu/internal/types"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ContentFilter 内容过滤器，在消息构建完成后、安全处理（关键词、签名）之前执行
+type ContentFilter interface {
+	// Filter 检查消息内容，可以就地修改其中的文本，返回错误时拒绝发送
+	Filter(content interface{}) error
+}
+
+// linkTextKeys 除可见文本外还需要检查的链接字段，链接中常带有令牌：富文本a元素的href、卡片按钮和链接的url
+var linkTextKeys = []string{"href", "url"}
+
+// filterRule 一条过滤规则：检测项及命中后的处理动作
+type filterRule struct {
+	redact.Detector
+	action types.FilterAction
+}
+
+// PolicyFilter 按内置检测项和自定义正则规则过滤消息内容
+type PolicyFilter struct {
+	rules []filterRule
+}
+
+// NewPolicyFilter 根据配置创建过滤器，未启用任何规则时返回nil
+func NewPolicyFilter(config types.ContentFilterConfig) (*PolicyFilter, error) {
+	var rules []filterRule
+	builtins := []struct {
+		action   string
+		detector redact.Detector
+	}{
+		{config.Credentials, redact.Credentials},
+		{config.IDCard, redact.IDCard},
+		{config.Phone, redact.Phone},
+		{config.Email, redact.Email},
+	}
+	for _, builtin := range builtins {
+		if builtin.action == "" {
+			continue
+		}
+		action, err := parseFilterAction(builtin.action)
+		if err != nil {
+			return nil, fmt.Errorf("内容过滤%s: %w", builtin.detector.Name, err)
+		}
+		rules = append(rules, filterRule{Detector: builtin.detector, action: action})
+	}
+
+	for i, rule := range config.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("内容过滤规则%d缺少名称", i)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("内容过滤规则%s的正则表达式无效: %w", rule.Name, err)
+		}
+		action, err := parseFilterAction(rule.Action)
+		if err != nil {
+			return nil, fmt.Errorf("内容过滤规则%s: %w", rule.Name, err)
+		}
+		rules = append(rules, filterRule{Detector: redact.Detector{Name: rule.Name, Pattern: pattern}, action: action})
+	}
+
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return &PolicyFilter{rules: rules}, nil
+}
+
+// parseFilterAction 解析处理动作
+func parseFilterAction(action string) (types.FilterAction, error) {
+	switch types.FilterAction(action) {
+	case types.FilterActionBlock, types.FilterActionRedact, types.FilterActionWarn:
+		return types.FilterAction(action), nil
+	default:
+		return "", fmt.Errorf("不支持的处理动作: %s，可选值为block、redact、warn", action)
+	}
+}
+
+// Filter 检查消息中的可见文本和链接，命中block规则时返回错误，命中redact规则时替换匹配内容
+//
+// 错误信息和日志只包含规则名称，不包含命中的内容本身。
+func (f *PolicyFilter) Filter(content interface{}) error {
+	blocked := make(map[string]bool)
+	rewriteContentText(content, func(text string) string {
+		for _, rule := range f.rules {
+			matches := rule.Matches(text)
+			if len(matches) == 0 {
+				continue
+			}
+			switch rule.action {
+			case types.FilterActionBlock:
+				blocked[rule.Name] = true
+			case types.FilterActionRedact:
+				text = rule.Replace(text)
+				log.Info().Str("rule", rule.Name).Int("matches", len(matches)).Msg("消息内容已脱敏")
+			case types.FilterActionWarn:
+				log.Warn().Str("rule", rule.Name).Int("matches", len(matches)).Msg("消息包含敏感内容，按warn策略继续发送")
+			}
+		}
+		return text
+	})
+
+	if len(blocked) > 0 {
+		names := make([]string, 0, len(blocked))
+		for name := range blocked {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("消息包含敏感内容(%s)，已阻止发送", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// errorFilter 过滤器配置无效时拒绝所有消息，避免在过滤失效的情况下发出敏感内容
+type errorFilter struct {
+	err error
+}
+
+func (f errorFilter) Filter(content interface{}) error {
+	return f.err
+}
+
+// rewriteContentText 对消息中的可见文本和链接逐个调用rewrite并写回
+func rewriteContentText(content interface{}, rewrite func(string) string) {
+	switch v := content.(type) {
+	case *types.TextMessage:
+		v.Text = rewrite(v.Text)
+	case *types.PostMessage:
+		if post, ok := toGeneric(v.Post).(map[string]interface{}); ok {
+			v.Post = post
+		}
+		rewriteGenericText(v.Post, rewrite)
+	case *types.InteractiveMessage:
+		v.Header = toGeneric(v.Header)
+		rewriteGenericText(v.Header, rewrite)
+		for i, element := range v.Elements {
+			v.Elements[i] = toGeneric(element)
+			rewriteGenericText(v.Elements[i], rewrite)
+		}
+		for locale, elements := range v.I18nElements {
+			v.I18nElements[locale] = toGeneric(elements)
+			rewriteGenericText(v.I18nElements[locale], rewrite)
+		}
+	}
+	// 图片、群名片等消息不包含文本
+}
+
+// toGeneric 将值通过JSON转换为map/slice通用结构，以便就地修改
+//
+// map和slice同样转换一次：其中可能嵌套类型化的值（如CreatePostContent构建的[][]interface{}），
+// 遍历可见文本时只会进入通用结构；转换结果是副本，修改不会影响调用方传入的值。转换失败时返回原值。
+func toGeneric(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return value
+	}
+	return generic
+}
+
+// rewriteGenericText 递归改写通用结构中可见文本和链接字段的字符串值，
+// 检查范围与关键词校验的可见文本一致，另外包含链接
+func rewriteGenericText(value interface{}, rewrite func(string) string) {
+	rewriteText(value, linkTextKeys, rewrite)
+}
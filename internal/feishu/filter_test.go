@@ -0,0 +1,149 @@
+package feishu
+
+import (
+	"encoding/json"
+	"mcp-feishu/internal/redact"
+	"mcp-feishu/internal/types"
+	"strings"
+	"testing"
+)
+
+const filterTestPhone = "13812345678"
+
+// TestPolicyFilter 按block、redact、warn三种处理动作检查各类消息内容中的全部可见文本
+func TestPolicyFilter(t *testing.T) {
+	text := "请联系 " + filterTestPhone
+	contents := []struct {
+		name  string
+		build func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error)
+	}{
+		{"文本", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildTextMessage(text)
+		}},
+		{"类型化富文本", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildRichTextMessage(CreatePostContent(CreateTextElement(text)))
+		}},
+		{"通用富文本", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			var post map[string]interface{}
+			if err := json.Unmarshal([]byte(`{"zh_cn":{"title":"周报","content":[[{"tag":"text","text":"`+text+`"}]]}}`), &post); err != nil {
+				return nil, err
+			}
+			return mb.BuildRichTextMessage(post)
+		}},
+		{"富文本其他语言", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildPostMessage(map[types.Locale]types.PostLocale{
+				"zh_cn": {Title: "周报", Content: [][]interface{}{{CreateTextElement("见英文版")}}},
+				"en_us": {Title: "Report", Content: [][]interface{}{{CreateTextElement(text)}}},
+			})
+		}},
+		{"富文本链接", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildRichTextMessage(CreatePostContent(CreateLinkElement("详情", "https://example.com/?phone="+filterTestPhone)))
+		}},
+		{"卡片", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildInteractiveMessage(nil, []interface{}{CreateDivElement(text)}, CreateCardHeader("告警", "", "red"))
+		}},
+		{"卡片标题", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildInteractiveMessage(nil, []interface{}{CreateDivElement("详情")}, CreateCardHeader(text, "", "red"))
+		}},
+		{"卡片多语言组件", func(mb *MessageBuilder) (*types.FeishuWebhookRequest, error) {
+			return mb.BuildCardMessage(&types.InteractiveMessage{
+				I18nElements: map[string]interface{}{
+					"zh_cn": []interface{}{CreateDivElement("详情")},
+					"en_us": []interface{}{CreateDivElement(text)},
+				},
+			})
+		}},
+	}
+
+	for _, action := range []types.FilterAction{types.FilterActionBlock, types.FilterActionRedact, types.FilterActionWarn} {
+		for _, content := range contents {
+			t.Run(string(action)+"/"+content.name, func(t *testing.T) {
+				filter, err := NewPolicyFilter(types.ContentFilterConfig{Phone: string(action)})
+				if err != nil {
+					t.Fatalf("NewPolicyFilter() error = %v", err)
+				}
+				mb := NewMessageBuilder(NewSecurityManager(nil, "", nil, ""))
+				mb.AddFilter(filter)
+
+				req, err := content.build(mb)
+				if action == types.FilterActionBlock {
+					if err == nil || !strings.Contains(err.Error(), "phone") {
+						t.Fatalf("error = %v, want 命中phone规则被阻止", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+
+				data, err := json.Marshal(req.Content)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body := string(data)
+				redacted := !strings.Contains(body, filterTestPhone) && strings.Contains(body, redact.Placeholder)
+				if redacted != (action == types.FilterActionRedact) {
+					t.Errorf("消息内容 = %s", body)
+				}
+			})
+		}
+	}
+}
+
+func TestPolicyFilterKeepsCallerContent(t *testing.T) {
+	filter, err := NewPolicyFilter(types.ContentFilterConfig{Phone: string(types.FilterActionRedact)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := NewMessageBuilder(NewSecurityManager(nil, "", nil, ""))
+	mb.AddFilter(filter)
+
+	element := CreateTextElement(filterTestPhone)
+	if _, err := mb.BuildRichTextMessage(CreatePostContent(element)); err != nil {
+		t.Fatal(err)
+	}
+	if element["text"] != filterTestPhone {
+		t.Errorf("调用方的元素被修改为 %q", element["text"])
+	}
+}
+
+func TestNewPolicyFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  types.ContentFilterConfig
+		wantNil bool
+		wantErr string
+	}{
+		{"未启用", types.ContentFilterConfig{}, true, ""},
+		{"内置检测项", types.ContentFilterConfig{Email: "redact"}, false, ""},
+		{"自定义规则", types.ContentFilterConfig{Rules: []types.ContentFilterRule{{Name: "ticket", Pattern: `JIRA-\d+`, Action: "warn"}}}, false, ""},
+		{"不支持的处理动作", types.ContentFilterConfig{Phone: "drop"}, true, "不支持的处理动作: drop"},
+		{"规则缺少名称", types.ContentFilterConfig{Rules: []types.ContentFilterRule{{Pattern: "x", Action: "block"}}}, true, "缺少名称"},
+		{"正则表达式无效", types.ContentFilterConfig{Rules: []types.ContentFilterRule{{Name: "bad", Pattern: "(", Action: "block"}}}, true, "正则表达式无效"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewPolicyFilter(tt.config)
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("NewPolicyFilter() error = %v, want %q", err, tt.wantErr)
+			}
+			if (filter == nil) != tt.wantNil {
+				t.Errorf("NewPolicyFilter() = %v, want nil: %v", filter, tt.wantNil)
+			}
+		})
+	}
+}
+
+// TestInvalidFilterConfig 过滤器配置无效时拒绝所有消息，而不是不经过滤直接发送
+func TestInvalidFilterConfig(t *testing.T) {
+	client := NewClient(types.FeishuConfig{
+		WebhookURL:    "https://open.feishu.cn/open-apis/bot/v2/hook/test",
+		ContentFilter: types.ContentFilterConfig{Phone: "drop"},
+	})
+	defer client.Close()
+
+	_, err := client.MessageBuilder().BuildTextMessage("发布完成")
+	if err == nil || !strings.Contains(err.Error(), "内容过滤配置无效") {
+		t.Errorf("BuildTextMessage() error = %v, want 内容过滤配置无效", err)
+	}
+}
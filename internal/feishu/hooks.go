@@ -0,0 +1,68 @@
+package feishu
+
+import (
+	"context"
+	"mcp-feishu/internal/types"
+	"time"
+)
+
+// 发送目标类型
+const (
+	TargetWebhook = "webhook" // 自定义机器人Webhook
+	TargetDirect  = "direct"  // 应用机器人私聊
+)
+
+// Hooks 发送消息前后的扩展点，由创建客户端的一方注入指标、链路追踪、审计日志和配额等处理，
+// 客户端本身不依赖任何进程级的全局状态
+type Hooks interface {
+	// BeforeSend 在请求发出前调用，返回的context用于本次请求和AfterSend，可以携带trace上下文
+	BeforeSend(ctx context.Context, send *Send) context.Context
+	// AfterSend 在得到发送结果后调用
+	AfterSend(ctx context.Context, send *Send)
+	// Queued 摘要中等待发送的消息数变化，加入摘要时delta为正，取出发送时为负
+	Queued(delta int)
+}
+
+// Send 一次发送的信息和结果
+type Send struct {
+	Target        string              // TargetWebhook或TargetDirect
+	Name          string              // 命名目标的名称，默认Webhook和私聊时为空
+	ReceiveIDType types.ReceiveIDType // 私聊接收者ID类型
+	Receiver      string              // 私聊接收者，如 email:someone@example.com
+	Request       *types.FeishuWebhookRequest
+
+	// Merged 摘要合并发送时各条消息加入摘要时的context，钩子应按这些context为每条消息记录结果，
+	// 而不是按发送时的context
+	Merged []context.Context
+
+	// 以下字段在AfterSend时有效
+	Duration time.Duration
+	Retries  int           // 重试次数，私聊消息的tenant_access_token失效时会刷新令牌重试一次；Webhook发送不重试
+	Response *SendResponse // 未收到飞书响应时为nil
+	Err      error
+}
+
+// SendResponse 飞书返回的结果
+type SendResponse struct {
+	Code      int
+	Message   string
+	MessageID string // 私聊消息的ID
+}
+
+// noHooks 未注入钩子时使用的空实现
+type noHooks struct{}
+
+func (noHooks) BeforeSend(ctx context.Context, send *Send) context.Context { return ctx }
+func (noHooks) AfterSend(ctx context.Context, send *Send)                  {}
+func (noHooks) Queued(delta int)                                           {}
+
+// SetHooks 设置发送钩子，同时作用于命名目标的客户端，传入nil时不执行任何处理
+func (c *Client) SetHooks(hooks Hooks) {
+	if hooks == nil {
+		hooks = noHooks{}
+	}
+	c.hooks = hooks
+	for _, target := range c.targets {
+		target.SetHooks(hooks)
+	}
+}
@@ -0,0 +1,74 @@
+package feishu
+
+import (
+	"context"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"sync"
+	"testing"
+)
+
+// recordingHooks 记录每次发送的测试钩子
+type recordingHooks struct {
+	mu     sync.Mutex
+	sends  []Send
+	queued int
+}
+
+type hookCtxKey struct{}
+
+func (h *recordingHooks) BeforeSend(ctx context.Context, send *Send) context.Context {
+	return context.WithValue(ctx, hookCtxKey{}, send.Target)
+}
+
+func (h *recordingHooks) AfterSend(ctx context.Context, send *Send) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ctx.Value(hookCtxKey{}) != send.Target {
+		panic("AfterSend没有收到BeforeSend返回的context")
+	}
+	h.sends = append(h.sends, *send)
+}
+
+func (h *recordingHooks) Queued(delta int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queued += delta
+}
+
+func TestClientHooks(t *testing.T) {
+	t.Parallel()
+	mock := feishutest.Start(feishutest.Options{})
+	defer mock.Close()
+
+	client := NewClient(types.FeishuConfig{
+		WebhookURL: mock.URL(),
+		Targets:    []types.WebhookTarget{{Name: "release", WebhookURL: mock.URL()}},
+	})
+	hooks := &recordingHooks{}
+	client.SetHooks(hooks)
+
+	mock.FailNext(feishutest.CodeSignMismatch, "sign match fail")
+	if _, err := client.SendTextMessage(context.Background(), "第一条"); err == nil {
+		t.Fatal("SendTextMessage() 应返回飞书错误")
+	}
+	release, _ := client.Target("release")
+	if _, err := release.SendTextMessage(context.Background(), "第二条"); err != nil {
+		t.Fatalf("命名目标 SendTextMessage() error = %v", err)
+	}
+
+	if len(hooks.sends) != 2 {
+		t.Fatalf("记录了%d次发送，want 2", len(hooks.sends))
+	}
+	failed, sent := hooks.sends[0], hooks.sends[1]
+	if failed.Target != TargetWebhook || failed.Name != "" || failed.Err == nil ||
+		failed.Response == nil || failed.Response.Code != feishutest.CodeSignMismatch {
+		t.Errorf("失败的发送 = %+v", failed)
+	}
+	if sent.Name != "release" || sent.Err != nil || sent.Response == nil || sent.Response.Code != 0 {
+		t.Errorf("命名目标的发送 = %+v", sent)
+	}
+	if sent.Request.MsgType != "text" {
+		t.Errorf("Request.MsgType = %s, want text", sent.Request.MsgType)
+	}
+}
@@ -0,0 +1,280 @@
+package feishu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// mentionAll @所有人使用的特殊user_id
+const mentionAll = "all"
+
+// mentionPattern 匹配文本中的@提及：@邮箱、@all 或 @名字
+// 第一个分组用于确保@前面不是单词字符，避免把普通邮箱地址当作提及
+var mentionPattern = regexp.MustCompile(`(^|[^\p{L}\p{N}_@.])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}|[\p{L}\p{N}_\-]+(?:\.[\p{L}\p{N}_\-]+)*)`)
+
+// DirectoryUser 通讯录中的用户
+type DirectoryUser struct {
+	Name    string   `json:"name"`
+	Email   string   `json:"email,omitempty"`
+	UserID  string   `json:"user_id"` // open_id 或 user_id
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Directory 用户目录，用于将名字或邮箱解析为飞书用户ID
+type Directory interface {
+	// Lookup 查找用户，未找到时返回nil和nil错误
+	Lookup(key string) (*DirectoryUser, error)
+}
+
+// StaticDirectory 基于静态映射文件的用户目录
+type StaticDirectory struct {
+	users map[string]*DirectoryUser
+}
+
+// LoadStaticDirectory 从JSON文件加载用户目录
+//
+// 文件格式：{"users": [{"name": "alice", "email": "alice@example.com", "user_id": "ou_xxx", "aliases": ["爱丽丝"]}]}
+func LoadStaticDirectory(path string) (*StaticDirectory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取用户目录文件失败: %w", err)
+	}
+
+	var file struct {
+		Users []DirectoryUser `json:"users"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析用户目录文件失败: %w", err)
+	}
+
+	dir := &StaticDirectory{users: make(map[string]*DirectoryUser)}
+	for i := range file.Users {
+		user := &file.Users[i]
+		if user.UserID == "" {
+			return nil, fmt.Errorf("用户目录第%d项缺少user_id", i+1)
+		}
+		for _, key := range append([]string{user.Name, user.Email}, user.Aliases...) {
+			if key == "" {
+				continue
+			}
+			key = strings.ToLower(key)
+			// 同一个名字对应多个用户时无法确定要@谁，直接拒绝加载
+			if existing, ok := dir.users[key]; ok && existing != user {
+				return nil, fmt.Errorf("用户目录第%d项的%q与%s重复", i+1, key, existing.UserID)
+			}
+			dir.users[key] = user
+		}
+	}
+
+	return dir, nil
+}
+
+// Lookup 按名字、邮箱或别名查找用户（不区分大小写）
+func (d *StaticDirectory) Lookup(key string) (*DirectoryUser, error) {
+	return d.users[strings.ToLower(key)], nil
+}
+
+// appDirectory 基于通讯录API的用户目录，仅支持邮箱查询
+type appDirectory struct {
+	appClient *AppClient
+}
+
+// Lookup 通过邮箱查询用户open_id，通讯录中不存在该邮箱时按未找到处理
+func (d *appDirectory) Lookup(key string) (*DirectoryUser, error) {
+	if !strings.Contains(key, "@") {
+		return nil, nil
+	}
+
+	openID, err := d.appClient.ResolveOpenIDByEmail(key)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DirectoryUser{Name: key, Email: key, UserID: openID}, nil
+}
+
+// MentionResolver 将文本中的@提及改写为飞书at标签
+type MentionResolver struct {
+	directories []Directory
+}
+
+// NewMentionResolver 创建提及解析器，按顺序查询各个目录
+func NewMentionResolver(directories ...Directory) *MentionResolver {
+	return &MentionResolver{directories: directories}
+}
+
+// mention 文本中解析出的一个提及
+type mention struct {
+	start, end int // 在原文本中的位置（不含前缀字符）
+	userID     string
+	userName   string
+}
+
+// findMentions 查找文本中的所有提及并解析为用户
+func (mr *MentionResolver) findMentions(text string) ([]mention, error) {
+	var mentions []mention
+
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, tokenStart, end := loc[4]-1, loc[4], loc[5]
+		token := text[tokenStart:end]
+
+		if strings.EqualFold(token, mentionAll) {
+			mentions = append(mentions, mention{start: start, end: end, userID: mentionAll, userName: "所有人"})
+			continue
+		}
+
+		// 没有配置任何目录时保持原文，兼容未启用提及解析的部署
+		if len(mr.directories) == 0 {
+			continue
+		}
+
+		user, matched, err := mr.lookup(token)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("无法解析@提及的用户: %s（请检查名字或邮箱是否在用户目录中）", token)
+		}
+
+		name := user.Name
+		if name == "" {
+			name = matched
+		}
+		mentions = append(mentions, mention{start: start, end: tokenStart + len(matched), userID: user.UserID, userName: name})
+	}
+
+	return mentions, nil
+}
+
+// lookup 查找用户，优先完整匹配；名字不是邮箱时再按最长前缀匹配，便于处理"@张三你好"这类中文文本
+//
+// 前缀只能在中日韩文字边界处截断，避免把"@bobby"解析为用户bob并留下"by"
+func (mr *MentionResolver) lookup(token string) (*DirectoryUser, string, error) {
+	candidates := []string{token}
+	if !strings.Contains(token, "@") {
+		runes := []rune(token)
+		for i := len(runes) - 1; i > 0; i-- {
+			if isCJK(runes[i-1]) || isCJK(runes[i]) {
+				candidates = append(candidates, string(runes[:i]))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		for _, dir := range mr.directories {
+			user, err := dir.Lookup(candidate)
+			if err != nil {
+				return nil, "", fmt.Errorf("解析@%s失败: %w", candidate, err)
+			}
+			if user != nil {
+				return user, candidate, nil
+			}
+		}
+	}
+
+	return nil, "", nil
+}
+
+// isCJK 判断字符是否为中日韩文字，这些文字的名字后面通常不加空格直接接正文
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// ResolveText 将文本消息中的@提及改写为 <at user_id="...">名字</at> 标签
+func (mr *MentionResolver) ResolveText(text string) (string, error) {
+	mentions, err := mr.findMentions(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range mentions {
+		sb.WriteString(text[last:m.start])
+		fmt.Fprintf(&sb, `<at user_id="%s">%s</at>`, m.userID, html.EscapeString(m.userName))
+		last = m.end
+	}
+	sb.WriteString(text[last:])
+
+	return sb.String(), nil
+}
+
+// ResolvePostContent 将富文本内容二维数组中text元素里的@提及拆分为at元素
+func (mr *MentionResolver) ResolvePostContent(content interface{}) (interface{}, error) {
+	rows, ok := content.([]interface{})
+	if !ok {
+		return content, nil
+	}
+
+	resolvedRows := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		elements, ok := row.([]interface{})
+		if !ok {
+			resolvedRows = append(resolvedRows, row)
+			continue
+		}
+
+		var resolved []interface{}
+		for _, element := range elements {
+			parts, err := mr.resolveElement(element)
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, parts...)
+		}
+		resolvedRows = append(resolvedRows, resolved)
+	}
+
+	return resolvedRows, nil
+}
+
+// resolveElement 拆分单个富文本元素，非text元素原样返回
+func (mr *MentionResolver) resolveElement(element interface{}) ([]interface{}, error) {
+	el, ok := element.(map[string]interface{})
+	if !ok || el["tag"] != "text" {
+		return []interface{}{element}, nil
+	}
+
+	text, _ := el["text"].(string)
+	mentions, err := mr.findMentions(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(mentions) == 0 {
+		return []interface{}{element}, nil
+	}
+
+	// 拆分后的文本片段保留原元素的样式等其他字段
+	textPart := func(s string) map[string]interface{} {
+		part := make(map[string]interface{}, len(el))
+		for k, v := range el {
+			part[k] = v
+		}
+		part["text"] = s
+		return part
+	}
+
+	var parts []interface{}
+	last := 0
+	for _, m := range mentions {
+		if m.start > last {
+			parts = append(parts, textPart(text[last:m.start]))
+		}
+		parts = append(parts, CreateAtElement(m.userID, m.userName))
+		last = m.end
+	}
+	if last < len(text) {
+		parts = append(parts, textPart(text[last:]))
+	}
+
+	return parts, nil
+}
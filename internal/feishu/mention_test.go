@@ -0,0 +1,166 @@
+package feishu
+
+import (
+	"encoding/json"
+	"mcp-feishu/internal/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadTestDirectory 将内容写入临时文件并通过LoadStaticDirectory加载
+func loadTestDirectory(t *testing.T, content string) (*StaticDirectory, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "directory.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadStaticDirectory(path)
+}
+
+func TestLoadStaticDirectory(t *testing.T) {
+	dir, err := loadTestDirectory(t, `{"users": [
+		{"name": "Bob", "email": "Bob@Example.com", "user_id": "ou_bob", "aliases": ["bob", "鲍勃"]},
+		{"name": "张三", "user_id": "ou_zhangsan"}
+	]}`)
+	if err != nil {
+		t.Fatalf("LoadStaticDirectory() error = %v", err)
+	}
+
+	for key, want := range map[string]string{
+		"bob":             "ou_bob",
+		"BOB":             "ou_bob",
+		"bob@example.com": "ou_bob",
+		"鲍勃":              "ou_bob",
+		"张三":              "ou_zhangsan",
+		"alice":           "",
+	} {
+		user, err := dir.Lookup(key)
+		if err != nil {
+			t.Fatalf("Lookup(%q) error = %v", key, err)
+		}
+		got := ""
+		if user != nil {
+			got = user.UserID
+		}
+		if got != want {
+			t.Errorf("Lookup(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestLoadStaticDirectoryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"非JSON", `users: []`, "解析用户目录文件失败"},
+		{"缺少user_id", `{"users": [{"name": "bob"}]}`, "用户目录第1项缺少user_id"},
+		{"名字重复", `{"users": [{"name": "bob", "user_id": "ou_bob"}, {"name": "Bob", "user_id": "ou_bob2"}]}`, `用户目录第2项的"bob"与ou_bob重复`},
+		{"别名与其他用户的名字重复", `{"users": [{"name": "bob", "user_id": "ou_bob"}, {"name": "robert", "aliases": ["bob"], "user_id": "ou_robert"}]}`, `用户目录第2项的"bob"与ou_bob重复`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestDirectory(t, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadStaticDirectory() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := LoadStaticDirectory(filepath.Join(t.TempDir(), "missing.json")); err == nil || !strings.Contains(err.Error(), "读取用户目录文件失败") {
+		t.Errorf("文件不存在 error = %v, want 读取用户目录文件失败", err)
+	}
+}
+
+func TestMentionResolverResolveText(t *testing.T) {
+	dir, err := loadTestDirectory(t, `{"users": [
+		{"name": "bob", "email": "bob@example.com", "user_id": "ou_bob"},
+		{"name": "张三", "user_id": "ou_zhangsan"}
+	]}`)
+	if err != nil {
+		t.Fatalf("LoadStaticDirectory() error = %v", err)
+	}
+	resolver := NewMentionResolver(dir)
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"名字", "请 @bob 看一下", `请 <at user_id="ou_bob">bob</at> 看一下`, ""},
+		{"邮箱", "@bob@example.com 你好", `<at user_id="ou_bob">bob</at> 你好`, ""},
+		{"所有人", "@all 发布完成", `<at user_id="all">所有人</at> 发布完成`, ""},
+		{"中文名后直接接正文", "@张三你好", `<at user_id="ou_zhangsan">张三</at>你好`, ""},
+		{"英文名后接中文", "@bob你好", `<at user_id="ou_bob">bob</at>你好`, ""},
+		{"英文名不按前缀截断", "@bobby 你好", "", "无法解析@提及的用户: bobby"},
+		{"普通邮箱地址不是提及", "联系 alice@example.com", "联系 alice@example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.ResolveText(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveText(%q) error = %v, want %q", tt.text, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveText(%q) error = %v", tt.text, err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestAppClient 启动模拟开放平台，通讯录中只有users中的邮箱
+func newTestAppClient(t *testing.T, users map[string]string) *AppClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/v3/tenant_access_token/internal":
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "t-test", "expire": 7200})
+		case "/contact/v3/users/batch_get_id":
+			var body struct {
+				Emails []string `json:"emails"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			var list []map[string]interface{}
+			for _, email := range body.Emails {
+				// 飞书对不存在的邮箱返回不带user_id的条目
+				list = append(list, map[string]interface{}{"email": email, "user_id": users[email]})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]interface{}{"user_list": list}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return NewAppClient(types.FeishuConfig{AppID: "cli_test", AppSecret: "secret", APIBaseURL: server.URL})
+}
+
+func TestMentionResolverAppDirectory(t *testing.T) {
+	resolver := NewMentionResolver(&appDirectory{appClient: newTestAppClient(t, map[string]string{
+		"alice@example.com": "ou_alice",
+	})})
+
+	got, err := resolver.ResolveText("@alice@example.com 请处理")
+	if err != nil {
+		t.Fatalf("ResolveText() error = %v", err)
+	}
+	if want := `<at user_id="ou_alice">alice@example.com</at> 请处理`; got != want {
+		t.Errorf("ResolveText() = %q, want %q", got, want)
+	}
+
+	_, err = resolver.ResolveText("@nobody@example.com 请处理")
+	if err == nil || !strings.Contains(err.Error(), "无法解析@提及的用户: nobody@example.com") {
+		t.Errorf("未知邮箱 error = %v, want 无法解析@提及的用户", err)
+	}
+}
@@ -1,12 +1,17 @@
 package feishu
 
 import (
+	"encoding/json"
+	"fmt"
 	"mcp-feishu/internal/types"
+	"mcp-feishu/pkg/feishumsg"
+	"strings"
 )
 
 // MessageBuilder 消息构建器
 type MessageBuilder struct {
 	securityManager *SecurityManager
+	filters         []ContentFilter
 }
 
 // NewMessageBuilder 创建消息构建器
@@ -16,6 +21,23 @@ func NewMessageBuilder(securityManager *SecurityManager) *MessageBuilder {
 	}
 }
 
+// AddFilter 添加内容过滤器，按添加顺序在安全处理之前执行
+func (mb *MessageBuilder) AddFilter(filter ContentFilter) {
+	mb.filters = append(mb.filters, filter)
+}
+
+// process 依次执行内容过滤和安全处理
+//
+// 内容过滤先于关键词注入和签名，脱敏后的内容才会参与关键词校验。
+func (mb *MessageBuilder) process(req *types.FeishuWebhookRequest, content interface{}) error {
+	for _, filter := range mb.filters {
+		if err := filter.Filter(content); err != nil {
+			return err
+		}
+	}
+	return mb.securityManager.ProcessMessage(req, content)
+}
+
 // BuildTextMessage 构建文本消息
 func (mb *MessageBuilder) BuildTextMessage(text string) (*types.FeishuWebhookRequest, error) {
 	content := &types.TextMessage{
@@ -27,17 +49,21 @@ func (mb *MessageBuilder) BuildTextMessage(text string) (*types.FeishuWebhookReq
 		Content: content,
 	}
 
-	if err := mb.securityManager.ProcessMessage(req, content); err != nil {
+	if err := mb.process(req, content); err != nil {
 		return nil, err
 	}
 
 	return req, nil
 }
 
-// BuildRichTextMessage 构建富文本消息（Post类型）
+// BuildRichTextMessage 构建富文本消息（Post类型），content为按语言组织的map或*feishumsg.Post
 func (mb *MessageBuilder) BuildRichTextMessage(content interface{}) (*types.FeishuWebhookRequest, error) {
+	post, err := postContentMap(content)
+	if err != nil {
+		return nil, err
+	}
 	postContent := &types.PostMessage{
-		Post: content.(map[string]interface{}),
+		Post: post,
 	}
 
 	req := &types.FeishuWebhookRequest{
@@ -45,37 +71,80 @@ func (mb *MessageBuilder) BuildRichTextMessage(content interface{}) (*types.Feis
 		Content: postContent,
 	}
 
-	if err := mb.securityManager.ProcessMessage(req, postContent); err != nil {
+	if err := mb.process(req, postContent); err != nil {
 		return nil, err
 	}
 
 	return req, nil
 }
 
-// BuildPostMessage 构建结构化富文本消息
-func (mb *MessageBuilder) BuildPostMessage(title string, content map[string]interface{}) (*types.FeishuWebhookRequest, error) {
-	// 构建完整的post结构
-	postData := map[string]interface{}{
-		"zh_cn": map[string]interface{}{
-			"title":   title,
-			"content": content,
-		},
+// postContentMap 将富文本内容转换为按语言组织的通用map，关键词注入和内容过滤需要就地修改其中的文本
+//
+// map同样逐层转换，其中的段落可能是类型化的切片，只转换外层会让内容过滤跳过这些文本。
+func postContentMap(content interface{}) (map[string]interface{}, error) {
+	if v, ok := content.(*feishumsg.Post); ok {
+		if v == nil {
+			return nil, fmt.Errorf("富文本内容不能为空")
+		}
+		content = v.Locales()
 	}
 
-	postContent := &types.PostMessage{
-		Post: postData,
+	post, ok := toGeneric(content).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("富文本内容必须是按语言组织的对象，如 {\"zh_cn\": {\"title\": \"...\", \"content\": [[...]]}}，实际为%T", content)
 	}
+	return post, nil
+}
 
-	req := &types.FeishuWebhookRequest{
-		MsgType: string(types.MessageTypePost),
-		Content: postContent,
+// BuildMessage 构建feishumsg类型化消息，与对应的Build方法一样执行内容过滤和安全处理
+func (mb *MessageBuilder) BuildMessage(msg feishumsg.Message) (*types.FeishuWebhookRequest, error) {
+	switch m := msg.(type) {
+	case *feishumsg.TextMessage:
+		return mb.BuildTextMessage(m.Text)
+	case *feishumsg.Post:
+		return mb.BuildRichTextMessage(m)
+	case *feishumsg.ImageMessage:
+		return mb.BuildImageMessage(m.ImageKey)
+	case *feishumsg.ShareChatMessage:
+		return mb.BuildShareChatMessage(m.ShareChatID)
+	case *feishumsg.Card:
+		// 转换为通用结构，关键词注入和内容过滤需要就地修改卡片中的文本
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, fmt.Errorf("序列化卡片失败: %w", err)
+		}
+		var card types.InteractiveMessage
+		if err := json.Unmarshal(data, &card); err != nil {
+			return nil, fmt.Errorf("转换卡片失败: %w", err)
+		}
+		return mb.BuildCardMessage(&card)
+	default:
+		return nil, fmt.Errorf("不支持的消息类型: %T", msg)
 	}
+}
 
-	if err := mb.securityManager.ProcessMessage(req, postContent); err != nil {
-		return nil, err
+// BuildPostMessage 构建结构化富文本消息，每种语言一份标题和内容，飞书按读者语言展示
+func (mb *MessageBuilder) BuildPostMessage(posts map[types.Locale]types.PostLocale) (*types.FeishuWebhookRequest, error) {
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("富文本消息至少需要一种语言的内容")
 	}
 
-	return req, nil
+	// 构建完整的post结构，使用通用map以便关键词注入和内容过滤就地修改
+	postData := make(map[string]interface{}, len(posts))
+	for locale, post := range posts {
+		if err := ValidateLocale(string(locale)); err != nil {
+			return nil, err
+		}
+		localeData := map[string]interface{}{
+			"content": post.Content,
+		}
+		if post.Title != "" {
+			localeData["title"] = post.Title
+		}
+		postData[string(locale)] = localeData
+	}
+
+	return mb.BuildRichTextMessage(postData)
 }
 
 // BuildImageMessage 构建图片消息
@@ -89,7 +158,7 @@ func (mb *MessageBuilder) BuildImageMessage(imageKey string) (*types.FeishuWebho
 		Content: content,
 	}
 
-	if err := mb.securityManager.ProcessMessage(req, content); err != nil {
+	if err := mb.process(req, content); err != nil {
 		return nil, err
 	}
 
@@ -98,10 +167,22 @@ func (mb *MessageBuilder) BuildImageMessage(imageKey string) (*types.FeishuWebho
 
 // BuildInteractiveMessage 构建交互式消息卡片
 func (mb *MessageBuilder) BuildInteractiveMessage(config interface{}, elements []interface{}, header interface{}) (*types.FeishuWebhookRequest, error) {
-	content := &types.InteractiveMessage{
+	return mb.BuildCardMessage(&types.InteractiveMessage{
 		Config:   config,
 		Elements: elements,
 		Header:   header,
+	})
+}
+
+// BuildCardMessage 构建交互式消息卡片，支持按语言提供的i18n_elements
+func (mb *MessageBuilder) BuildCardMessage(content *types.InteractiveMessage) (*types.FeishuWebhookRequest, error) {
+	for locale, elements := range content.I18nElements {
+		if err := ValidateLocale(locale); err != nil {
+			return nil, fmt.Errorf("i18n_elements: %w", err)
+		}
+		if _, ok := elements.([]interface{}); !ok {
+			return nil, fmt.Errorf("i18n_elements.%s 必须是数组类型", locale)
+		}
 	}
 
 	req := &types.FeishuWebhookRequest{
@@ -109,7 +190,7 @@ func (mb *MessageBuilder) BuildInteractiveMessage(config interface{}, elements [
 		Content: content,
 	}
 
-	if err := mb.securityManager.ProcessMessage(req, content); err != nil {
+	if err := mb.process(req, content); err != nil {
 		return nil, err
 	}
 
@@ -127,19 +208,42 @@ func (mb *MessageBuilder) BuildShareChatMessage(shareChatID string) (*types.Feis
 		Content: content,
 	}
 
-	if err := mb.securityManager.ProcessMessage(req, content); err != nil {
+	if err := mb.process(req, content); err != nil {
 		return nil, err
 	}
 
 	return req, nil
 }
 
-// CreatePostContent 创建富文本内容的便捷方法
+// ValidateLocale 检查语言是否受支持
+func ValidateLocale(locale string) error {
+	for _, supported := range types.SupportedLocales {
+		if types.Locale(locale) == supported {
+			return nil
+		}
+	}
+	names := make([]string, len(types.SupportedLocales))
+	for i, supported := range types.SupportedLocales {
+		names[i] = string(supported)
+	}
+	return fmt.Errorf("不支持的语言: %s，可选值: %s", locale, strings.Join(names, ", "))
+}
+
+// CreatePostContent 创建默认语言(zh_cn)富文本内容的便捷方法
 func CreatePostContent(elements ...interface{}) map[string]interface{} {
+	return CreateLocalePostContent(types.DefaultLocale, "", elements...)
+}
+
+// CreateLocalePostContent 创建指定语言的单段富文本内容，多种语言的结果可以合并到同一个map中发送
+func CreateLocalePostContent(locale types.Locale, title string, elements ...interface{}) map[string]interface{} {
+	localeData := map[string]interface{}{
+		"content": [][]interface{}{elements},
+	}
+	if title != "" {
+		localeData["title"] = title
+	}
 	return map[string]interface{}{
-		"zh_cn": map[string]interface{}{
-			"content": [][]interface{}{elements},
-		},
+		string(locale): localeData,
 	}
 }
 
@@ -6,39 +6,85 @@ import (
 	"encoding/base64"
 	"fmt"
 	"mcp-feishu/internal/types"
+	"mcp-feishu/pkg/feishumsg"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// SecurityManager 安全管理器
+// SecurityManager 安全管理器，按组合策略处理消息
 type SecurityManager struct {
-	securityType types.SecurityType
-	secret       string
-	keywords     []string
+	policies      []types.SecurityType
+	secret        string
+	keywords      []string
+	keywordPolicy types.KeywordPolicy
 }
 
-// NewSecurityManager 创建安全管理器
-func NewSecurityManager(securityType types.SecurityType, secret string, keywords []string) *SecurityManager {
+// NewSecurityManager 创建安全管理器，keywordPolicy为空时默认为reject
+func NewSecurityManager(policies []types.SecurityType, secret string, keywords []string, keywordPolicy types.KeywordPolicy) *SecurityManager {
+	if keywordPolicy == "" {
+		keywordPolicy = types.KeywordPolicyReject
+	}
+
 	return &SecurityManager{
-		securityType: securityType,
-		secret:       secret,
-		keywords:     keywords,
+		policies:      policies,
+		secret:        secret,
+		keywords:      keywords,
+		keywordPolicy: keywordPolicy,
 	}
 }
 
+// newSecurityManagerFromConfig 根据飞书配置创建安全管理器
+func newSecurityManagerFromConfig(config types.FeishuConfig) *SecurityManager {
+	return NewSecurityManager(
+		config.EnabledSecurityPolicies(),
+		config.Secret,
+		config.Keywords,
+		types.KeywordPolicy(config.KeywordPolicy),
+	)
+}
+
+// Policies 获取启用的安全策略
+func (sm *SecurityManager) Policies() []types.SecurityType {
+	return sm.policies
+}
+
+// HasPolicy 是否启用了指定安全策略
+func (sm *SecurityManager) HasPolicy(policy types.SecurityType) bool {
+	for _, p := range sm.policies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
 // ProcessMessage 处理消息安全设置
+//
+// 关键词策略可能修改消息内容，因此先于签名处理；签名只依赖时间戳和密钥。
 func (sm *SecurityManager) ProcessMessage(req *types.FeishuWebhookRequest, content interface{}) error {
-	switch sm.securityType {
-	case types.SecurityTypeSignature:
-		return sm.addSignature(req)
-	case types.SecurityTypeKeyword:
-		return sm.validateKeyword(content)
-	case types.SecurityTypeNone:
-		return nil
-	default:
-		return fmt.Errorf("不支持的安全类型: %s", sm.securityType)
+	for _, policy := range sm.policies {
+		switch policy {
+		case types.SecurityTypeKeyword, types.SecurityTypeSignature, types.SecurityTypeIPAllowlist:
+		default:
+			return fmt.Errorf("不支持的安全类型: %s", policy)
+		}
+	}
+
+	if sm.HasPolicy(types.SecurityTypeKeyword) {
+		if err := sm.ensureKeyword(content); err != nil {
+			return err
+		}
 	}
+
+	if sm.HasPolicy(types.SecurityTypeSignature) {
+		if err := sm.addSignature(req); err != nil {
+			return err
+		}
+	}
+
+	// IP白名单由飞书服务端根据请求来源IP校验，这里无需处理
+	return nil
 }
 
 // addSignature 添加签名
@@ -49,19 +95,17 @@ func (sm *SecurityManager) addSignature(req *types.FeishuWebhookRequest) error {
 
 	timestamp := time.Now().Unix()
 	req.Timestamp = timestamp
-
-	// 构造签名字符串：timestamp + "\n" + secret
-	stringToSign := fmt.Sprintf("%d\n%s", timestamp, sm.secret)
-
-	// 计算HMAC-SHA256签名
-	h := hmac.New(sha256.New, []byte(sm.secret))
-	h.Write([]byte(stringToSign))
-	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
-
-	req.Sign = signature
+	req.Sign = GenSign(sm.secret, timestamp)
 	return nil
 }
 
+// GenSign 按飞书自定义机器人的签名算法计算签名
+//
+// 以 timestamp + "\n" + secret 作为HMAC-SHA256的密钥对空内容计算摘要，再进行Base64编码。
+func GenSign(secret string, timestamp int64) string {
+	return feishumsg.Sign(secret, timestamp)
+}
+
 // validateKeyword 验证关键词
 func (sm *SecurityManager) validateKeyword(content interface{}) error {
 	if len(sm.keywords) == 0 {
@@ -69,7 +113,7 @@ func (sm *SecurityManager) validateKeyword(content interface{}) error {
 	}
 
 	// 提取消息文本内容
-	text := extractTextFromContent(content)
+	text := visibleText(content)
 	if text == "" {
 		return fmt.Errorf("无法提取消息文本内容")
 	}
@@ -84,41 +128,82 @@ func (sm *SecurityManager) validateKeyword(content interface{}) error {
 	return fmt.Errorf("消息内容必须包含以下关键词之一: %v", sm.keywords)
 }
 
-// extractTextFromContent 从不同类型的消息内容中提取文本
-func extractTextFromContent(content interface{}) string {
+// ensureKeyword 确保消息包含关键词，按策略拒绝或自动注入第一个关键词
+func (sm *SecurityManager) ensureKeyword(content interface{}) error {
+	err := sm.validateKeyword(content)
+	if err == nil || sm.keywordPolicy == types.KeywordPolicyReject || len(sm.keywords) == 0 {
+		return err
+	}
+
+	if !injectKeyword(content, sm.keywords[0], sm.keywordPolicy) {
+		return fmt.Errorf("%w（该消息类型不包含可注入关键词的文本）", err)
+	}
+
+	return nil
+}
+
+// injectKeyword 将关键词注入文本消息正文、富文本标题或卡片标题，返回是否注入成功
+func injectKeyword(content interface{}, keyword string, policy types.KeywordPolicy) bool {
 	switch v := content.(type) {
 	case *types.TextMessage:
-		return v.Text
-	case map[string]interface{}:
-		// 处理不同消息类型
-		if text, ok := v["text"].(string); ok {
-			return text
-		}
-		if title, ok := v["title"].(string); ok {
-			return title
+		v.Text = joinKeyword(v.Text, keyword, policy)
+		return true
+	case *types.PostMessage:
+		// 每种语言的标题都需要注入，飞书按读者语言展示
+		injected := false
+		for _, localeContent := range v.Post {
+			if locale, ok := localeContent.(map[string]interface{}); ok {
+				title, _ := locale["title"].(string)
+				locale["title"] = joinKeyword(title, keyword, policy)
+				injected = true
+			}
 		}
-		// 尝试递归提取文本
-		for _, value := range v {
-			if text := extractTextFromContent(value); text != "" {
-				return text
+		return injected
+	case *types.InteractiveMessage:
+		header, ok := v.Header.(map[string]interface{})
+		if !ok {
+			if v.Header != nil {
+				return false
 			}
+			header = map[string]interface{}{}
+			v.Header = header
 		}
-	case []interface{}:
-		// 处理数组类型
-		for _, item := range v {
-			if text := extractTextFromContent(item); text != "" {
-				return text
+		title, ok := header["title"].(map[string]interface{})
+		if !ok {
+			title = map[string]interface{}{"tag": "plain_text"}
+			header["title"] = title
+		}
+		text, _ := title["content"].(string)
+		title["content"] = joinKeyword(text, keyword, policy)
+		// 多语言标题同样需要注入，飞书按读者语言展示
+		if i18n, ok := title["i18n"].(map[string]interface{}); ok {
+			for locale, value := range i18n {
+				if text, ok := value.(string); ok {
+					i18n[locale] = joinKeyword(text, keyword, policy)
+				}
 			}
 		}
-	case string:
-		return v
+		return true
+	default:
+		// 图片、群名片等消息没有可承载关键词的文本
+		return false
+	}
+}
+
+// joinKeyword 按策略将关键词拼接到文本开头或末尾
+func joinKeyword(text, keyword string, policy types.KeywordPolicy) string {
+	if text == "" {
+		return keyword
+	}
+	if policy == types.KeywordPolicyAppend {
+		return text + " " + keyword
 	}
-	return ""
+	return keyword + " " + text
 }
 
 // ValidateSignature 验证接收到的签名（用于接收飞书回调）
 func (sm *SecurityManager) ValidateSignature(timestamp string, signature string, body []byte) error {
-	if sm.securityType != types.SecurityTypeSignature {
+	if !sm.HasPolicy(types.SecurityTypeSignature) {
 		return nil
 	}
 
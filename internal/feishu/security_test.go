@@ -0,0 +1,139 @@
+package feishu
+
+import (
+	"context"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"strings"
+	"testing"
+)
+
+// 期望值由飞书自定义机器人文档中的Python示例代码（gen_sign）计算得到
+func TestGenSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		want      string
+	}{
+		{"文档示例时间戳", "demo", 1599360473, "l1N0gAcBjdwBvGm1xMjOF0XSyaLRpR7tuO5dHfhAYc8="},
+		{"SEC前缀密钥", "SEC5f2a9c1e7b3d", 1700000000, "y+mP49zpMix+GhULQkUZSJJkLt4MTQMppeDPUgZb3CY="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenSign(tt.secret, tt.timestamp); got != tt.want {
+				t.Errorf("GenSign(%q, %d) = %q, want %q", tt.secret, tt.timestamp, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSecurityAgainstMock 按客户端和模拟服务器各自的安全设置发送，检查签名和关键词的处理结果
+func TestSecurityAgainstMock(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   types.FeishuConfig
+		mock     feishutest.Options
+		text     string
+		wantCode int    // 飞书返回的错误码，-1表示请求未发出
+		wantText string // 飞书收到的文本
+	}{
+		{
+			name:     "无安全设置",
+			text:     "服务已恢复",
+			wantText: "服务已恢复",
+		},
+		{
+			name:     "签名正确",
+			client:   types.FeishuConfig{SecurityType: "signature", Secret: "SEC5f2a9c1e7b3d"},
+			mock:     feishutest.Options{Secret: "SEC5f2a9c1e7b3d"},
+			text:     "服务已恢复",
+			wantText: "服务已恢复",
+		},
+		{
+			name:     "签名密钥错误",
+			client:   types.FeishuConfig{SecurityType: "signature", Secret: "SECwrong"},
+			mock:     feishutest.Options{Secret: "SEC5f2a9c1e7b3d"},
+			text:     "服务已恢复",
+			wantCode: feishutest.CodeSignMismatch,
+		},
+		{
+			name:     "未签名",
+			mock:     feishutest.Options{Secret: "SEC5f2a9c1e7b3d"},
+			text:     "服务已恢复",
+			wantCode: feishutest.CodeSignMismatch,
+		},
+		{
+			name:     "包含关键词",
+			client:   types.FeishuConfig{SecurityType: "keyword", Keywords: []string{"告警"}},
+			mock:     feishutest.Options{Keywords: []string{"告警"}},
+			text:     "告警 服务已恢复",
+			wantText: "告警 服务已恢复",
+		},
+		{
+			name:     "注入关键词",
+			client:   types.FeishuConfig{SecurityType: "keyword", Keywords: []string{"告警"}, KeywordPolicy: "prepend"},
+			mock:     feishutest.Options{Keywords: []string{"告警"}},
+			text:     "服务已恢复",
+			wantText: "告警 服务已恢复",
+		},
+		{
+			name:     "客户端拒绝缺少关键词的消息",
+			client:   types.FeishuConfig{SecurityType: "keyword", Keywords: []string{"告警"}},
+			mock:     feishutest.Options{Keywords: []string{"告警"}},
+			text:     "服务已恢复",
+			wantCode: -1,
+		},
+		{
+			name:     "未配置关键词",
+			mock:     feishutest.Options{Keywords: []string{"告警"}},
+			text:     "服务已恢复",
+			wantCode: feishutest.CodeKeywordMissing,
+		},
+		{
+			name:     "签名和关键词",
+			client:   types.FeishuConfig{SecurityType: "signature,keyword", Secret: "demo", Keywords: []string{"告警"}, KeywordPolicy: "append"},
+			mock:     feishutest.Options{Secret: "demo", Keywords: []string{"告警"}},
+			text:     "服务已恢复",
+			wantText: "服务已恢复 告警",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mock := feishutest.Start(tt.mock)
+			defer mock.Close()
+
+			config := tt.client
+			config.WebhookURL = mock.URL()
+			resp, err := NewClient(config).SendTextMessage(context.Background(), tt.text)
+
+			switch {
+			case tt.wantCode == -1:
+				if err == nil || len(mock.Requests()) != 0 {
+					t.Fatalf("SendTextMessage() error = %v, 请求数 = %d, want 发送前被拒绝", err, len(mock.Requests()))
+				}
+				return
+			case tt.wantCode != 0:
+				if err == nil || resp == nil || resp.Code != tt.wantCode {
+					t.Fatalf("SendTextMessage() = %+v, %v, want code=%d", resp, err, tt.wantCode)
+				}
+				if len(mock.Messages()) != 0 {
+					t.Errorf("被拒绝的消息出现在Messages()中: %+v", mock.Messages())
+				}
+				return
+			case err != nil:
+				t.Fatalf("SendTextMessage() error = %v", err)
+			}
+
+			messages := mock.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("Messages() = %+v, want 1条", messages)
+			}
+			if got, _ := messages[0].Content["text"].(string); strings.TrimSpace(got) != tt.wantText {
+				t.Errorf("收到的文本 = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package feishu
+
+import (
+	"sort"
+	"strings"
+)
+
+// textKeys 消息内容中承载用户可见文本的字段
+var textKeys = map[string]bool{
+	"text":      true, // 文本消息、富文本text/a元素、卡片文本对象
+	"title":     true, // 富文本标题
+	"content":   true, // 卡片plain_text/lark_md/markdown内容
+	"user_name": true, // 富文本at元素显示的名字
+}
+
+// localizedKeys 按语言保存文本的字段，其中每个字符串值都是可见文本，如卡片标题的 {"i18n": {"zh_cn": "...", "en_us": "..."}}
+var localizedKeys = map[string]bool{
+	"i18n": true,
+}
+
+// hiddenKeys 不会展示给用户的字段，遍历时整体跳过
+var hiddenKeys = map[string]bool{
+	"value":  true, // 按钮回调数据
+	"config": true, // 卡片全局配置
+}
+
+// visibleText 返回消息内容中对用户可见的全部文本，以换行连接
+//
+// 范围与飞书校验自定义关键词时检查的一致：文本正文、富文本标题和各元素文本、@名字、
+// 卡片标题和各组件文本（包括多语言文本），按钮回调值、链接、ID等字段除外。
+// content可以是消息结构体、JSON通用结构或任意可序列化为JSON的值，结果按字段名排序，顺序稳定。
+func visibleText(content interface{}) string {
+	if text, ok := content.(string); ok {
+		return text
+	}
+
+	var texts []string
+	rewriteText(toGeneric(content), nil, func(text string) string {
+		texts = append(texts, text)
+		return text
+	})
+	return strings.Join(texts, "\n")
+}
+
+// rewriteText 按字段名顺序遍历JSON通用结构（map/slice）中的非空可见文本，visit返回不同的文本时写回原字段
+//
+// extraKeys为除可见文本外还需要遍历的字符串字段，例如内容过滤检查的链接字段href、url；只读取时visit返回原文本即可。
+func rewriteText(value interface{}, extraKeys []string, visit func(text string) string) {
+	extra := make(map[string]bool, len(extraKeys))
+	for _, key := range extraKeys {
+		extra[key] = true
+	}
+	walkText(value, extra, visit)
+}
+
+// walkText 递归遍历可见文本字段
+func walkText(value interface{}, extra map[string]bool, visit func(string) string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if hiddenKeys[key] {
+				continue
+			}
+			if text, ok := v[key].(string); ok {
+				if (textKeys[key] || extra[key]) && text != "" {
+					if rewritten := visit(text); rewritten != text {
+						v[key] = rewritten
+					}
+				}
+				continue
+			}
+			if localized, ok := v[key].(map[string]interface{}); ok && localizedKeys[key] {
+				walkLocalized(localized, visit)
+				continue
+			}
+			walkText(v[key], extra, visit)
+		}
+	case []interface{}:
+		for _, item := range v {
+			walkText(item, extra, visit)
+		}
+	}
+}
+
+// walkLocalized 按语言顺序遍历多语言文本
+func walkLocalized(localized map[string]interface{}, visit func(string) string) {
+	locales := make([]string, 0, len(localized))
+	for locale := range localized {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	for _, locale := range locales {
+		if text, ok := localized[locale].(string); ok && text != "" {
+			if rewritten := visit(text); rewritten != text {
+				localized[locale] = rewritten
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package feishu
+
+import (
+	"mcp-feishu/pkg/feishumsg"
+	"testing"
+)
+
+func TestVisibleText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content interface{}
+		want    string
+	}{
+		{
+			name:    "文本消息",
+			content: feishumsg.NewTextMessage("告警 服务已恢复"),
+			want:    "告警 服务已恢复",
+		},
+		{
+			name: "富文本包含标题、文本、链接文字和@名字，不包含链接和ID",
+			content: feishumsg.NewPost().Title("发布").Paragraph(
+				feishumsg.Text("v2.3.0"), feishumsg.Link("说明", "https://example.com/secret"), feishumsg.At("ou_123", "张三"), feishumsg.Img("img_key"),
+			),
+			want: "v2.3.0\n说明\n张三\n发布",
+		},
+		{
+			name: "卡片包含多语言标题和组件文本，不包含按钮回调值和链接",
+			content: feishumsg.NewCard().
+				WithHeader(feishumsg.NewHeader("告警").WithTitleI18n(feishumsg.EnUS, "Alert")).
+				Add(
+					feishumsg.Div(feishumsg.Markdown("**CPU** 过高")),
+					feishumsg.Action(feishumsg.Button("确认").WithValue(map[string]interface{}{"text": "回调"}).WithURL("https://example.com")),
+				),
+			want: "**CPU** 过高\n确认\n告警\nAlert",
+		},
+		{
+			name: "多语言卡片内容",
+			content: map[string]interface{}{
+				"i18n_elements": map[string]interface{}{
+					"en_us": []interface{}{map[string]interface{}{"tag": "markdown", "content": "hello"}},
+					"zh_cn": []interface{}{map[string]interface{}{"tag": "markdown", "content": "你好"}},
+				},
+			},
+			want: "hello\n你好",
+		},
+		{
+			name:    "图片消息没有可见文本",
+			content: feishumsg.NewImageMessage("img_key"),
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := visibleText(tt.content); got != tt.want {
+				t.Errorf("visibleText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteTextLinks(t *testing.T) {
+	content := map[string]interface{}{
+		"text": "token=abc",
+		"href": "https://example.com/?token=abc",
+		"tag":  "a",
+	}
+	rewriteText(content, []string{"href"}, func(text string) string { return "[已隐藏]" })
+
+	if content["text"] != "[已隐藏]" || content["href"] != "[已隐藏]" {
+		t.Errorf("文本和链接未被改写: %v", content)
+	}
+	if content["tag"] != "a" {
+		t.Errorf("tag字段不应被改写: %v", content["tag"])
+	}
+}
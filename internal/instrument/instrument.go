@@ -0,0 +1,113 @@
+// Package instrument 将飞书客户端的发送钩子接到进程级的指标、链路追踪、审计日志和发送配额上。
+package instrument
+
+import (
+	"context"
+	"mcp-feishu/internal/audit"
+	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/metrics"
+	"mcp-feishu/internal/quota"
+	"mcp-feishu/internal/tracing"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hooks 服务器使用的发送钩子
+type hooks struct{}
+
+// Hooks 返回服务器使用的发送钩子：记录指标和span，写入审计日志，并向context中的配额占用报告发送结果
+func Hooks() feishu.Hooks {
+	return hooks{}
+}
+
+// BeforeSend 创建发送span，span上下文随返回的context传播到请求头；
+// 摘要合并发送时span链接到每条消息加入摘要时所在的span
+func (hooks) BeforeSend(ctx context.Context, send *feishu.Send) context.Context {
+	attributes := []attribute.KeyValue{
+		attribute.String("feishu.msg_type", send.Request.MsgType),
+		attribute.String("feishu.target", send.Target),
+	}
+	if send.Name != "" {
+		attributes = append(attributes, attribute.String("feishu.webhook_target", send.Name))
+	}
+	if send.ReceiveIDType != "" {
+		attributes = append(attributes, attribute.String("feishu.receive_id_type", string(send.ReceiveIDType)))
+	}
+	var links []trace.Link
+	for _, merged := range send.Merged {
+		links = append(links, trace.LinkFromContext(merged))
+	}
+
+	ctx, _ = tracing.Tracer().Start(ctx, "feishu.send "+send.Target,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attributes...),
+		trace.WithLinks(links...),
+	)
+	return ctx
+}
+
+// AfterSend 记录请求耗时和飞书返回的错误码，结束span，写入审计日志并报告配额
+func (hooks) AfterSend(ctx context.Context, send *feishu.Send) {
+	// 命名目标按目标名称统计
+	target := send.Target
+	if send.Name != "" {
+		target = send.Name
+	}
+	metrics.RequestDuration.WithLabelValues(target).Observe(send.Duration.Seconds())
+	if send.Retries > 0 {
+		metrics.Retries.WithLabelValues(target).Add(float64(send.Retries))
+	}
+
+	span := trace.SpanFromContext(ctx)
+	receiver := send.Receiver
+	if receiver == "" {
+		receiver = send.Name
+	}
+	record := audit.Message{Target: send.Target, Receiver: receiver, Request: send.Request, Err: send.Err}
+	if send.Retries > 0 {
+		span.SetAttributes(attribute.Int("feishu.retries", send.Retries))
+	}
+	code := metrics.CodeTransportError
+	if send.Response != nil {
+		code = strconv.Itoa(send.Response.Code)
+		span.SetAttributes(attribute.Int("feishu.code", send.Response.Code))
+		record.Response = &audit.Response{
+			Code:      send.Response.Code,
+			Message:   send.Response.Message,
+			MessageID: send.Response.MessageID,
+		}
+	}
+	metrics.Messages.WithLabelValues(send.Request.MsgType, target, code).Inc()
+	tracing.RecordError(span, send.Err)
+	span.End()
+
+	if len(send.Merged) == 0 {
+		audit.Record(ctx, record)
+		quota.Report(ctx, send.Err)
+		return
+	}
+	// 摘要按每条消息加入时的调用方记录审计日志，并在各自的trace中记录合并发送的结果；
+	// 熔断按实际的发送请求计数，由ReportMerged只更新一次
+	for _, merged := range send.Merged {
+		attributes := []attribute.KeyValue{attribute.Int("feishu.digest.messages", len(send.Merged))}
+		if send.Response != nil {
+			attributes = append(attributes, attribute.Int("feishu.code", send.Response.Code))
+		}
+		merged, span := tracing.Tracer().Start(merged, "feishu.digest",
+			trace.WithAttributes(attributes...),
+			trace.WithLinks(trace.LinkFromContext(ctx)),
+		)
+		tracing.RecordError(span, send.Err)
+		span.End()
+
+		audit.Record(merged, record)
+	}
+	quota.ReportMerged(send.Merged, send.Err)
+}
+
+// Queued 更新等待发送的消息数
+func (hooks) Queued(delta int) {
+	metrics.QueueDepth.Add(float64(delta))
+}
@@ -0,0 +1,220 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"mcp-feishu/internal/acl"
+	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/quota"
+	"mcp-feishu/internal/types"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// broadcastResult 广播到一个目标的结果
+type broadcastResult struct {
+	Target  string `json:"target"`
+	Success bool   `json:"success"`
+	Code    int    `json:"code,omitempty"`    // 飞书返回的错误码
+	Message string `json:"message,omitempty"` // 飞书返回的消息
+	Error   string `json:"error,omitempty"`
+
+	// 预览模式下该目标的最终请求体，已按目标的安全设置签名
+	Request *types.FeishuWebhookRequest `json:"request,omitempty"`
+}
+
+// broadcastMessageTool 广播消息工具定义，可选目标和目标组写入描述，方便AI选择
+func broadcastMessageTool(client *feishu.Client) types.Tool {
+	targets := append([]string{types.DefaultTargetName}, client.TargetNames()...)
+	description := "广播消息\n\n将同一条消息并发发送到多个群机器人目标，例如把发布公告同时发到多个群。每个目标使用各自配置的签名密钥和关键词，结果中逐个列出每个目标的成功或失败，部分目标失败不影响其他目标。支持所有消息类型，消息参数与send_direct_message相同。\n\n可用目标: " + strings.Join(targets, ", ")
+	if groups := client.TargetGroupNames(); len(groups) > 0 {
+		description += "\n可用目标组: " + strings.Join(groups, ", ")
+	}
+	description += "\n\n示例：{\"targets\": [\"webhook\", \"release-cn\"], \"msg_type\": \"post\", \"title\": \"v2.3.0 发布\", \"content\": [[{\"tag\": \"text\", \"text\": \"新版本已上线\"}]]}"
+
+	properties := messageProperties()
+	properties["targets"] = map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "目标名称列表，webhook表示默认Webhook。可以与group同时使用，重复的目标只发送一次。",
+	}
+	properties["group"] = map[string]interface{}{
+		"type":        "string",
+		"description": "配置中的目标组名称，发送到组内全部目标。",
+	}
+
+	return types.Tool{
+		Name:        "broadcast_message",
+		Description: description,
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		},
+	}
+}
+
+// handleBroadcastMessage 处理广播消息，按broadcast_concurrency限制并发，每个目标独立检查权限和配额
+func (th *ToolsHandler) handleBroadcastMessage(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	targets, err := th.broadcastTargets(args)
+	if err != nil {
+		return newErrorResult(err.Error()), nil
+	}
+
+	msgType, _ := args["msg_type"].(string)
+	if msgType == "" {
+		msgType = string(types.MessageTypeText)
+	}
+	dryRun := th.isDryRun(args)
+
+	results := make([]broadcastResult, len(targets))
+	slots := make(chan struct{}, th.feishuClient.BroadcastConcurrency())
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = th.broadcastTo(ctx, target, types.MessageType(msgType), args, dryRun)
+		}(i, target)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	log.Info().
+		Int("targets", len(targets)).
+		Int("succeeded", succeeded).
+		Bool("dry_run", dryRun).
+		Msg("广播消息完成")
+
+	summary := fmt.Sprintf("广播完成: %d/%d个目标发送成功", succeeded, len(targets))
+	if dryRun {
+		summary = fmt.Sprintf("预览模式，消息未发送: %d/%d个目标构建成功", succeeded, len(targets))
+	}
+	result := newTextResult(fmt.Sprintf("%s\n%s", summary, SerializeForLogging(results)))
+	// 只有全部目标失败时才作为错误结果，部分失败由调用方根据逐个目标的结果处理
+	result.IsError = succeeded == 0
+	return result, nil
+}
+
+// broadcastTargets 合并targets和group参数中的目标，去重并保持顺序，存在未知目标时返回错误
+func (th *ToolsHandler) broadcastTargets(args map[string]interface{}) ([]string, error) {
+	var names []string
+	if raw, ok := args["targets"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("targets 参数必须是字符串数组")
+		}
+		for _, item := range list {
+			name, ok := item.(string)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("targets 参数必须是非空字符串数组")
+			}
+			names = append(names, name)
+		}
+	}
+	if group, _ := args["group"].(string); group != "" {
+		members, ok := th.feishuClient.TargetGroup(group)
+		if !ok {
+			return nil, fmt.Errorf("未知的目标组: %s，可用目标组: %s", group, strings.Join(th.feishuClient.TargetGroupNames(), ", "))
+		}
+		names = append(names, members...)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("必须提供 targets 或 group 参数")
+	}
+
+	seen := make(map[string]bool, len(names))
+	targets := make([]string, 0, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := th.feishuClient.Target(name); !ok {
+			available := append([]string{types.DefaultTargetName}, th.feishuClient.TargetNames()...)
+			return nil, fmt.Errorf("未知的目标: %s，可用目标: %s", name, strings.Join(available, ", "))
+		}
+		targets = append(targets, name)
+	}
+	return targets, nil
+}
+
+// broadcastTo 按目标的安全设置构建并发送消息
+func (th *ToolsHandler) broadcastTo(ctx context.Context, name string, msgType types.MessageType, args map[string]interface{}, dryRun bool) broadcastResult {
+	result := broadcastResult{Target: name}
+	client, _ := th.feishuClient.Target(name)
+	target := acl.WebhookTarget(name)
+
+	if th.policy != nil {
+		principal := acl.PrincipalFromContext(ctx)
+		if !th.policy.RuleFor(principal).AllowsTarget(target) {
+			result.Error = fmt.Sprintf("%s无权向 %s 发送消息", principal, target)
+			log.Warn().Str("target", target).Msg("广播目标被访问控制策略拒绝")
+			return result
+		}
+	}
+
+	// 每个目标单独构建，签名和关键词按目标各自的配置处理；注入关键词等处理会就地修改卡片和富文本参数，
+	// 因此每个目标使用参数的副本，避免并发写入其他目标共用的map
+	req, err := th.buildMessageFromArgs(client.MessageBuilder(), msgType, copyValue(args).(map[string]interface{}))
+	if err != nil {
+		result.Error = fmt.Sprintf("构建消息失败: %v", err)
+		return result
+	}
+
+	if dryRun {
+		result.Success = true
+		result.Request = req
+		return result
+	}
+
+	if th.quota != nil {
+		ticket, err := th.acquireQuota(ctx, target)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		defer ticket.Release()
+		ctx = quota.WithTicket(ctx, ticket)
+	}
+
+	resp, err := client.SendMessage(ctx, req)
+	if resp != nil {
+		result.Code = resp.Code
+		result.Message = resp.Message
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("发送消息失败: %v", err)
+		return result
+	}
+	result.Success = true
+	return result
+}
+
+// copyValue 深拷贝工具参数中的map和slice，其他值原样返回
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	default:
+		return value
+	}
+}
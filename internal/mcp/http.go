@@ -0,0 +1,317 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mcp-feishu/internal/types"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// HTTP传输参数
+const (
+	httpPath        = "/mcp"           // MCP端点路径
+	sessionHeader   = "Mcp-Session-Id" // 会话标识请求头，initialize响应中返回
+	maxRequestBytes = 4 << 20          // 单个请求体上限
+	maxSessions     = 1024             // 保留的会话数上限，超出时淘汰最久未使用的会话
+)
+
+// APIKey HTTP传输接受的一个bearer令牌，Name用于日志、审计和访问控制
+type APIKey struct {
+	Name string
+	Key  string
+}
+
+// HTTPOptions HTTP传输选项
+type HTTPOptions struct {
+	Addr    string   // 监听地址，如 127.0.0.1:3000
+	APIKeys []APIKey // 配置后请求必须携带 Authorization: Bearer <key>
+
+	// TLSConfig 非nil时使用HTTPS，ClientAuth为RequireAndVerifyClientCert时要求客户端证书(mTLS)
+	TLSConfig *tls.Config
+}
+
+// hashedKey 只保存令牌的SHA-256，比较时长度固定，不会通过耗时泄露令牌长度
+type hashedKey struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// httpSession HTTP传输的会话
+type httpSession struct {
+	*session
+	lastUsed time.Time
+}
+
+// httpTransport 以HTTP POST承载JSON-RPC消息的MCP传输，每个请求体是一条消息，响应体是对应的响应
+//
+// 只实现请求-响应模式，不提供服务器推送的SSE流，客户端需要自行重新获取工具列表。
+type httpTransport struct {
+	server *Server
+	keys   []hashedKey
+	mtls   bool
+	logger zerolog.Logger
+
+	httpServer *http.Server
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// RunHTTP 以HTTP传输运行MCP服务器，阻塞直到Shutdown被调用或监听失败
+//
+// 必须配置API密钥或客户端证书认证，避免任何能访问端口的人都可以通过机器人发消息。
+func (s *Server) RunHTTP(opts HTTPOptions) error {
+	mtls := opts.TLSConfig != nil && opts.TLSConfig.ClientAuth == tls.RequireAndVerifyClientCert
+	if len(opts.APIKeys) == 0 && !mtls {
+		return fmt.Errorf("HTTP传输必须配置API密钥或客户端证书认证")
+	}
+
+	t := &httpTransport{
+		server:   s,
+		mtls:     mtls,
+		logger:   s.logger.With().Str("transport", "http").Logger(),
+		sessions: make(map[string]*httpSession),
+	}
+	for _, key := range opts.APIKeys {
+		t.keys = append(t.keys, hashedKey{name: key.Name, hash: sha256.Sum256([]byte(key.Key))})
+	}
+	t.httpServer = &http.Server{
+		Addr:              opts.Addr,
+		Handler:           t,
+		TLSConfig:         opts.TLSConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	s.httpMu.Lock()
+	s.http = t
+	s.httpMu.Unlock()
+
+	s.logger.Info().
+		Str("addr", opts.Addr).
+		Bool("tls", opts.TLSConfig != nil).
+		Bool("mtls", mtls).
+		Int("api_keys", len(t.keys)).
+		Msg("启动MCP飞书服务器（HTTP传输）")
+
+	var err error
+	if opts.TLSConfig != nil {
+		// 证书已经在TLSConfig中加载
+		err = t.httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = t.httpServer.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// shutdown 停止接收新连接，等待进行中的请求完成
+func (t *httpTransport) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := t.httpServer.Shutdown(ctx); err != nil {
+		t.logger.Warn().Err(err).Msg("关闭HTTP传输超时")
+	}
+}
+
+// ServeHTTP 认证后处理发往 /mcp 的请求
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != httpPath {
+		http.NotFound(w, r)
+		return
+	}
+
+	identity, ok := t.authenticate(r)
+	if !ok {
+		// 不记录请求携带的令牌，避免错误配置的客户端把密钥写进日志
+		t.logger.Warn().Str("remote_addr", r.RemoteAddr).Msg("HTTP请求认证失败")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mcp-feishu"`)
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r, identity)
+	case http.MethodDelete:
+		t.handleDelete(w, r, identity)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// authenticate 校验请求的身份，返回认证通过的身份名称
+//
+// 配置了API密钥时必须携带匹配的bearer令牌，身份为密钥名称；只使用mTLS时身份为客户端证书的CN。
+// 客户端证书由TLS握手校验，这里无需重复检查。
+func (t *httpTransport) authenticate(r *http.Request) (string, bool) {
+	if len(t.keys) == 0 {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return "", false
+		}
+		return r.TLS.PeerCertificates[0].Subject.CommonName, true
+	}
+
+	token, ok := bearerToken(r.Header.Get("Authorization"))
+	if !ok {
+		return "", false
+	}
+	hash := sha256.Sum256([]byte(token))
+
+	// 比较全部密钥而不是在第一个匹配处返回，耗时与命中哪个密钥无关
+	name := ""
+	for _, key := range t.keys {
+		if subtle.ConstantTimeCompare(hash[:], key.hash[:]) == 1 && name == "" {
+			name = key.name
+		}
+	}
+	return name, name != ""
+}
+
+// bearerToken 解析 Authorization: Bearer <token> 请求头，认证方案不区分大小写
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// handlePost 处理一条JSON-RPC消息，请求返回JSON响应，通知返回202
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request, identity string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取请求失败: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var request types.MCPRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.writeJSON(w, http.StatusBadRequest, types.MCPResponse{
+			JSONRPC: "2.0",
+			ID:      nil,
+			Error: &types.MCPError{
+				Code:    -32700,
+				Message: "解析请求失败",
+				Data:    err.Error(),
+			},
+		})
+		return
+	}
+
+	t.logger.Debug().
+		Str("method", request.Method).
+		Interface("id", request.ID).
+		Str("api_key", identity).
+		Msg("收到MCP请求")
+
+	// initialize开始新会话；其他请求携带会话标识时沿用会话中的客户端信息，未携带时作为无状态调用处理
+	var sess *session
+	sessionID := r.Header.Get(sessionHeader)
+	switch {
+	case request.Method == "initialize":
+		sess = &session{apiKey: identity}
+		sessionID = ""
+	case sessionID != "":
+		sess = t.lookupSession(sessionID, identity)
+		if sess == nil {
+			http.Error(w, "会话不存在或已过期，请重新initialize", http.StatusNotFound)
+			return
+		}
+	default:
+		sess = &session{apiKey: identity}
+	}
+
+	response := t.server.handleRequest(sess, request)
+	if response == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if request.Method == "initialize" && response.Error == nil {
+		id, err := t.addSession(sess)
+		if err != nil {
+			t.logger.Error().Err(err).Msg("创建会话失败")
+			http.Error(w, "创建会话失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(sessionHeader, id)
+	}
+	t.writeJSON(w, http.StatusOK, response)
+}
+
+// handleDelete 客户端主动结束会话
+func (t *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request, identity string) {
+	sessionID := r.Header.Get(sessionHeader)
+	if sessionID == "" || t.lookupSession(sessionID, identity) == nil {
+		http.Error(w, "会话不存在或已过期", http.StatusNotFound)
+		return
+	}
+
+	t.mu.Lock()
+	delete(t.sessions, sessionID)
+	t.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJSON 写出JSON响应
+func (t *httpTransport) writeJSON(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		t.logger.Error().Err(err).Msg("编码响应失败")
+	}
+}
+
+// addSession 保存会话并返回随机生成的会话标识，会话数达到上限时淘汰最久未使用的会话
+func (t *httpTransport) addSession(sess *session) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成会话标识失败: %w", err)
+	}
+	id := hex.EncodeToString(buf)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.sessions) >= maxSessions {
+		oldestID := ""
+		for sessionID, s := range t.sessions {
+			if oldestID == "" || s.lastUsed.Before(t.sessions[oldestID].lastUsed) {
+				oldestID = sessionID
+			}
+		}
+		delete(t.sessions, oldestID)
+	}
+	t.sessions[id] = &httpSession{session: sess, lastUsed: time.Now()}
+	return id, nil
+}
+
+// lookupSession 返回会话，会话只能由创建它的身份使用，避免持有其他密钥的客户端冒用会话
+func (t *httpTransport) lookupSession(id, identity string) *session {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok || s.apiKey != identity {
+		return nil
+	}
+	s.lastUsed = time.Now()
+	return s.session
+}
@@ -0,0 +1,246 @@
+// Package mcptest 通过内存管道驱动mcp.Server，用于端到端验证服务器的MCP协议行为。
+//
+// Client 模拟MCP客户端按行发送JSON-RPC消息并读取响应和通知，只应在测试中使用；
+// 包内的TestConformance在此基础上对照 feishutest 模拟Webhook执行一组协议一致性检查。
+package mcptest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mcp-feishu/internal/mcp"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout 等待响应或通知的默认超时时间
+const DefaultTimeout = 5 * time.Second
+
+// Message 服务器发出的一条JSON-RPC消息
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+
+	raw []byte
+}
+
+// Error JSON-RPC错误对象
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// IsNotification 是否为服务器发出的通知
+func (m *Message) IsNotification() bool {
+	return m.Method != "" && len(m.ID) == 0
+}
+
+// Raw 返回消息的原始JSON
+func (m *Message) Raw() string {
+	return string(m.raw)
+}
+
+// DecodeResult 将result解析到v
+func (m *Message) DecodeResult(v interface{}) error {
+	if m.Error != nil {
+		return fmt.Errorf("响应为错误: code=%d, message=%s", m.Error.Code, m.Error.Message)
+	}
+	if len(m.Result) == 0 {
+		return fmt.Errorf("响应缺少result")
+	}
+	return json.Unmarshal(m.Result, v)
+}
+
+// Client 通过内存管道连接mcp.Server的测试客户端
+type Client struct {
+	server  *mcp.Server
+	input   *io.PipeWriter
+	timeout time.Duration
+
+	writeMu sync.Mutex
+	nextID  int
+
+	messages chan *Message
+	done     chan error
+
+	mu            sync.Mutex
+	notifications []*Message
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewClient 在后台启动server.Run并返回连接到它的客户端，使用完毕后调用Close
+func NewClient(server *mcp.Server) *Client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	c := &Client{
+		server:   server,
+		input:    inWriter,
+		timeout:  DefaultTimeout,
+		messages: make(chan *Message, 64),
+		done:     make(chan error, 1),
+	}
+
+	go func() {
+		err := server.Run(inReader, outWriter)
+		outWriter.Close()
+		c.done <- err
+	}()
+	go c.readLoop(outReader)
+
+	return c
+}
+
+// Server 返回被测试的服务器
+func (c *Client) Server() *mcp.Server {
+	return c.server
+}
+
+// SetTimeout 设置等待响应和通知的超时时间
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// readLoop 按行读取服务器输出，通知单独记录，其余消息交给等待响应的调用方
+func (c *Client) readLoop(output io.Reader) {
+	defer close(c.messages)
+
+	reader := bufio.NewReader(output)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			msg := &Message{raw: line}
+			if jsonErr := json.Unmarshal(line, msg); jsonErr != nil {
+				msg = &Message{raw: line, Error: &Error{Code: -1, Message: fmt.Sprintf("服务器输出了无效的JSON: %v", jsonErr)}}
+			}
+			if msg.IsNotification() {
+				c.mu.Lock()
+				c.notifications = append(c.notifications, msg)
+				c.mu.Unlock()
+			} else {
+				c.messages <- msg
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// SendRaw 向服务器发送一行原始数据，用于构造格式错误的消息
+func (c *Client) SendRaw(line string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if _, err := io.WriteString(c.input, line+"\n"); err != nil {
+		return fmt.Errorf("发送消息失败: %w", err)
+	}
+	return nil
+}
+
+// send 序列化并发送一条消息
+func (c *Client) send(message map[string]interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("序列化消息失败: %w", err)
+	}
+	return c.SendRaw(string(data))
+}
+
+// Notify 发送通知，服务器不应返回响应
+func (c *Client) Notify(method string, params interface{}) error {
+	message := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	return c.send(message)
+}
+
+// Call 使用自增的数字id发送请求并等待响应
+func (c *Client) Call(method string, params interface{}) (*Message, error) {
+	c.writeMu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.writeMu.Unlock()
+
+	return c.CallWithID(id, method, params)
+}
+
+// CallWithID 使用指定的id发送请求并等待响应，响应的id必须与请求一致
+func (c *Client) CallWithID(id interface{}, method string, params interface{}) (*Message, error) {
+	message := map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	if err := c.send(message); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Next()
+	if err != nil {
+		return nil, fmt.Errorf("等待%s响应失败: %w", method, err)
+	}
+
+	expected, _ := json.Marshal(id)
+	if string(resp.ID) != string(expected) {
+		return resp, fmt.Errorf("响应id不匹配: 期望%s，实际%s", expected, resp.ID)
+	}
+	return resp, nil
+}
+
+// Next 等待服务器发出的下一条非通知消息
+func (c *Client) Next() (*Message, error) {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			return nil, fmt.Errorf("服务器已关闭输出")
+		}
+		return msg, nil
+	case <-time.After(c.timeout):
+		return nil, fmt.Errorf("等待响应超时(%s)", c.timeout)
+	}
+}
+
+// Notifications 返回目前收到的全部通知
+func (c *Client) Notifications() []*Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]*Message(nil), c.notifications...)
+}
+
+// WaitNotification 等待指定方法的通知
+func (c *Client) WaitNotification(method string) (*Message, error) {
+	deadline := time.Now().Add(c.timeout)
+	for time.Now().Before(deadline) {
+		for _, msg := range c.Notifications() {
+			if msg.Method == method {
+				return msg, nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("等待通知%s超时(%s)", method, c.timeout)
+}
+
+// Close 关闭输入并等待服务器退出，可重复调用
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.input.Close()
+
+		select {
+		case c.closeErr = <-c.done:
+		case <-time.After(c.timeout):
+			c.closeErr = fmt.Errorf("服务器在输入关闭后未退出")
+		}
+	})
+	return c.closeErr
+}
@@ -0,0 +1,447 @@
+package mcptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/mcp"
+	"mcp-feishu/internal/types"
+	"strings"
+	"testing"
+)
+
+// 一致性检查使用的安全配置，客户端和模拟Webhook保持一致
+const (
+	conformanceSecret  = "conformance-secret"
+	conformanceKeyword = "告警"
+)
+
+// webhookTools 未配置应用机器人时应当提供的工具
+var webhookTools = []string{
+	"send_text_message",
+	"send_post_message",
+	"send_image_message",
+	"send_interactive_message",
+	"send_share_chat_message",
+}
+
+// Env 单项检查的运行环境，每项检查使用独立的服务器和模拟Webhook
+type Env struct {
+	Mock   *feishutest.Server
+	Client *Client
+	Config types.FeishuConfig
+}
+
+// NewEnv 启动模拟Webhook和连接到它的MCP服务器，启用签名和关键词校验
+func NewEnv() *Env {
+	mock := feishutest.Start(feishutest.Options{
+		Secret:           conformanceSecret,
+		Keywords:         []string{conformanceKeyword},
+		DisableRateLimit: true,
+	})
+
+	cfg := types.FeishuConfig{
+		WebhookURL:   mock.URL(),
+		SecurityType: "signature,keyword",
+		Secret:       conformanceSecret,
+		Keywords:     []string{conformanceKeyword},
+	}
+
+	return &Env{
+		Mock:   mock,
+		Client: NewClient(mcp.NewServer(feishu.NewClient(cfg))),
+		Config: cfg,
+	}
+}
+
+// Close 关闭服务器和模拟Webhook
+func (e *Env) Close() error {
+	err := e.Client.Close()
+	e.Mock.Close()
+	return err
+}
+
+// Check 一项一致性检查
+type Check struct {
+	Name string
+	Run  func(env *Env) error
+}
+
+// Checks 返回全部一致性检查：初始化、通知、工具列表、工具调用及各类错误格式
+func Checks() []Check {
+	return []Check{
+		{Name: "initialize返回协议版本、能力和服务器信息", Run: checkInitialize},
+		{Name: "initialized通知不返回响应", Run: checkInitializedNotification},
+		{Name: "未知通知不返回响应", Run: checkUnknownNotification},
+		{Name: "ping返回result对象", Run: checkPing},
+		{Name: "字符串id原样返回", Run: checkStringID},
+		{Name: "tools/list工具定义完整", Run: checkToolsList},
+		{Name: "send_text_message发送到Webhook", Run: checkSendText},
+		{Name: "安全设置拒绝的消息返回工具错误且不发送", Run: checkSecurityRejection},
+		{Name: "Webhook错误码返回工具错误", Run: checkWebhookError},
+		{Name: "dry_run返回请求体且不发送", Run: checkDryRun},
+		{Name: "未知工具返回工具错误", Run: checkUnknownTool},
+		{Name: "未知方法返回-32601", Run: checkUnknownMethod},
+		{Name: "tools/call参数格式错误返回-32602", Run: checkInvalidParams},
+		{Name: "无效JSON返回-32700且服务器继续工作", Run: checkParseError},
+		{Name: "工具列表变化时发送tools/list_changed", Run: checkListChanged},
+		{Name: "输入结束后服务器退出", Run: checkShutdownOnEOF},
+	}
+}
+
+func TestConformance(t *testing.T) {
+	for _, check := range Checks() {
+		check := check
+		t.Run(check.Name, func(t *testing.T) {
+			env := NewEnv()
+			if err := check.Run(env); err != nil {
+				t.Error(err)
+			}
+			// 服务器应在输入结束后退出
+			if err := env.Close(); err != nil {
+				t.Errorf("关闭运行环境失败: %v", err)
+			}
+		})
+	}
+}
+
+// toolResult tools/call的结果
+type toolResult struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	IsError bool `json:"isError"`
+}
+
+// text 拼接结果中的全部文本内容
+func (r *toolResult) text() string {
+	var parts []string
+	for _, content := range r.Content {
+		parts = append(parts, content.Text)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// callTool 调用工具并校验结果至少包含一段文本内容
+func callTool(c *Client, name string, args map[string]interface{}) (*toolResult, error) {
+	resp, err := c.Call("tools/call", map[string]interface{}{"name": name, "arguments": args})
+	if err != nil {
+		return nil, err
+	}
+
+	var result toolResult
+	if err := resp.DecodeResult(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Content) == 0 {
+		return nil, fmt.Errorf("工具结果content为空: %s", resp.Raw())
+	}
+	for _, content := range result.Content {
+		if content.Type != "text" {
+			return nil, fmt.Errorf("工具结果content类型应为text，实际为%q", content.Type)
+		}
+	}
+	return &result, nil
+}
+
+// expectError 校验响应为指定错误码的JSON-RPC错误
+func expectError(resp *Message, code int) error {
+	if resp.Error == nil {
+		return fmt.Errorf("期望错误码%d，实际响应: %s", code, resp.Raw())
+	}
+	if resp.Error.Code != code {
+		return fmt.Errorf("期望错误码%d，实际为%d: %s", code, resp.Error.Code, resp.Error.Message)
+	}
+	if len(resp.Result) > 0 {
+		return fmt.Errorf("错误响应不应包含result: %s", resp.Raw())
+	}
+	return nil
+}
+
+// expectNoResponse 发送ping确认服务器在此之前没有输出多余的响应
+func expectNoResponse(c *Client) error {
+	if _, err := c.Call("ping", nil); err != nil {
+		return fmt.Errorf("服务器可能对通知返回了响应: %w", err)
+	}
+	return nil
+}
+
+func checkInitialize(env *Env) error {
+	resp, err := env.Client.Call("initialize", map[string]interface{}{
+		"protocolVersion": "2024-11-05",
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "mcptest", "version": "1.0.0"},
+	})
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		ProtocolVersion string `json:"protocolVersion"`
+		Capabilities    struct {
+			Tools *struct {
+				ListChanged bool `json:"listChanged"`
+			} `json:"tools"`
+		} `json:"capabilities"`
+		ServerInfo struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if err := resp.DecodeResult(&result); err != nil {
+		return err
+	}
+
+	switch {
+	case result.ProtocolVersion == "":
+		return fmt.Errorf("缺少protocolVersion")
+	case result.Capabilities.Tools == nil:
+		return fmt.Errorf("capabilities缺少tools")
+	case !result.Capabilities.Tools.ListChanged:
+		return fmt.Errorf("capabilities.tools.listChanged应为true")
+	case result.ServerInfo.Name == "" || result.ServerInfo.Version == "":
+		return fmt.Errorf("serverInfo缺少name或version")
+	}
+	return nil
+}
+
+func checkInitializedNotification(env *Env) error {
+	if err := env.Client.Notify("notifications/initialized", nil); err != nil {
+		return err
+	}
+	return expectNoResponse(env.Client)
+}
+
+func checkUnknownNotification(env *Env) error {
+	if err := env.Client.Notify("notifications/cancelled", map[string]interface{}{"requestId": 99}); err != nil {
+		return err
+	}
+	return expectNoResponse(env.Client)
+}
+
+func checkPing(env *Env) error {
+	resp, err := env.Client.Call("ping", nil)
+	if err != nil {
+		return err
+	}
+
+	var result map[string]interface{}
+	if err := resp.DecodeResult(&result); err != nil {
+		return fmt.Errorf("ping的result应为对象: %w", err)
+	}
+	return nil
+}
+
+func checkStringID(env *Env) error {
+	_, err := env.Client.CallWithID("req-abc", "ping", nil)
+	return err
+}
+
+func checkToolsList(env *Env) error {
+	resp, err := env.Client.Call("tools/list", nil)
+	if err != nil {
+		return err
+	}
+
+	var result struct {
+		Tools []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			InputSchema struct {
+				Type       string                     `json:"type"`
+				Properties map[string]json.RawMessage `json:"properties"`
+				Required   []string                   `json:"required"`
+			} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := resp.DecodeResult(&result); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, tool := range result.Tools {
+		if tool.Name == "" || tool.Description == "" {
+			return fmt.Errorf("工具缺少name或description: %q", tool.Name)
+		}
+		if names[tool.Name] {
+			return fmt.Errorf("工具名称重复: %s", tool.Name)
+		}
+		names[tool.Name] = true
+
+		if tool.InputSchema.Type != "object" {
+			return fmt.Errorf("工具%s的inputSchema.type应为object", tool.Name)
+		}
+		for _, required := range tool.InputSchema.Required {
+			if _, ok := tool.InputSchema.Properties[required]; !ok {
+				return fmt.Errorf("工具%s的必填参数%s未在properties中定义", tool.Name, required)
+			}
+		}
+	}
+
+	for _, name := range webhookTools {
+		if !names[name] {
+			return fmt.Errorf("缺少工具%s", name)
+		}
+	}
+	if names["send_direct_message"] {
+		return fmt.Errorf("未配置应用机器人时不应提供send_direct_message")
+	}
+	return nil
+}
+
+func checkSendText(env *Env) error {
+	text := conformanceKeyword + " 一致性测试"
+	result, err := callTool(env.Client, "send_text_message", map[string]interface{}{"text": text})
+	if err != nil {
+		return err
+	}
+	if result.IsError {
+		return fmt.Errorf("工具返回错误: %s", result.text())
+	}
+
+	messages := env.Mock.Messages()
+	if len(messages) != 1 {
+		return fmt.Errorf("模拟Webhook应收到1条消息，实际%d条，请求记录: %+v", len(messages), env.Mock.Requests())
+	}
+	if got, _ := messages[0].Content["text"].(string); got != text {
+		return fmt.Errorf("消息内容不匹配: %q", got)
+	}
+	return nil
+}
+
+func checkSecurityRejection(env *Env) error {
+	result, err := callTool(env.Client, "send_text_message", map[string]interface{}{"text": "不含关键词"})
+	if err != nil {
+		return err
+	}
+	if !result.IsError {
+		return fmt.Errorf("缺少关键词的消息应返回isError")
+	}
+	if n := len(env.Mock.Requests()); n != 0 {
+		return fmt.Errorf("被拒绝的消息不应发送，模拟Webhook收到%d个请求", n)
+	}
+	return nil
+}
+
+func checkWebhookError(env *Env) error {
+	env.Mock.FailNext(feishutest.CodeSignMismatch, "sign match fail or timestamp is not within one hour from current time")
+
+	result, err := callTool(env.Client, "send_text_message", map[string]interface{}{"text": conformanceKeyword + " 错误码"})
+	if err != nil {
+		return err
+	}
+	if !result.IsError {
+		return fmt.Errorf("Webhook返回错误码时应返回isError")
+	}
+	if !strings.Contains(result.text(), fmt.Sprint(feishutest.CodeSignMismatch)) {
+		return fmt.Errorf("错误信息应包含飞书错误码: %s", result.text())
+	}
+	return nil
+}
+
+func checkDryRun(env *Env) error {
+	result, err := callTool(env.Client, "send_text_message", map[string]interface{}{
+		"text":    conformanceKeyword + " 预览",
+		"dry_run": true,
+	})
+	if err != nil {
+		return err
+	}
+	if result.IsError {
+		return fmt.Errorf("工具返回错误: %s", result.text())
+	}
+	if !strings.Contains(result.text(), `"sign"`) {
+		return fmt.Errorf("预览结果应包含签名后的请求体: %s", result.text())
+	}
+	if n := len(env.Mock.Requests()); n != 0 {
+		return fmt.Errorf("预览模式不应发送，模拟Webhook收到%d个请求", n)
+	}
+	return nil
+}
+
+func checkUnknownTool(env *Env) error {
+	result, err := callTool(env.Client, "no_such_tool", map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+	if !result.IsError {
+		return fmt.Errorf("未知工具应返回isError")
+	}
+	return nil
+}
+
+func checkUnknownMethod(env *Env) error {
+	resp, err := env.Client.Call("no/such/method", nil)
+	if err != nil {
+		return err
+	}
+	return expectError(resp, -32601)
+}
+
+func checkInvalidParams(env *Env) error {
+	resp, err := env.Client.Call("tools/call", []int{1, 2, 3})
+	if err != nil {
+		return err
+	}
+	return expectError(resp, -32602)
+}
+
+func checkParseError(env *Env) error {
+	if err := env.Client.SendRaw(`{"jsonrpc": "2.0", "id": 1, "method": `); err != nil {
+		return err
+	}
+
+	resp, err := env.Client.Next()
+	if err != nil {
+		return err
+	}
+	if err := expectError(resp, -32700); err != nil {
+		return err
+	}
+	if string(resp.ID) != "null" {
+		return fmt.Errorf("解析错误的id应为null，实际为%s", resp.ID)
+	}
+
+	// 后续请求应正常处理
+	if _, err := env.Client.Call("ping", nil); err != nil {
+		return fmt.Errorf("解析错误后服务器未继续工作: %w", err)
+	}
+	return nil
+}
+
+func checkListChanged(env *Env) error {
+	// 与真实客户端一样先完成初始化，确保服务器已开始处理消息
+	if err := checkInitialize(env); err != nil {
+		return err
+	}
+	if err := env.Client.Notify("notifications/initialized", nil); err != nil {
+		return err
+	}
+
+	cfg := env.Config
+	cfg.AppID = "cli_conformance"
+	cfg.AppSecret = "conformance-app-secret"
+	env.Client.Server().UpdateFeishuClient(feishu.NewClient(cfg))
+
+	if _, err := env.Client.WaitNotification("notifications/tools/list_changed"); err != nil {
+		return err
+	}
+
+	resp, err := env.Client.Call("tools/list", nil)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(resp.Result), `"send_direct_message"`) {
+		return fmt.Errorf("配置应用机器人后工具列表应包含send_direct_message")
+	}
+	return nil
+}
+
+func checkShutdownOnEOF(env *Env) error {
+	if _, err := env.Client.Call("ping", nil); err != nil {
+		return err
+	}
+	return env.Client.Close()
+}
@@ -0,0 +1,50 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"mcp-feishu/internal/metrics"
+	"net/http"
+	"time"
+)
+
+// metricsPath 指标端点的路径
+const metricsPath = "/metrics"
+
+// ServeMetrics 在addr上提供/metrics端点，阻塞直到Shutdown被调用或监听失败
+func (s *Server) ServeMetrics(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, metrics.Handler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	s.httpMu.Lock()
+	s.metrics = server
+	s.httpMu.Unlock()
+
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// shutdownMetrics 关闭指标端点，未开启时不做任何事
+func (s *Server) shutdownMetrics() {
+	s.httpMu.Lock()
+	server := s.metrics
+	s.httpMu.Unlock()
+	if server == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		s.logger.Warn().Err(err).Msg("关闭指标端点超时")
+	}
+}
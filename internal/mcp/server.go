@@ -1,22 +1,96 @@
 package mcp
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mcp-feishu/internal/acl"
+	"mcp-feishu/internal/audit"
 	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/quota"
+	"mcp-feishu/internal/tracing"
 	"mcp-feishu/internal/types"
+	"net/http"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Server MCP服务器
 type Server struct {
+	// mu 保护feishuClient、calls和toolsHandler，重新加载配置时整体替换
+	mu           sync.RWMutex
 	feishuClient *feishu.Client
+	// calls 使用当前飞书客户端的进行中的工具调用，替换客户端后等待这些调用结束再关闭旧客户端
+	calls        *sync.WaitGroup
 	toolsHandler *ToolsHandler
+	policy       *acl.Policy
+	quota        *quota.Limiter
 	logger       zerolog.Logger
+
+	// stdio stdio传输的会话，HTTP传输的会话见httpTransport
+	stdio *session
+
+	// writeMu 保护encoder，响应和服务器主动发送的通知可能来自不同goroutine
+	writeMu sync.Mutex
+	encoder *json.Encoder
+
+	// closing 等待进行中的调用结束后再关闭的旧客户端，Shutdown时等待全部关闭
+	closing sync.WaitGroup
+
+	// httpMu 保护http和metrics，HTTP传输和指标端点运行期间非nil
+	httpMu  sync.Mutex
+	http    *httpTransport
+	metrics *http.Server
+}
+
+// session 一个MCP会话中客户端的身份，stdio传输只有一个会话，HTTP传输按Mcp-Session-Id区分
+type session struct {
+	// mu 保护clientInfo，initialize请求中上报的客户端信息，用于访问控制和审计日志
+	mu         sync.RWMutex
+	clientInfo *audit.ClientInfo
+
+	// apiKey HTTP传输认证通过的身份名称，会话期间不变
+	apiKey string
+}
+
+// setClientInfo 记录initialize请求中上报的客户端信息
+func (c *session) setClientInfo(clientInfo *audit.ClientInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clientInfo = clientInfo
+}
+
+// principal 返回会话的调用主体，认证过的会话只使用API密钥名称，客户端上报的名称只记录在审计日志中
+func (c *session) principal() acl.Principal {
+	if c.apiKey != "" {
+		return acl.Principal{APIKey: c.apiKey}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var principal acl.Principal
+	if c.clientInfo != nil {
+		principal.Client = c.clientInfo.Name
+	}
+	return principal
+}
+
+// caller 返回写入审计日志的调用方信息
+func (c *session) caller(tool string) audit.Caller {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return audit.Caller{Client: c.clientInfo, APIKey: c.apiKey, Tool: tool}
 }
 
 // NewServer 创建MCP服务器
@@ -28,81 +102,153 @@ func NewServer(feishuClient *feishu.Client) *Server {
 
 	return &Server{
 		feishuClient: feishuClient,
+		calls:        &sync.WaitGroup{},
 		toolsHandler: toolsHandler,
 		logger:       log.With().Str("component", "mcp-server").Logger(),
+		stdio:        &session{},
 	}
 }
 
-// Run 运行MCP服务器
-func (s *Server) Run() error {
+// Run 运行MCP服务器，从input读取请求并将响应和通知写入output
+//
+// 消息按行分隔（stdio传输），input结束时返回nil。
+func (s *Server) Run(input io.Reader, output io.Writer) error {
 	s.logger.Info().Msg("启动MCP飞书服务器")
 
-	// 设置输入输出
-	input := os.Stdin
-	output := os.Stdout
-
-	// 处理请求循环
-	decoder := json.NewDecoder(input)
-	encoder := json.NewEncoder(output)
+	s.writeMu.Lock()
+	s.encoder = json.NewEncoder(output)
+	s.writeMu.Unlock()
 
+	// 按行读取而不是直接使用json.Decoder，格式错误的消息不会导致后续消息无法解析
+	reader := bufio.NewReader(input)
 	for {
-		var request types.MCPRequest
-		if err := decoder.Decode(&request); err != nil {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			s.handleMessage(line)
+		}
+		if err != nil {
 			if err == io.EOF {
 				s.logger.Info().Msg("收到EOF，退出服务器")
-				break
+				return nil
 			}
-			s.logger.Error().Err(err).Msg("解析请求失败")
-			continue
+			return fmt.Errorf("读取请求失败: %w", err)
 		}
+	}
+}
 
+// handleMessage 解析并处理一条消息，需要响应时写回客户端
+func (s *Server) handleMessage(data []byte) {
+	var request types.MCPRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		s.logger.Error().Err(err).Msg("解析请求失败")
+		// 无法确定请求id，按JSON-RPC规范返回id为null的解析错误
+		s.writeResponse(&types.MCPResponse{
+			JSONRPC: "2.0",
+			ID:      nil,
+			Error: &types.MCPError{
+				Code:    -32700,
+				Message: "解析请求失败",
+				Data:    err.Error(),
+			},
+		})
+		return
+	}
+
+	s.logger.Debug().
+		Str("method", request.Method).
+		Interface("id", request.ID).
+		Msg("收到MCP请求")
+
+	response := s.handleRequest(s.stdio, request)
+
+	// 只有非通知请求才需要发送响应
+	if response != nil {
+		s.writeResponse(response)
+	} else {
 		s.logger.Debug().
 			Str("method", request.Method).
-			Interface("id", request.ID).
-			Msg("收到MCP请求")
+			Msg("处理通知完成，无响应")
+	}
+}
 
-		response := s.handleRequest(request)
+// writeResponse 发送响应
+func (s *Server) writeResponse(response *types.MCPResponse) {
+	if err := s.write(response); err != nil {
+		s.logger.Error().Err(err).Msg("编码响应失败")
+		return
+	}
 
-		// 只有非通知请求才需要发送响应
-		if response != nil {
-			if err := encoder.Encode(response); err != nil {
-				s.logger.Error().Err(err).Msg("编码响应失败")
-				continue
-			}
+	s.logger.Debug().
+		Interface("id", response.ID).
+		Bool("has_error", response.Error != nil).
+		Msg("发送MCP响应")
+}
 
-			s.logger.Debug().
-				Interface("id", response.ID).
-				Bool("has_error", response.Error != nil).
-				Msg("发送MCP响应")
-		} else {
-			s.logger.Debug().
-				Str("method", request.Method).
-				Msg("处理通知完成，无响应")
-		}
+// write 向客户端写出一条消息
+func (s *Server) write(message interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if s.encoder == nil {
+		return fmt.Errorf("服务器尚未启动")
 	}
+	return s.encoder.Encode(message)
+}
 
-	return nil
+// notify 向客户端发送通知
+func (s *Server) notify(method string, params interface{}) {
+	notification := types.MCPRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	}
+
+	if err := s.write(notification); err != nil {
+		s.logger.Warn().Err(err).Str("method", method).Msg("发送通知失败")
+	}
 }
 
-// handleRequest 处理MCP请求
-func (s *Server) handleRequest(request types.MCPRequest) *types.MCPResponse {
+// handlers 获取当前的工具处理器，调用方在整个请求期间使用同一个快照，
+// 重新加载配置不会影响正在进行的工具调用
+func (s *Server) handlers() *ToolsHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.toolsHandler
+}
+
+// acquireHandlers 获取当前的工具处理器并登记一次进行中的工具调用，调用结束后必须调用返回的函数；
+// 在此期间替换飞书客户端时，旧客户端等到调用结束后才会关闭
+func (s *Server) acquireHandlers() (*ToolsHandler, func()) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	s.calls.Add(1)
+	return s.toolsHandler, s.calls.Done
+}
+
+// handleRequest 处理会话中的一条MCP请求
+func (s *Server) handleRequest(sess *session, request types.MCPRequest) *types.MCPResponse {
 	switch request.Method {
 	case "initialize":
-		response := s.handleInitialize(request)
+		response := s.handleInitialize(sess, request)
 		return &response
 	case "notifications/initialized":
 		s.handleInitialized(request)
 		return nil // 通知不需要响应
 	case "tools/list":
-		response := s.handleToolsList(request)
+		response := s.handleToolsList(sess, request)
 		return &response
 	case "tools/call":
-		response := s.handleToolsCall(request)
+		response := s.handleToolsCall(sess, request)
 		return &response
 	case "ping":
 		response := s.handlePing(request)
 		return &response
 	default:
+		// 未知的通知直接忽略，JSON-RPC不允许对通知返回响应
+		if request.ID == nil {
+			s.logger.Debug().Str("method", request.Method).Msg("忽略未知通知")
+			return nil
+		}
 		response := types.MCPResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
@@ -116,21 +262,26 @@ func (s *Server) handleRequest(request types.MCPRequest) *types.MCPResponse {
 }
 
 // handleInitialize 处理初始化请求
-func (s *Server) handleInitialize(request types.MCPRequest) types.MCPResponse {
+func (s *Server) handleInitialize(sess *session, request types.MCPRequest) types.MCPResponse {
 	s.logger.Info().Msg("处理初始化请求")
 
+	if clientInfo := parseClientInfo(request.Params); clientInfo != nil {
+		sess.setClientInfo(clientInfo)
+		s.logger.Info().Str("client", clientInfo.Name).Str("client_version", clientInfo.Version).Msg("MCP客户端信息")
+	}
+
 	result := map[string]interface{}{
 		"protocolVersion": "2024-11-05",
 		"capabilities": map[string]interface{}{
 			"tools": map[string]interface{}{
-				"listChanged": false,
+				"listChanged": true,
 			},
 		},
 		"serverInfo": map[string]interface{}{
 			"name":    "mcp-feishu",
 			"version": "1.0.0",
 		},
-		"instructions": "这是一个飞书消息发送MCP服务器，支持发送各种类型的飞书消息，包括文本、富文本、图片、卡片等。支持三种安全设置：无安全、签名校验、自定义关键词。",
+		"instructions": "这是一个飞书消息发送MCP服务器，支持发送各种类型的飞书消息，包括文本、富文本、图片、卡片等。支持可组合的安全设置：签名校验、自定义关键词、IP白名单。",
 	}
 
 	return types.MCPResponse{
@@ -140,6 +291,21 @@ func (s *Server) handleInitialize(request types.MCPRequest) types.MCPResponse {
 	}
 }
 
+// parseClientInfo 读取initialize请求参数中的clientInfo，不存在时返回nil
+func parseClientInfo(params interface{}) *audit.ClientInfo {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil
+	}
+	var initParams struct {
+		ClientInfo *audit.ClientInfo `json:"clientInfo"`
+	}
+	if err := json.Unmarshal(data, &initParams); err != nil || initParams.ClientInfo == nil || initParams.ClientInfo.Name == "" {
+		return nil
+	}
+	return initParams.ClientInfo
+}
+
 // handleInitialized 处理初始化完成通知（通知不需要响应）
 func (s *Server) handleInitialized(request types.MCPRequest) {
 	s.logger.Info().Msg("收到初始化完成通知")
@@ -147,10 +313,10 @@ func (s *Server) handleInitialized(request types.MCPRequest) {
 }
 
 // handleToolsList 处理工具列表请求
-func (s *Server) handleToolsList(request types.MCPRequest) types.MCPResponse {
+func (s *Server) handleToolsList(sess *session, request types.MCPRequest) types.MCPResponse {
 	s.logger.Info().Msg("处理工具列表请求")
 
-	tools := s.toolsHandler.GetTools()
+	tools := s.handlers().ToolsFor(sess.principal())
 
 	result := map[string]interface{}{
 		"tools": tools,
@@ -164,7 +330,7 @@ func (s *Server) handleToolsList(request types.MCPRequest) types.MCPResponse {
 }
 
 // handleToolsCall 处理工具调用请求
-func (s *Server) handleToolsCall(request types.MCPRequest) types.MCPResponse {
+func (s *Server) handleToolsCall(sess *session, request types.MCPRequest) types.MCPResponse {
 	s.logger.Info().Msg("处理工具调用请求")
 
 	// 解析参数
@@ -184,6 +350,7 @@ func (s *Server) handleToolsCall(request types.MCPRequest) types.MCPResponse {
 	var params struct {
 		Name      string                 `json:"name"`
 		Arguments map[string]interface{} `json:"arguments"`
+		Meta      map[string]interface{} `json:"_meta"`
 	}
 
 	if err := json.Unmarshal(paramsBytes, &params); err != nil {
@@ -198,10 +365,13 @@ func (s *Server) handleToolsCall(request types.MCPRequest) types.MCPResponse {
 		}
 	}
 
-	s.logger.Info().
+	event := s.logger.Info().
 		Str("tool_name", params.Name).
-		Interface("arguments", params.Arguments).
-		Msg("调用工具")
+		Interface("arguments", params.Arguments)
+	if sess.apiKey != "" {
+		event = event.Str("api_key", sess.apiKey)
+	}
+	event.Msg("调用工具")
 
 	// 调用工具
 	toolCall := types.ToolCall{
@@ -209,8 +379,23 @@ func (s *Server) handleToolsCall(request types.MCPRequest) types.MCPResponse {
 		Arguments: params.Arguments,
 	}
 
-	result, err := s.toolsHandler.CallTool(toolCall)
+	ctx, span := tracing.Tracer().Start(tracing.Extract(context.Background(), params.Meta), "tools/call",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", "tools/call"),
+			attribute.String("mcp.tool.name", params.Name),
+		))
+	defer span.End()
+
+	ctx = audit.WithCaller(ctx, sess.caller(params.Name))
+	ctx = acl.WithPrincipal(ctx, sess.principal())
+
+	toolsHandler, done := s.acquireHandlers()
+	defer done()
+	result, err := toolsHandler.CallTool(ctx, toolCall)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return types.MCPResponse{
 			JSONRPC: "2.0",
 			ID:      request.ID,
@@ -241,18 +426,118 @@ func (s *Server) handlePing(request types.MCPRequest) types.MCPResponse {
 }
 
 // UpdateFeishuClient 更新飞书客户端
+//
+// 新客户端整体替换旧客户端，正在进行的工具调用继续使用旧客户端完成，全部结束后在后台关闭旧客户端。
+// 当前客户端可见的工具列表发生变化时向客户端发送 notifications/tools/list_changed。
 func (s *Server) UpdateFeishuClient(feishuClient *feishu.Client) {
+	s.mu.Lock()
+	toolsHandler := s.newToolsHandler(feishuClient)
+	oldTools := toolNames(s.toolsHandler.ToolsFor(s.stdio.principal()))
+	oldClient, oldCalls := s.feishuClient, s.calls
 	s.feishuClient = feishuClient
-	s.toolsHandler = NewToolsHandler(feishuClient)
+	s.calls = &sync.WaitGroup{}
+	s.toolsHandler = toolsHandler
+	s.mu.Unlock()
+
+	// 旧客户端上的调用结束后，摘要中的消息按旧配置发送出去
+	s.closing.Add(1)
+	go func() {
+		defer s.closing.Done()
+		oldCalls.Wait()
+		oldClient.Close()
+	}()
+
 	s.logger.Info().Msg("飞书客户端配置已更新")
+	s.notifyToolsChanged(oldTools, toolNames(toolsHandler.ToolsFor(s.stdio.principal())))
+}
+
+// SetPolicy 设置访问控制策略，传入nil取消限制，当前客户端可见的工具变化时通知客户端
+func (s *Server) SetPolicy(policy *acl.Policy) {
+	for _, pattern := range policy.ToolPatterns() {
+		if !strings.ContainsAny(pattern, "*?[") && !knownTools[pattern] {
+			s.logger.Warn().Str("tool", pattern).Msg("访问控制策略中的工具不存在，请检查拼写")
+		}
+	}
+
+	s.mu.Lock()
+	oldTools := toolNames(s.toolsHandler.ToolsFor(s.stdio.principal()))
+	s.policy = policy
+	toolsHandler := s.newToolsHandler(s.feishuClient)
+	s.toolsHandler = toolsHandler
+	s.mu.Unlock()
+
+	s.logger.Info().Bool("enabled", policy != nil).Msg("访问控制策略已更新")
+	s.notifyToolsChanged(oldTools, toolNames(toolsHandler.ToolsFor(s.stdio.principal())))
+}
+
+// SetQuota 设置发送配额和熔断，传入nil取消限制；重新加载配置时通过Limiter.Update更新限制，已有计数保留
+func (s *Server) SetQuota(limiter *quota.Limiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = limiter
+	s.toolsHandler = s.newToolsHandler(s.feishuClient)
+}
+
+// newToolsHandler 按当前的访问控制策略和配额创建工具处理器，调用方需持有s.mu
+func (s *Server) newToolsHandler(feishuClient *feishu.Client) *ToolsHandler {
+	toolsHandler := NewToolsHandler(feishuClient)
+	toolsHandler.SetPolicy(s.policy)
+	toolsHandler.SetQuota(s.quota)
+	return toolsHandler
+}
+
+// notifyToolsChanged 工具列表变化且服务器已启动时发送tools/list_changed通知
+func (s *Server) notifyToolsChanged(oldTools, newTools []string) {
+	if reflect.DeepEqual(oldTools, newTools) {
+		return
+	}
+
+	s.writeMu.Lock()
+	running := s.encoder != nil
+	s.writeMu.Unlock()
+	if !running {
+		return
+	}
+
+	s.logger.Info().Strs("tools", newTools).Msg("工具列表已变化，通知客户端")
+	s.notify("notifications/tools/list_changed", nil)
 }
 
 // GetFeishuClient 获取飞书客户端
 func (s *Server) GetFeishuClient() *feishu.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.feishuClient
 }
 
+// toolNames 提取排序后的工具名称列表
+func toolNames(tools []types.Tool) []string {
+	names := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Shutdown 关闭服务器
 func (s *Server) Shutdown() {
 	s.logger.Info().Msg("关闭MCP飞书服务器")
+
+	s.httpMu.Lock()
+	transport := s.http
+	s.httpMu.Unlock()
+	if transport != nil {
+		transport.shutdown()
+	}
+
+	// 进行中的调用结束后发送摘要中剩余的消息，并等待重新加载前的旧客户端关闭
+	s.mu.RLock()
+	client, calls := s.feishuClient, s.calls
+	s.mu.RUnlock()
+	calls.Wait()
+	client.Close()
+	s.closing.Wait()
+
+	s.shutdownMetrics()
 }
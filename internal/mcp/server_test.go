@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"io"
+	"mcp-feishu/internal/acl"
+	"mcp-feishu/internal/audit"
+	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionPrincipal(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiKey     string
+		clientInfo *audit.ClientInfo
+		want       acl.Principal
+	}{
+		{"stdio按客户端名称", "", &audit.ClientInfo{Name: "claude-ai"}, acl.Principal{Client: "claude-ai"}},
+		{"未initialize", "", nil, acl.Principal{}},
+		{"认证过的会话忽略上报的名称", "deploy-bot", &audit.ClientInfo{Name: "claude-ai"}, acl.Principal{APIKey: "deploy-bot"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &session{apiKey: tt.apiKey}
+			sess.setClientInfo(tt.clientInfo)
+			if got := sess.principal(); got != tt.want {
+				t.Errorf("principal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpdateFeishuClientDrainsCalls 重新加载配置时，已经拿到旧客户端的工具调用仍能加入旧客户端的摘要，
+// 旧客户端等这些调用结束后才关闭并发送摘要
+func TestUpdateFeishuClientDrainsCalls(t *testing.T) {
+	oldMock := feishutest.Start(feishutest.Options{DisableRateLimit: true})
+	defer oldMock.Close()
+	newMock := feishutest.Start(feishutest.Options{DisableRateLimit: true})
+	defer newMock.Close()
+
+	digest := types.DigestConfig{WindowSeconds: 3600}
+	server := NewServer(feishu.NewClient(types.FeishuConfig{WebhookURL: oldMock.URL(), Digest: digest}))
+
+	// 模拟重新加载时正在进行的调用
+	inFlight, done := server.acquireHandlers()
+	server.UpdateFeishuClient(feishu.NewClient(types.FeishuConfig{WebhookURL: newMock.URL(), Digest: digest}))
+
+	closed := make(chan struct{})
+	go func() {
+		server.closing.Wait()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("旧客户端在进行中的调用结束前就已关闭")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	result, err := inFlight.CallTool(context.Background(), types.ToolCall{
+		Name:      "send_text_message",
+		Arguments: map[string]interface{}{"text": "重新加载前的消息"},
+	})
+	if err != nil || result.IsError {
+		t.Fatalf("CallTool() = %+v, %v", result, err)
+	}
+	done()
+
+	server.Shutdown()
+	if messages := oldMock.Messages(); len(messages) != 1 || !strings.Contains(messages[0].Body, "重新加载前的消息") {
+		t.Errorf("旧Webhook收到 %+v, want 1条摘要", messages)
+	}
+	if messages := newMock.Messages(); len(messages) != 0 {
+		t.Errorf("新Webhook收到 %+v, want 0条", messages)
+	}
+}
+
+// TestServeMetricsShutdown 关闭服务器时一并关闭指标端点
+func TestServeMetricsShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	server := NewServer(feishu.NewClient(types.FeishuConfig{}))
+	served := make(chan error, 1)
+	go func() { served <- server.ServeMetrics(addr) }()
+
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if resp, err = http.Get("http://" + addr + metricsPath); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("请求指标端点失败: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "mcp_feishu_queue_depth") {
+		t.Errorf("指标端点输出 = %s", body)
+	}
+
+	server.Shutdown()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("ServeMetrics() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown后指标端点仍在运行")
+	}
+}
@@ -1,15 +1,32 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mcp-feishu/internal/acl"
 	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/metrics"
+	"mcp-feishu/internal/quota"
+	"mcp-feishu/internal/tracing"
 	"mcp-feishu/internal/types"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ToolsHandler 工具处理器
 type ToolsHandler struct {
 	feishuClient *feishu.Client
+	// policy 访问控制策略，为nil时不限制
+	policy *acl.Policy
+	// quota 发送配额和熔断状态，为nil时不限制
+	quota *quota.Limiter
 }
 
 // NewToolsHandler 创建工具处理器
@@ -19,26 +36,88 @@ func NewToolsHandler(feishuClient *feishu.Client) *ToolsHandler {
 	}
 }
 
+// SetPolicy 设置访问控制策略，传入nil取消限制
+func (th *ToolsHandler) SetPolicy(policy *acl.Policy) {
+	th.policy = policy
+}
+
+// SetQuota 设置发送配额和熔断，传入nil取消限制
+func (th *ToolsHandler) SetQuota(limiter *quota.Limiter) {
+	th.quota = limiter
+}
+
+// ToolsFor 返回主体有权调用的工具，未配置访问控制策略时返回全部工具
+func (th *ToolsHandler) ToolsFor(principal acl.Principal) []types.Tool {
+	tools := th.GetTools()
+	if th.policy == nil {
+		return tools
+	}
+
+	rule := th.policy.RuleFor(principal)
+	allowed := make([]types.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if rule.AllowsTool(tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+	return allowed
+}
+
+// checkAccess 按访问控制策略检查ctx中的主体能否调用工具并发送到对应目标
+func (th *ToolsHandler) checkAccess(ctx context.Context, toolCall types.ToolCall) error {
+	if th.policy == nil {
+		return nil
+	}
+
+	principal := acl.PrincipalFromContext(ctx)
+	rule := th.policy.RuleFor(principal)
+	if !rule.AllowsTool(toolCall.Name) {
+		return fmt.Errorf("%s无权调用工具 %s", principal, toolCall.Name)
+	}
+	if target := toolTarget(toolCall); target != "" && !rule.AllowsTarget(target) {
+		return fmt.Errorf("%s无权向 %s 发送消息", principal, target)
+	}
+	return nil
+}
+
+// toolTarget 返回工具调用的消息目标，群消息工具按target参数区分命名目标，不发送消息的工具返回空字符串
+func toolTarget(toolCall types.ToolCall) string {
+	if _, ok := webhookToolTypes[toolCall.Name]; ok {
+		if name, _ := toolCall.Arguments["target"].(string); name != "" {
+			return acl.WebhookTarget(name)
+		}
+		return acl.TargetWebhook
+	}
+	if toolCall.Name == "send_direct_message" {
+		// receive_id缺失时由工具自身返回参数错误
+		if receiveID, _ := toolCall.Arguments["receive_id"].(string); receiveID != "" {
+			return acl.DirectTarget(receiveID)
+		}
+	}
+	return ""
+}
+
 // GetTools 获取所有可用工具
 func (th *ToolsHandler) GetTools() []types.Tool {
-	return []types.Tool{
+	tools := []types.Tool{
 		{
 			Name:        "send_text_message",
-			Description: "发送纯文本消息\n\n发送简单的文本消息，支持自动换行和基本文本格式。这是最基础的消息类型，适合发送通知、状态更新等简单信息。会自动应用配置的安全设置。\n\n示例：{\"text\": \"系统维护通知：服务将在今晚22:00-24:00进行维护\"}",
+			Description: "发送纯文本消息\n\n发送简单的文本消息，支持自动换行和基本文本格式。这是最基础的消息类型，适合发送通知、状态更新等简单信息。会自动应用配置的安全设置。\n\n支持@提及：在文本中写 @all 提及所有人，写 @邮箱 或 @名字 提及具体用户（需配置用户目录或应用机器人），会自动转换为飞书at标签，无法识别的用户会返回错误。\n\n示例：{\"text\": \"系统维护通知：服务将在今晚22:00-24:00进行维护\"}",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"text": map[string]interface{}{
 						"type":        "string",
-						"description": "要发送的纯文本内容，支持换行符。最大长度为30000字符。如果配置了关键词验证，文本必须包含指定关键词。",
+						"description": "要发送的纯文本内容，支持换行符和@提及（@all、@邮箱、@名字）。最大长度为30000字符。如果配置了关键词验证，文本必须包含指定关键词。",
 					},
+					"dry_run": dryRunProperty(),
 				},
 				"required": []string{"text"},
 			},
 		},
 		{
 			Name:        "send_post_message",
-			Description: "发送富文本消息\n\n发送支持格式化的富文本消息，可以包含加粗、斜体、链接、@用户、图片等丰富格式。支持可选标题。AI只需提供内容数组和可选标题，工具会自动包装成飞书API格式。\n\n示例1（无标题）：{\"content\": [[{\"tag\": \"text\", \"text\": \"访问\"}, {\"tag\": \"a\", \"text\": \"飞书官网\", \"href\": \"https://feishu.cn\"}]]}\n示例2（有标题）：{\"title\": \"数据报告\", \"content\": [[{\"tag\": \"text\", \"text\": \"本月销售额增长15%\"}]]}",
+			Description: "发送富文本消息\n\n发送支持格式化的富文本消息，可以包含加粗、斜体、链接、@用户、图片等丰富格式。支持可选标题。AI只需提供内容数组和可选标题，工具会自动包装成飞书API格式。text元素中的 @all、@邮箱、@名字 会自动拆分为at元素。\n\n示例1（无标题）：{\"content\": [[{\"tag\": \"text\", \"text\": \"访问\"}, {\"tag\": \"a\", \"text\": \"飞书官网\", \"href\": \"https://feishu.cn\"}]]}\n示例2（有标题）：{\"title\": \"数据报告\", \"content\": [[{\"tag\": \"text\", \"text\": \"本月销售额增长15%\"}]]}",
 			InputSchema: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
@@ -50,8 +129,9 @@ func (th *ToolsHandler) GetTools() []types.Tool {
 						"type":        "array",
 						"description": "富文本内容数组，二维数组格式。每行是一个元素数组，元素可以是文本、链接、@用户等。支持的元素类型：text(文本)、a(链接)、at(提及用户)、img(图片)等。工具会自动包装成飞书API需要的post结构。",
 					},
+					"i18n":    i18nPostProperty(),
+					"dry_run": dryRunProperty(),
 				},
-				"required": []string{"content"},
 			},
 		},
 		{
@@ -64,6 +144,7 @@ func (th *ToolsHandler) GetTools() []types.Tool {
 						"type":        "string",
 						"description": "飞书图片资源的唯一标识符，格式通常为 img_v2_ 开头的字符串。需要先通过飞书上传图片接口获取此值。image_key有效期通常为24小时。",
 					},
+					"dry_run": dryRunProperty(),
 				},
 				"required": []string{"image_key"},
 			},
@@ -84,10 +165,11 @@ func (th *ToolsHandler) GetTools() []types.Tool {
 					},
 					"header": map[string]interface{}{
 						"type":        "object",
-						"description": "可选的卡片头部配置，包含标题、副标题、模板样式等。格式：{\"title\": {\"tag\": \"plain_text\", \"content\": \"标题\"}, \"template\": \"blue\"}。template可选值：blue、wathet、turquoise、green、yellow、orange、red、carmine、violet、purple、indigo、grey",
+						"description": "可选的卡片头部配置，包含标题、副标题、模板样式等。格式：{\"title\": {\"tag\": \"plain_text\", \"content\": \"标题\"}, \"template\": \"blue\"}。template可选值：blue、wathet、turquoise、green、yellow、orange、red、carmine、violet、purple、indigo、grey。多语言标题写在title.i18n中：{\"title\": {\"tag\": \"plain_text\", \"content\": \"发布通知\", \"i18n\": {\"zh_cn\": \"发布通知\", \"en_us\": \"Release\"}}}",
 					},
+					"i18n_elements": i18nElementsProperty(),
+					"dry_run":       dryRunProperty(),
 				},
-				"required": []string{"elements"},
 			},
 		},
 		{
@@ -98,28 +180,284 @@ func (th *ToolsHandler) GetTools() []types.Tool {
 				"properties": map[string]interface{}{
 					"share_chat_id": map[string]interface{}{
 						"type":        "string",
-						"description": "要分享的群聊的唯一标识符，格式通常为 oc_ 开头的字符串。可以通过飞书群聊设置或API获取。机器人必须是该群聊的成员才能分享。",
+						"description": "要分享的群聊的唯一标识符，格式通常为 oc_ 开头的字符串。可以通过飞书群聊设置获取；配置了应用机器人时可以使用find_chat或list_chats工具查询。机器人必须是该群聊的成员才能分享。",
 					},
+					"dry_run": dryRunProperty(),
 				},
 				"required": []string{"share_chat_id"},
 			},
 		},
 	}
+
+	// 配置了命名目标时群消息工具可以选择目标，并提供广播工具
+	if len(th.feishuClient.TargetNames()) > 0 {
+		property := targetProperty(th.feishuClient)
+		for _, tool := range tools {
+			schema := tool.InputSchema.(map[string]interface{})
+			schema["properties"].(map[string]interface{})["target"] = property
+		}
+		tools = append(tools, broadcastMessageTool(th.feishuClient))
+	}
+	// 应用机器人模式下才提供私聊和群聊查询工具
+	if th.feishuClient.HasAppClient() {
+		tools = append(tools, directMessageTool(), listChatsTool(), findChatTool())
+	}
+
+	return tools
+}
+
+// listChatsTool 群聊列表工具定义
+func listChatsTool() types.Tool {
+	return types.Tool{
+		Name:        "list_chats",
+		Description: "列出机器人所在的群聊\n\n通过飞书应用机器人分页列出机器人已加入的群聊，返回每个群的chat_id(oc_开头)和名称。获取到的chat_id可以直接用于send_share_chat_message等工具。结果较多时使用返回的page_token获取下一页。\n\n示例：{\"page_size\": 20}",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"page_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "可选的每页数量，范围1-100，默认100。",
+				},
+				"page_token": map[string]interface{}{
+					"type":        "string",
+					"description": "可选的分页标记，使用上一次调用返回的page_token获取下一页。",
+				},
+			},
+		},
+	}
+}
+
+// findChatTool 查找群聊工具定义
+func findChatTool() types.Tool {
+	return types.Tool{
+		Name:        "find_chat",
+		Description: "按名称查找群聊ID\n\n通过飞书应用机器人按群名称搜索群聊，返回匹配的chat_id列表，名称完全相同的群排在最前面。适合在只知道群名时获取send_share_chat_message需要的share_chat_id。\n\n示例：{\"name\": \"研发值班群\"}",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "要查找的群聊名称，支持部分匹配。",
+				},
+			},
+			"required": []string{"name"},
+		},
+	}
+}
+
+// directMessageTool 私聊消息工具定义
+func directMessageTool() types.Tool {
+	properties := messageProperties()
+	properties["receive_id"] = map[string]interface{}{
+		"type":        "string",
+		"description": "接收者标识，可以是邮箱、open_id(ou_开头)、user_id或union_id(on_开头)。",
+	}
+	properties["receive_id_type"] = map[string]interface{}{
+		"type":        "string",
+		"enum":        []string{"email", "open_id", "user_id", "union_id"},
+		"description": "可选的接收者ID类型。不提供时根据receive_id格式自动推断：包含@为email，ou_开头为open_id，on_开头为union_id，其余为user_id。",
+	}
+
+	return types.Tool{
+		Name:        "send_direct_message",
+		Description: "发送私聊消息\n\n通过飞书应用机器人直接向指定用户发送私聊消息，而不是发送到群组。适合值班告警、审批提醒等需要通知具体负责人的场景。支持所有消息类型，消息参数与对应的群消息工具相同。接收者可以用邮箱、open_id、user_id或union_id指定，邮箱会自动解析为open_id。\n\n示例1：{\"receive_id\": \"alice@example.com\", \"text\": \"线上告警：订单服务错误率超过5%\"}\n示例2：{\"receive_id\": \"ou_7d8a6e6df7621556ce0d21922b676706ccs\", \"msg_type\": \"post\", \"title\": \"值班交接\", \"content\": [[{\"tag\": \"text\", \"text\": \"今日无遗留问题\"}]]}",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   []string{"receive_id"},
+		},
+	}
+}
+
+// messageProperties 按msg_type发送任意类型消息的工具共用的参数定义，参数格式与对应的群消息工具相同
+func messageProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"msg_type": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"text", "post", "image", "interactive", "share_chat"},
+			"description": "消息类型，默认text。不同类型需要的参数与对应的群消息工具相同。",
+		},
+		"text": map[string]interface{}{
+			"type":        "string",
+			"description": "text类型：纯文本内容。",
+		},
+		"title": map[string]interface{}{
+			"type":        "string",
+			"description": "post类型：可选的消息标题。",
+		},
+		"content": map[string]interface{}{
+			"type":        "array",
+			"description": "post类型：富文本内容二维数组，格式同send_post_message。",
+		},
+		"image_key": map[string]interface{}{
+			"type":        "string",
+			"description": "image类型：飞书图片资源标识符。",
+		},
+		"config": map[string]interface{}{
+			"type":        "object",
+			"description": "interactive类型：可选的卡片全局配置。",
+		},
+		"elements": map[string]interface{}{
+			"type":        "array",
+			"description": "interactive类型：卡片内容元素数组，格式同send_interactive_message。",
+		},
+		"header": map[string]interface{}{
+			"type":        "object",
+			"description": "interactive类型：可选的卡片头部配置。",
+		},
+		"i18n":          i18nPostProperty(),
+		"i18n_elements": i18nElementsProperty(),
+		"share_chat_id": map[string]interface{}{
+			"type":        "string",
+			"description": "share_chat类型：要分享的群聊ID。",
+		},
+		"dry_run": dryRunProperty(),
+	}
+}
+
+// webhookToolTypes 群机器人消息工具对应的消息类型
+var webhookToolTypes = map[string]types.MessageType{
+	"send_text_message":        types.MessageTypeText,
+	"send_post_message":        types.MessageTypePost,
+	"send_image_message":       types.MessageTypeImage,
+	"send_interactive_message": types.MessageTypeInteractive,
+	"send_share_chat_message":  types.MessageTypeShareChat,
+}
+
+// targetProperty 群消息工具的目标参数定义
+func targetProperty(client *feishu.Client) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "string",
+		"enum":        append([]string{types.DefaultTargetName}, client.TargetNames()...),
+		"description": "可选的群机器人目标名称，默认发送到webhook（默认Webhook）。消息按目标各自的签名密钥和关键词构建，目标配置了摘要模式时文本消息按目标合并发送。发送到多个目标请使用broadcast_message。",
+	}
 }
 
-// CallTool 调用工具
-func (th *ToolsHandler) CallTool(toolCall types.ToolCall) (types.ToolResult, error) {
+// i18nPostProperty 富文本按语言提供标题和内容的参数定义
+func i18nPostProperty() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "object",
+		"description": "post类型：可选，按语言提供标题和内容，飞书按读者客户端的语言展示。键为语言(zh_cn、en_us、ja_jp)，值为 {\"title\": \"...\", \"content\": [[...]]}，content格式与顶层content相同。可以与顶层title/content（作为zh_cn）同时使用，i18n中的同名语言优先。示例：{\"zh_cn\": {\"title\": \"发布公告\", \"content\": [[{\"tag\": \"text\", \"text\": \"新版本已上线\"}]]}, \"en_us\": {\"title\": \"Release\", \"content\": [[{\"tag\": \"text\", \"text\": \"New version is live\"}]]}}",
+	}
+}
+
+// i18nElementsProperty 卡片按语言提供内容的参数定义
+func i18nElementsProperty() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "object",
+		"description": "interactive类型：可选，按语言提供卡片内容元素，飞书按读者客户端的语言展示，代替elements。键为语言(zh_cn、en_us、ja_jp)，值为与elements格式相同的数组。示例：{\"zh_cn\": [{\"tag\": \"div\", \"text\": {\"tag\": \"plain_text\", \"content\": \"服务已恢复\"}}], \"en_us\": [{\"tag\": \"div\", \"text\": {\"tag\": \"plain_text\", \"content\": \"Service recovered\"}}]}",
+	}
+}
+
+// dryRunProperty 预览参数定义，所有发送消息的工具都支持
+func dryRunProperty() map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "boolean",
+		"description": "可选，为true时只构建消息并应用安全设置（签名、关键词），返回最终请求体而不实际发送，用于发送前检查。服务器配置了dry_run时始终为预览模式。",
+	}
+}
+
+// knownTools 全部工具名称，未知的工具名在指标中统一记为unknown，避免标签无限增长
+var knownTools = map[string]bool{
+	"send_text_message":        true,
+	"send_post_message":        true,
+	"send_image_message":       true,
+	"send_interactive_message": true,
+	"send_share_chat_message":  true,
+	"send_direct_message":      true,
+	"broadcast_message":        true,
+	"list_chats":               true,
+	"find_chat":                true,
+}
+
+// CallTool 调用工具，记录调用次数、结果和耗时，ctx中的trace上下文会传播到飞书请求
+func (th *ToolsHandler) CallTool(ctx context.Context, toolCall types.ToolCall) (types.ToolResult, error) {
+	tool := toolCall.Name
+	if !knownTools[tool] {
+		tool = "unknown"
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "tool "+tool, trace.WithAttributes(
+		attribute.String("mcp.tool.name", tool),
+		attribute.Bool("mcp.tool.dry_run", th.isDryRun(toolCall.Arguments)),
+	))
+	defer span.End()
+
+	start := time.Now()
+	result, err := th.callTool(ctx, toolCall)
+
+	outcome := metrics.ResultSuccess
+	if err != nil {
+		outcome = metrics.ResultFailure
+		tracing.RecordError(span, err)
+	} else if result.IsError {
+		outcome = metrics.ResultError
+		span.SetStatus(codes.Error, "工具返回错误结果")
+	}
+	span.SetAttributes(attribute.String("mcp.tool.result", outcome))
+	metrics.ToolCalls.WithLabelValues(tool, outcome).Inc()
+	metrics.ToolCallDuration.WithLabelValues(tool).Observe(time.Since(start).Seconds())
+
+	return result, err
+}
+
+// callTool 按工具名称分发调用
+func (th *ToolsHandler) callTool(ctx context.Context, toolCall types.ToolCall) (types.ToolResult, error) {
+	if err := th.checkAccess(ctx, toolCall); err != nil {
+		log.Warn().Err(err).Msg("工具调用被访问控制策略拒绝")
+		return newErrorResult(err.Error()), nil
+	}
+
+	// 群机器人消息发送到target参数指定的目标
+	client := th.feishuClient
+	if msgType, ok := webhookToolTypes[toolCall.Name]; ok {
+		var err error
+		if client, err = th.webhookClient(toolCall.Arguments); err != nil {
+			return newErrorResult(err.Error()), nil
+		}
+
+		// 预览模式下只构建不发送
+		if th.isDryRun(toolCall.Arguments) {
+			req, err := th.buildMessageFromArgs(client.MessageBuilder(), msgType, toolCall.Arguments)
+			if err != nil {
+				return newErrorResult(fmt.Sprintf("构建消息失败: %v", err)), nil
+			}
+			target := ""
+			if name, _ := toolCall.Arguments["target"].(string); name != "" {
+				target = "目标: " + name
+			}
+			return newDryRunResult(req, target), nil
+		}
+	}
+
+	// 预览模式不实际发送，不占用配额
+	if target := toolTarget(toolCall); target != "" && th.quota != nil && !th.isDryRun(toolCall.Arguments) {
+		ticket, err := th.acquireQuota(ctx, target)
+		if err != nil {
+			return newErrorResult(err.Error()), nil
+		}
+		defer ticket.Release()
+		ctx = quota.WithTicket(ctx, ticket)
+	}
+
 	switch toolCall.Name {
 	case "send_text_message":
-		return th.handleSendTextMessage(toolCall.Arguments)
+		return th.handleSendTextMessage(ctx, client, toolCall.Arguments)
 	case "send_post_message":
-		return th.handleSendPostMessage(toolCall.Arguments)
+		return th.handleSendPostMessage(ctx, client, toolCall.Arguments)
 	case "send_image_message":
-		return th.handleSendImageMessage(toolCall.Arguments)
+		return th.handleSendImageMessage(ctx, client, toolCall.Arguments)
 	case "send_interactive_message":
-		return th.handleSendInteractiveMessage(toolCall.Arguments)
+		return th.handleSendInteractiveMessage(ctx, client, toolCall.Arguments)
 	case "send_share_chat_message":
-		return th.handleSendShareChatMessage(toolCall.Arguments)
+		return th.handleSendShareChatMessage(ctx, client, toolCall.Arguments)
+	case "send_direct_message":
+		return th.handleSendDirectMessage(ctx, toolCall.Arguments)
+	case "broadcast_message":
+		return th.handleBroadcastMessage(ctx, toolCall.Arguments)
+	case "list_chats":
+		return th.handleListChats(toolCall.Arguments)
+	case "find_chat":
+		return th.handleFindChat(toolCall.Arguments)
 	default:
 		return types.ToolResult{
 			IsError: true,
@@ -133,8 +471,43 @@ func (th *ToolsHandler) CallTool(toolCall types.ToolCall) (types.ToolResult, err
 	}
 }
 
+// webhookClient 返回target参数指定的群机器人目标，未提供时为默认Webhook
+func (th *ToolsHandler) webhookClient(args map[string]interface{}) (*feishu.Client, error) {
+	raw, ok := args["target"]
+	if !ok {
+		return th.feishuClient, nil
+	}
+	name, ok := raw.(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("target 参数必须是非空字符串")
+	}
+	client, ok := th.feishuClient.Target(name)
+	if !ok {
+		available := append([]string{types.DefaultTargetName}, th.feishuClient.TargetNames()...)
+		return nil, fmt.Errorf("未知的目标: %s，可用目标: %s", name, strings.Join(available, ", "))
+	}
+	return client, nil
+}
+
+// acquireQuota 为ctx中的主体向目标发送一条消息占用配额，被拒绝时记录指标和日志
+func (th *ToolsHandler) acquireQuota(ctx context.Context, target string) (*quota.Ticket, error) {
+	principal := acl.PrincipalFromContext(ctx).String()
+	ticket, err := th.quota.Acquire(principal, target)
+	if err != nil {
+		reason := quota.ReasonBreaker
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			reason = exceeded.Reason
+		}
+		metrics.QuotaRejections.WithLabelValues(reason).Inc()
+		log.Warn().Err(err).Str("principal", principal).Str("target", target).Msg("工具调用被配额限制拒绝")
+		return nil, err
+	}
+	return ticket, nil
+}
+
 // handleSendTextMessage 处理发送文本消息
-func (th *ToolsHandler) handleSendTextMessage(args map[string]interface{}) (types.ToolResult, error) {
+func (th *ToolsHandler) handleSendTextMessage(ctx context.Context, client *feishu.Client, args map[string]interface{}) (types.ToolResult, error) {
 	text, ok := args["text"].(string)
 	if !ok {
 		return types.ToolResult{
@@ -148,7 +521,30 @@ func (th *ToolsHandler) handleSendTextMessage(args map[string]interface{}) (type
 		}, nil
 	}
 
-	resp, err := th.feishuClient.SendTextMessage(text)
+	text, err := th.feishuClient.ResolveTextMentions(text)
+	if err != nil {
+		return newErrorResult(fmt.Sprintf("解析@提及失败: %v", err)), nil
+	}
+
+	// 摘要模式下合并发送，带@提及的消息需要及时通知对方，仍然立即发送
+	if digest := client.Digest(); digest != nil && !strings.Contains(text, "<at ") {
+		count, flushAt, err := digest.Add(ctx, text)
+		if err != nil {
+			return newErrorResult(fmt.Sprintf("加入摘要失败: %v", err)), nil
+		}
+		// 加入摘要即占用配额，合并发送后再报告结果
+		quota.Hold(ctx)
+		return types.ToolResult{
+			Content: []interface{}{
+				map[string]interface{}{
+					"type": "text",
+					"text": fmt.Sprintf("消息已加入摘要（当前窗口第%d条），将在%s合并发送", count, flushAt.Format("15:04:05")),
+				},
+			},
+		}, nil
+	}
+
+	resp, err := client.SendTextMessage(ctx, text)
 	if err != nil {
 		return types.ToolResult{
 			IsError: true,
@@ -172,44 +568,14 @@ func (th *ToolsHandler) handleSendTextMessage(args map[string]interface{}) (type
 }
 
 // handleSendPostMessage 处理发送富文本消息
-// AI只需提供内容数组和可选标题，工具内部自动包装成完整结构
-func (th *ToolsHandler) handleSendPostMessage(args map[string]interface{}) (types.ToolResult, error) {
-	// title是可选参数
-	title, _ := args["title"].(string)
-
-	content, ok := args["content"]
-	if !ok {
-		return types.ToolResult{
-			IsError: true,
-			Content: []interface{}{
-				map[string]interface{}{
-					"type": "text",
-					"text": "content 参数是必需的",
-				},
-			},
-		}, nil
-	}
-
-	// 内部自动包装成完整的post结构
-	var postData map[string]interface{}
-	if title != "" {
-		// 有标题的富文本消息
-		postData = map[string]interface{}{
-			"zh_cn": map[string]interface{}{
-				"title":   title,
-				"content": content, // AI直接提供的内容数组
-			},
-		}
-	} else {
-		// 无标题的富文本消息
-		postData = map[string]interface{}{
-			"zh_cn": map[string]interface{}{
-				"content": content, // AI直接提供的内容数组
-			},
-		}
+// AI只需提供内容数组和可选标题，或按语言提供的i18n，工具内部自动包装成完整结构
+func (th *ToolsHandler) handleSendPostMessage(ctx context.Context, client *feishu.Client, args map[string]interface{}) (types.ToolResult, error) {
+	posts, err := th.postLocalesFromArgs(args)
+	if err != nil {
+		return newErrorResult(err.Error()), nil
 	}
 
-	resp, err := th.feishuClient.SendRichTextMessage(postData)
+	resp, err := client.SendPostMessage(ctx, posts)
 	if err != nil {
 		return types.ToolResult{
 			IsError: true,
@@ -233,7 +599,7 @@ func (th *ToolsHandler) handleSendPostMessage(args map[string]interface{}) (type
 }
 
 // handleSendImageMessage 处理发送图片消息
-func (th *ToolsHandler) handleSendImageMessage(args map[string]interface{}) (types.ToolResult, error) {
+func (th *ToolsHandler) handleSendImageMessage(ctx context.Context, client *feishu.Client, args map[string]interface{}) (types.ToolResult, error) {
 	imageKey, ok := args["image_key"].(string)
 	if !ok {
 		return types.ToolResult{
@@ -247,7 +613,7 @@ func (th *ToolsHandler) handleSendImageMessage(args map[string]interface{}) (typ
 		}, nil
 	}
 
-	resp, err := th.feishuClient.SendImageMessage(imageKey)
+	resp, err := client.SendImageMessage(ctx, imageKey)
 	if err != nil {
 		return types.ToolResult{
 			IsError: true,
@@ -271,24 +637,13 @@ func (th *ToolsHandler) handleSendImageMessage(args map[string]interface{}) (typ
 }
 
 // handleSendInteractiveMessage 处理发送交互式消息
-func (th *ToolsHandler) handleSendInteractiveMessage(args map[string]interface{}) (types.ToolResult, error) {
-	elements, ok := args["elements"].([]interface{})
-	if !ok {
-		return types.ToolResult{
-			IsError: true,
-			Content: []interface{}{
-				map[string]interface{}{
-					"type": "text",
-					"text": "elements 参数必须是数组类型",
-				},
-			},
-		}, nil
+func (th *ToolsHandler) handleSendInteractiveMessage(ctx context.Context, client *feishu.Client, args map[string]interface{}) (types.ToolResult, error) {
+	card, err := cardFromArgs(args)
+	if err != nil {
+		return newErrorResult(err.Error()), nil
 	}
 
-	config := args["config"]
-	header := args["header"]
-
-	resp, err := th.feishuClient.SendInteractiveMessage(config, elements, header)
+	resp, err := client.SendCardMessage(ctx, card)
 	if err != nil {
 		return types.ToolResult{
 			IsError: true,
@@ -312,7 +667,7 @@ func (th *ToolsHandler) handleSendInteractiveMessage(args map[string]interface{}
 }
 
 // handleSendShareChatMessage 处理发送群名片消息
-func (th *ToolsHandler) handleSendShareChatMessage(args map[string]interface{}) (types.ToolResult, error) {
+func (th *ToolsHandler) handleSendShareChatMessage(ctx context.Context, client *feishu.Client, args map[string]interface{}) (types.ToolResult, error) {
 	shareChatID, ok := args["share_chat_id"].(string)
 	if !ok {
 		return types.ToolResult{
@@ -326,7 +681,7 @@ func (th *ToolsHandler) handleSendShareChatMessage(args map[string]interface{})
 		}, nil
 	}
 
-	resp, err := th.feishuClient.SendShareChatMessage(shareChatID)
+	resp, err := client.SendShareChatMessage(ctx, shareChatID)
 	if err != nil {
 		return types.ToolResult{
 			IsError: true,
@@ -349,6 +704,237 @@ func (th *ToolsHandler) handleSendShareChatMessage(args map[string]interface{})
 	}, nil
 }
 
+// handleSendDirectMessage 处理发送私聊消息
+func (th *ToolsHandler) handleSendDirectMessage(ctx context.Context, args map[string]interface{}) (types.ToolResult, error) {
+	builder := th.feishuClient.DirectMessageBuilder()
+	if builder == nil {
+		return newErrorResult("未配置应用机器人(app_id/app_secret)，无法发送私聊消息"), nil
+	}
+
+	receiveID, ok := args["receive_id"].(string)
+	if !ok || receiveID == "" {
+		return newErrorResult("receive_id 参数必须是非空字符串"), nil
+	}
+
+	idType, _ := args["receive_id_type"].(string)
+	receiveIDType, err := feishu.ParseReceiveIDType(idType, receiveID)
+	if err != nil {
+		return newErrorResult(err.Error()), nil
+	}
+
+	msgType, _ := args["msg_type"].(string)
+	if msgType == "" {
+		msgType = string(types.MessageTypeText)
+	}
+
+	req, err := th.buildMessageFromArgs(builder, types.MessageType(msgType), args)
+	if err != nil {
+		return newErrorResult(err.Error()), nil
+	}
+
+	if th.isDryRun(args) {
+		// 预览im/v1接口实际收到的请求体，而不是Webhook格式的消息
+		idType, body, err := th.feishuClient.DirectMessageRequest(receiveIDType, receiveID, req)
+		if err != nil {
+			return newErrorResult(fmt.Sprintf("构建私聊消息失败: %v", err)), nil
+		}
+		return newDryRunResult(body, fmt.Sprintf("接收者: %s(%s)，请求: POST /im/v1/messages?receive_id_type=%s", receiveID, receiveIDType, idType)), nil
+	}
+
+	messageID, err := th.feishuClient.SendDirectMessage(ctx, receiveIDType, receiveID, req)
+	if err != nil {
+		return newErrorResult(fmt.Sprintf("发送私聊消息失败: %v", err)), nil
+	}
+
+	return newTextResult(fmt.Sprintf("私聊消息发送成功! 接收者: %s(%s), message_id=%s", receiveID, receiveIDType, messageID)), nil
+}
+
+// handleListChats 处理列出群聊
+func (th *ToolsHandler) handleListChats(args map[string]interface{}) (types.ToolResult, error) {
+	// JSON数字解析为float64
+	pageSize := 0
+	if size, ok := args["page_size"].(float64); ok {
+		pageSize = int(size)
+	}
+	pageToken, _ := args["page_token"].(string)
+
+	list, err := th.feishuClient.ListChats(pageSize, pageToken)
+	if err != nil {
+		return newErrorResult(fmt.Sprintf("获取群聊列表失败: %v", err)), nil
+	}
+
+	return newTextResult(fmt.Sprintf("共返回%d个群聊:\n%s", len(list.Items), SerializeForLogging(list))), nil
+}
+
+// handleFindChat 处理按名称查找群聊
+func (th *ToolsHandler) handleFindChat(args map[string]interface{}) (types.ToolResult, error) {
+	name, ok := args["name"].(string)
+	if !ok || name == "" {
+		return newErrorResult("name 参数必须是非空字符串"), nil
+	}
+
+	chats, err := th.feishuClient.FindChats(name)
+	if err != nil {
+		return newErrorResult(fmt.Sprintf("查找群聊失败: %v", err)), nil
+	}
+
+	if len(chats) == 0 {
+		return newErrorResult(fmt.Sprintf("未找到名称包含\"%s\"的群聊，请确认机器人已加入该群", name)), nil
+	}
+
+	return newTextResult(fmt.Sprintf("找到%d个匹配的群聊:\n%s", len(chats), SerializeForLogging(chats))), nil
+}
+
+// buildMessageFromArgs 根据消息类型从工具参数构建消息，参数格式与各消息工具一致
+func (th *ToolsHandler) buildMessageFromArgs(builder *feishu.MessageBuilder, msgType types.MessageType, args map[string]interface{}) (*types.FeishuWebhookRequest, error) {
+	switch msgType {
+	case types.MessageTypeText:
+		text, ok := args["text"].(string)
+		if !ok {
+			return nil, fmt.Errorf("text 参数必须是字符串类型")
+		}
+		text, err := th.feishuClient.ResolveTextMentions(text)
+		if err != nil {
+			return nil, fmt.Errorf("解析@提及失败: %w", err)
+		}
+		return builder.BuildTextMessage(text)
+	case types.MessageTypePost:
+		posts, err := th.postLocalesFromArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return builder.BuildPostMessage(posts)
+	case types.MessageTypeImage:
+		imageKey, ok := args["image_key"].(string)
+		if !ok {
+			return nil, fmt.Errorf("image_key 参数必须是字符串类型")
+		}
+		return builder.BuildImageMessage(imageKey)
+	case types.MessageTypeInteractive:
+		card, err := cardFromArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return builder.BuildCardMessage(card)
+	case types.MessageTypeShareChat:
+		shareChatID, ok := args["share_chat_id"].(string)
+		if !ok {
+			return nil, fmt.Errorf("share_chat_id 参数必须是字符串类型")
+		}
+		return builder.BuildShareChatMessage(shareChatID)
+	default:
+		return nil, fmt.Errorf("不支持的消息类型: %s", msgType)
+	}
+}
+
+// isDryRun 全局配置或本次调用的dry_run参数开启时为预览模式
+func (th *ToolsHandler) isDryRun(args map[string]interface{}) bool {
+	dryRun, _ := args["dry_run"].(bool)
+	return dryRun || th.feishuClient.DryRun()
+}
+
+// postLocalesFromArgs 从title/content（默认语言zh_cn）和i18n参数构建各语言的富文本内容，并解析其中的@提及
+func (th *ToolsHandler) postLocalesFromArgs(args map[string]interface{}) (map[types.Locale]types.PostLocale, error) {
+	posts := make(map[types.Locale]types.PostLocale)
+	if content, ok := args["content"]; ok {
+		title, _ := args["title"].(string)
+		posts[types.DefaultLocale] = types.PostLocale{Title: title, Content: content}
+	}
+
+	if raw, ok := args["i18n"]; ok {
+		i18n, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("i18n 参数必须是对象类型，如 {\"en_us\": {\"title\": \"...\", \"content\": [[...]]}}")
+		}
+		for locale, value := range i18n {
+			if err := feishu.ValidateLocale(locale); err != nil {
+				return nil, fmt.Errorf("i18n: %w", err)
+			}
+			post, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("i18n.%s 必须是包含title和content的对象", locale)
+			}
+			content, ok := post["content"]
+			if !ok {
+				return nil, fmt.Errorf("i18n.%s.content 参数是必需的", locale)
+			}
+			title, _ := post["title"].(string)
+			// i18n中的语言优先于title/content
+			posts[types.Locale(locale)] = types.PostLocale{Title: title, Content: content}
+		}
+	}
+
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("content 参数是必需的（或通过 i18n 按语言提供内容）")
+	}
+
+	for locale, post := range posts {
+		content, err := th.feishuClient.ResolvePostMentions(post.Content)
+		if err != nil {
+			return nil, fmt.Errorf("解析@提及失败: %w", err)
+		}
+		post.Content = content
+		posts[locale] = post
+	}
+	return posts, nil
+}
+
+// cardFromArgs 从elements、i18n_elements、header和config参数构建卡片，elements和i18n_elements至少提供一个
+func cardFromArgs(args map[string]interface{}) (*types.InteractiveMessage, error) {
+	card := &types.InteractiveMessage{
+		Config: args["config"],
+		Header: args["header"],
+	}
+
+	if raw, ok := args["elements"]; ok {
+		elements, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("elements 参数必须是数组类型")
+		}
+		card.Elements = elements
+	}
+	if raw, ok := args["i18n_elements"]; ok {
+		i18nElements, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("i18n_elements 参数必须是对象类型，如 {\"zh_cn\": [...], \"en_us\": [...]}")
+		}
+		card.I18nElements = i18nElements
+	}
+
+	if card.Elements == nil && len(card.I18nElements) == 0 {
+		return nil, fmt.Errorf("elements 参数必须是数组类型（或通过 i18n_elements 按语言提供卡片内容）")
+	}
+	return card, nil
+}
+
+// newTextResult 创建文本工具结果
+func newTextResult(text string) types.ToolResult {
+	return types.ToolResult{
+		Content: []interface{}{
+			map[string]interface{}{
+				"type": "text",
+				"text": text,
+			},
+		},
+	}
+}
+
+// newErrorResult 创建错误工具结果
+func newErrorResult(text string) types.ToolResult {
+	result := newTextResult(text)
+	result.IsError = true
+	return result
+}
+
+// newDryRunResult 创建预览结果，包含最终请求体（Webhook消息已签名）
+func newDryRunResult(req interface{}, target string) types.ToolResult {
+	text := "预览模式，消息未发送。"
+	if target != "" {
+		text += target + "。"
+	}
+	return newTextResult(fmt.Sprintf("%s最终请求体:\n%s", text, SerializeForLogging(req)))
+}
+
 // CreateCardElements 创建简单的卡片元素
 func CreateCardElements(title, content string) []interface{} {
 	return []interface{}{
@@ -0,0 +1,135 @@
+package mcp
+
+import (
+	"context"
+	"mcp-feishu/internal/acl"
+	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/feishu/feishutest"
+	"mcp-feishu/internal/types"
+	"strings"
+	"testing"
+)
+
+func TestToolTarget(t *testing.T) {
+	tests := []struct {
+		name string
+		call types.ToolCall
+		want string
+	}{
+		{"默认Webhook", types.ToolCall{Name: "send_text_message", Arguments: map[string]interface{}{"text": "hi"}}, "webhook"},
+		{"显式默认Webhook", types.ToolCall{Name: "send_text_message", Arguments: map[string]interface{}{"target": "webhook"}}, "webhook"},
+		{"命名目标", types.ToolCall{Name: "send_post_message", Arguments: map[string]interface{}{"target": "release"}}, "webhook:release"},
+		{"私聊", types.ToolCall{Name: "send_direct_message", Arguments: map[string]interface{}{"receive_id": "a@example.com"}}, "direct:a@example.com"},
+		{"私聊缺少接收者", types.ToolCall{Name: "send_direct_message", Arguments: map[string]interface{}{}}, ""},
+		{"查询工具", types.ToolCall{Name: "list_chats", Arguments: map[string]interface{}{}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toolTarget(tt.call); got != tt.want {
+				t.Errorf("toolTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallToolTarget(t *testing.T) {
+	defaultMock := feishutest.Start(feishutest.Options{DisableRateLimit: true})
+	defer defaultMock.Close()
+	releaseMock := feishutest.Start(feishutest.Options{DisableRateLimit: true, Keywords: []string{"发布"}})
+	defer releaseMock.Close()
+
+	handler := NewToolsHandler(feishu.NewClient(types.FeishuConfig{
+		WebhookURL: defaultMock.URL(),
+		Targets: []types.WebhookTarget{
+			{Name: "release", WebhookURL: releaseMock.URL(), Keywords: []string{"发布"}},
+		},
+	}))
+	handler.SetPolicy(&acl.Policy{
+		Default: &acl.Rule{Tools: []string{"*"}, Targets: []string{"webhook"}},
+		Clients: map[string]acl.Rule{
+			"release-bot": {Tools: []string{"*"}, Targets: []string{"webhook:*"}},
+		},
+	})
+
+	tests := []struct {
+		name        string
+		client      string
+		args        map[string]interface{}
+		wantErr     string
+		wantDefault int
+		wantRelease int
+	}{
+		{"默认Webhook", "", map[string]interface{}{"text": "发布通知"}, "", 1, 0},
+		{"无权发送到命名目标", "", map[string]interface{}{"text": "发布通知", "target": "release"}, "无权向 webhook:release 发送消息", 0, 0},
+		{"命名目标", "release-bot", map[string]interface{}{"text": "发布通知", "target": "release"}, "", 0, 1},
+		{"未知目标", "release-bot", map[string]interface{}{"text": "发布通知", "target": "staging"}, "未知的目标: staging", 0, 0},
+		{"命名目标预览", "release-bot", map[string]interface{}{"text": "发布通知", "target": "release", "dry_run": true}, "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaultMock.Reset()
+			releaseMock.Reset()
+
+			ctx := acl.WithPrincipal(context.Background(), acl.Principal{Client: tt.client})
+			result, err := handler.CallTool(ctx, types.ToolCall{Name: "send_text_message", Arguments: tt.args})
+			if err != nil {
+				t.Fatalf("CallTool() error = %v", err)
+			}
+			text := result.Content[0].(map[string]interface{})["text"].(string)
+			if result.IsError != (tt.wantErr != "") || !strings.Contains(text, tt.wantErr) {
+				t.Errorf("CallTool() = %q, want error %q", text, tt.wantErr)
+			}
+			if got := len(defaultMock.Messages()); got != tt.wantDefault {
+				t.Errorf("默认Webhook收到%d条消息, want %d", got, tt.wantDefault)
+			}
+			if got := len(releaseMock.Messages()); got != tt.wantRelease {
+				t.Errorf("release收到%d条消息, want %d", got, tt.wantRelease)
+			}
+		})
+	}
+}
+
+// TestBroadcastKeywordPerTarget 每个目标注入各自的关键词，不能改写其他目标共用的卡片参数
+func TestBroadcastKeywordPerTarget(t *testing.T) {
+	mocks := map[string]*feishutest.Server{
+		"a": feishutest.Start(feishutest.Options{DisableRateLimit: true, Keywords: []string{"KW-a"}}),
+		"b": feishutest.Start(feishutest.Options{DisableRateLimit: true, Keywords: []string{"KW-b"}}),
+	}
+	var targets []types.WebhookTarget
+	for name, mock := range mocks {
+		defer mock.Close()
+		targets = append(targets, types.WebhookTarget{
+			Name:          name,
+			WebhookURL:    mock.URL(),
+			SecurityType:  string(types.SecurityTypeKeyword),
+			Keywords:      []string{"KW-" + name},
+			KeywordPolicy: string(types.KeywordPolicyPrepend),
+		})
+	}
+	handler := NewToolsHandler(feishu.NewClient(types.FeishuConfig{Targets: targets}))
+
+	header := map[string]interface{}{"title": map[string]interface{}{"tag": "plain_text", "content": "Release"}}
+	result, err := handler.CallTool(context.Background(), types.ToolCall{Name: "broadcast_message", Arguments: map[string]interface{}{
+		"targets":  []interface{}{"a", "b"},
+		"msg_type": "interactive",
+		"header":   header,
+		"elements": []interface{}{map[string]interface{}{"tag": "markdown", "content": "v2.3.0"}},
+	}})
+	if err != nil || result.IsError {
+		t.Fatalf("CallTool() = %+v, error = %v", result, err)
+	}
+
+	for name, mock := range mocks {
+		messages := mock.Messages()
+		if len(messages) != 1 {
+			t.Fatalf("%s收到%d条消息, want 1", name, len(messages))
+		}
+		title := messages[0].Content["header"].(map[string]interface{})["title"].(map[string]interface{})["content"]
+		if want := "KW-" + name + " Release"; title != want {
+			t.Errorf("%s的卡片标题 = %q, want %q", name, title, want)
+		}
+	}
+	if title := header["title"].(map[string]interface{})["content"]; title != "Release" {
+		t.Errorf("调用方的header被修改为 %q", title)
+	}
+}
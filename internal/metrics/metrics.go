@@ -0,0 +1,80 @@
+// Package metrics 定义本服务的Prometheus指标，通过/metrics端点输出。
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 工具调用结果
+const (
+	ResultSuccess = "success" // 调用成功
+	ResultError   = "error"   // 工具返回isError结果
+	ResultFailure = "failure" // 工具执行出错，返回JSON-RPC错误
+)
+
+// CodeTransportError 请求未收到飞书响应（网络错误、响应无法解析等）时使用的code标签值
+const CodeTransportError = "transport_error"
+
+// DefaultBuckets 耗时直方图的桶（秒），覆盖飞书接口的常见耗时和超时
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Registry 本服务的指标注册表，/metrics端点只输出其中的指标
+var Registry = prometheus.NewRegistry()
+
+var (
+	// ToolCalls 工具调用次数
+	ToolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_feishu_tool_calls_total",
+		Help: "工具调用次数，按工具名称和结果统计",
+	}, []string{"tool", "result"})
+
+	// ToolCallDuration 工具调用耗时
+	ToolCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mcp_feishu_tool_call_duration_seconds",
+		Help:    "工具调用耗时（秒）",
+		Buckets: DefaultBuckets,
+	}, []string{"tool"})
+
+	// Messages 发送到飞书的消息数，code为飞书返回的错误码，0表示成功
+	Messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_feishu_messages_total",
+		Help: "发送到飞书的消息数，按消息类型、目标和飞书错误码统计",
+	}, []string{"msg_type", "target", "code"})
+
+	// RequestDuration 请求飞书的耗时
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mcp_feishu_request_duration_seconds",
+		Help:    "请求飞书的耗时（秒）",
+		Buckets: DefaultBuckets,
+	}, []string{"target"})
+
+	// Retries 发送失败后的重试次数
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_feishu_retries_total",
+		Help: "发送失败后的重试次数，目前只有私聊消息在tenant_access_token失效时重试",
+	}, []string{"target"})
+
+	// QueueDepth 等待发送的消息数
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mcp_feishu_queue_depth",
+		Help: "等待发送的消息数",
+	})
+
+	// QuotaRejections 因配额用尽或目标熔断被拒绝的工具调用次数
+	QuotaRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_feishu_quota_rejections_total",
+		Help: "因配额用尽或目标熔断被拒绝的工具调用次数，按原因统计",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(ToolCalls, ToolCallDuration, Messages, RequestDuration, Retries, QueueDepth, QuotaRejections)
+}
+
+// Handler 返回输出Registry中全部指标的/metrics处理器
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
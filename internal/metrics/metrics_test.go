@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHandler /metrics端点以Prometheus文本格式输出本服务的指标，直方图使用DefaultBuckets
+func TestHandler(t *testing.T) {
+	ToolCalls.WithLabelValues("send_text_message", ResultSuccess).Inc()
+	RequestDuration.WithLabelValues("webhook").Observe(0.3)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		"# TYPE mcp_feishu_tool_calls_total counter",
+		`mcp_feishu_tool_calls_total{result="success",tool="send_text_message"} 1`,
+		`mcp_feishu_request_duration_seconds_bucket{target="webhook",le="0.25"} 0`,
+		`mcp_feishu_request_duration_seconds_bucket{target="webhook",le="0.5"} 1`,
+		// 没有标签的仪表在没有更新过时也会输出
+		"mcp_feishu_queue_depth 0",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("输出中缺少 %q:\n%s", want, body)
+		}
+	}
+	// 只输出本服务的指标，不包含Go运行时指标
+	if strings.Contains(string(body), "go_goroutines") {
+		t.Errorf("输出中包含Go运行时指标:\n%s", body)
+	}
+}
@@ -0,0 +1,361 @@
+// Package quota 按调用主体和消息目标限制发送数量，并在目标连续发送失败时暂停发送（熔断）。
+//
+// 配额按自然分钟、小时、天计数，窗口结束时清零；熔断按目标计数，
+// 暂停结束后放行一条试探消息，成功则恢复，失败则继续暂停。
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Config 配额和熔断配置，数值为0表示不限制
+type Config struct {
+	PerMinute int // 每个主体向每个目标每分钟最多发送的消息数
+	PerHour   int
+	PerDay    int
+
+	BreakerThreshold int           // 目标连续发送失败多少次后暂停，0表示不启用熔断
+	BreakerCooldown  time.Duration // 暂停时长
+}
+
+// 拒绝原因，用于日志和指标
+const (
+	ReasonMinute  = "minute"
+	ReasonHour    = "hour"
+	ReasonDay     = "day"
+	ReasonBreaker = "breaker"
+)
+
+// window 一种计数窗口
+type window struct {
+	reason string
+	label  string
+	limit  func(Config) int
+	start  func(now time.Time) time.Time
+	end    func(start time.Time) time.Time
+}
+
+// windows 按从短到长的顺序检查，返回最先用尽的窗口
+var windows = [...]window{
+	{
+		reason: ReasonMinute,
+		label:  "每分钟",
+		limit:  func(c Config) int { return c.PerMinute },
+		start: func(now time.Time) time.Time {
+			return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, now.Location())
+		},
+		end: func(start time.Time) time.Time { return start.Add(time.Minute) },
+	},
+	{
+		reason: ReasonHour,
+		label:  "每小时",
+		limit:  func(c Config) int { return c.PerHour },
+		start: func(now time.Time) time.Time {
+			return time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+		},
+		end: func(start time.Time) time.Time { return start.Add(time.Hour) },
+	},
+	{
+		reason: ReasonDay,
+		label:  "每天",
+		limit:  func(c Config) int { return c.PerDay },
+		start: func(now time.Time) time.Time {
+			return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		},
+		end: func(start time.Time) time.Time { return start.AddDate(0, 0, 1) },
+	},
+}
+
+// usage 一个主体向一个目标的发送计数
+type usage struct {
+	starts [len(windows)]time.Time
+	counts [len(windows)]int
+}
+
+// breaker 一个目标的熔断状态
+type breaker struct {
+	failures  int       // 连续失败次数
+	openUntil time.Time // 暂停结束时间，零值表示未暂停
+	probing   bool      // 暂停结束后是否已有试探消息在发送
+}
+
+// ExceededError 配额用尽或目标被熔断，说明何时可以恢复发送
+type ExceededError struct {
+	Principal string
+	Target    string
+	Reason    string
+	Limit     int       // 配额上限，熔断时为连续失败次数
+	ResetAt   time.Time // 配额重置或暂停结束的时间
+}
+
+func (e *ExceededError) Error() string {
+	resetAt := e.ResetAt.Format("2006-01-02 15:04:05")
+	if e.Reason == ReasonBreaker {
+		return fmt.Sprintf("目标 %s 连续%d次发送失败，已暂停发送，将在%s后恢复", e.Target, e.Limit, resetAt)
+	}
+	label := ""
+	for _, w := range windows {
+		if w.reason == e.Reason {
+			label = w.label
+		}
+	}
+	return fmt.Sprintf("%s向 %s 发送的消息已达到%s%d条的上限，配额将在%s重置", e.Principal, e.Target, label, e.Limit, resetAt)
+}
+
+// Limiter 配额和熔断状态，重新加载配置时通过Update更新限制，已有的计数保留
+type Limiter struct {
+	mu       sync.Mutex
+	config   Config
+	now      func() time.Time
+	usage    map[string]*usage
+	breakers map[string]*breaker
+}
+
+// New 创建Limiter
+func New(config Config) *Limiter {
+	return &Limiter{
+		config:   config,
+		now:      time.Now,
+		usage:    make(map[string]*usage),
+		breakers: make(map[string]*breaker),
+	}
+}
+
+// Update 更新配额和熔断配置
+func (l *Limiter) Update(config Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.config = config
+}
+
+// maxUsageEntries 计数条目超过该数量时清理已过期的条目
+const maxUsageEntries = 4096
+
+// Acquire 为主体向目标发送一条消息占用配额，超出配额或目标被熔断时返回*ExceededError
+//
+// 调用方发送完成后必须调用Ticket.Release，未实际发送（如参数错误）的消息会退还配额。
+func (l *Limiter) Acquire(principal, target string) (*Ticket, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	ticket := &Ticket{limiter: l, target: target}
+
+	if l.config.BreakerThreshold > 0 {
+		b := l.breakers[target]
+		if b != nil && !b.openUntil.IsZero() {
+			if now.Before(b.openUntil) || b.probing {
+				resetAt := b.openUntil
+				if !now.Before(resetAt) {
+					// 试探消息尚未返回结果，稍后再试
+					resetAt = now.Add(time.Second)
+				}
+				return nil, &ExceededError{Principal: principal, Target: target, Reason: ReasonBreaker, Limit: b.failures, ResetAt: resetAt}
+			}
+			b.probing = true
+			ticket.probe = true
+		}
+	}
+
+	key := principal + "\x00" + target
+	u := l.usage[key]
+	if u == nil {
+		if len(l.usage) >= maxUsageEntries {
+			l.prune(now)
+		}
+		u = &usage{}
+		l.usage[key] = u
+	}
+
+	for i, w := range windows {
+		if start := w.start(now); !u.starts[i].Equal(start) {
+			u.starts[i] = start
+			u.counts[i] = 0
+		}
+		if limit := w.limit(l.config); limit > 0 && u.counts[i] >= limit {
+			if ticket.probe {
+				l.breakers[target].probing = false
+			}
+			return nil, &ExceededError{Principal: principal, Target: target, Reason: w.reason, Limit: limit, ResetAt: w.end(u.starts[i])}
+		}
+	}
+	for i := range windows {
+		u.counts[i]++
+	}
+
+	ticket.usage = u
+	ticket.starts = u.starts
+	return ticket, nil
+}
+
+// prune 删除所有窗口都已过期的计数，调用方需持有l.mu
+func (l *Limiter) prune(now time.Time) {
+	day := len(windows) - 1
+	for key, u := range l.usage {
+		if !u.starts[day].Equal(windows[day].start(now)) {
+			delete(l.usage, key)
+		}
+	}
+}
+
+// Ticket 一次占用的配额
+type Ticket struct {
+	limiter *Limiter
+	target  string
+	usage   *usage
+	starts  [len(windows)]time.Time
+	probe   bool // 熔断恢复后的试探消息
+
+	mu       sync.Mutex
+	reported bool
+	held     bool // 结果稍后报告，Release不退还配额
+	released bool
+}
+
+// Report 记录发送结果，连续失败达到阈值时暂停目标，成功时清除失败计数
+func (t *Ticket) Report(err error) {
+	t.mu.Lock()
+	t.reported = true
+	t.mu.Unlock()
+
+	l := t.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t.endProbe()
+	l.reportTarget(t.target, err)
+}
+
+// Hold 标记消息已被接受、稍后发送（如加入摘要），Release不再退还配额，发送后仍需通过Report记录结果
+//
+// 试探消息加入摘要后不再占用试探名额，否则在摘要发送前其他调用方都会被熔断拒绝；摘要发送的结果照常更新熔断状态。
+func (t *Ticket) Hold() {
+	t.mu.Lock()
+	t.held = true
+	t.mu.Unlock()
+
+	l := t.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t.endProbe()
+}
+
+// endProbe 结束试探消息占用的试探名额，调用方需持有t.limiter.mu
+func (t *Ticket) endProbe() {
+	if !t.probe {
+		return
+	}
+	t.probe = false
+	if b := t.limiter.breakers[t.target]; b != nil {
+		b.probing = false
+	}
+}
+
+// Release 结束本次占用，没有调用过Report或Hold的占用会退还配额，重复调用只生效一次
+func (t *Ticket) Release() {
+	t.mu.Lock()
+	if t.released {
+		t.mu.Unlock()
+		return
+	}
+	t.released = true
+	kept := t.reported || t.held
+	t.mu.Unlock()
+
+	if kept {
+		return
+	}
+
+	l := t.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t.endProbe()
+	// 窗口已经切换时无需退还
+	for i := range windows {
+		if t.usage.starts[i].Equal(t.starts[i]) && t.usage.counts[i] > 0 {
+			t.usage.counts[i]--
+		}
+	}
+}
+
+// ReportTarget 记录一次向目标发送请求的结果，连续失败达到阈值时暂停目标，成功时清除失败计数
+func (l *Limiter) ReportTarget(target string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reportTarget(target, err)
+}
+
+// reportTarget 更新目标的熔断状态，调用方需持有l.mu
+func (l *Limiter) reportTarget(target string, err error) {
+	if err == nil {
+		delete(l.breakers, target)
+		return
+	}
+	if l.config.BreakerThreshold <= 0 {
+		return
+	}
+
+	b := l.breakers[target]
+	if b == nil {
+		b = &breaker{}
+		l.breakers[target] = b
+	}
+	b.failures++
+	if b.failures >= l.config.BreakerThreshold {
+		b.openUntil = l.now().Add(l.config.BreakerCooldown)
+	}
+}
+
+// ticketKey context中保存配额占用的key
+type ticketKey struct{}
+
+// WithTicket 返回携带配额占用的context，发送消息后通过Report记录结果
+func WithTicket(ctx context.Context, ticket *Ticket) context.Context {
+	return context.WithValue(ctx, ticketKey{}, ticket)
+}
+
+// Hold 保留context中的配额占用，context中没有配额占用时不做任何事
+func Hold(ctx context.Context) {
+	if ticket, ok := ctx.Value(ticketKey{}).(*Ticket); ok {
+		ticket.Hold()
+	}
+}
+
+// Report 记录context中配额占用的发送结果，context中没有配额占用时（如命令行发送）不做任何事
+func Report(ctx context.Context, err error) {
+	if ctx == nil {
+		return
+	}
+	if ticket, ok := ctx.Value(ticketKey{}).(*Ticket); ok {
+		ticket.Report(err)
+	}
+}
+
+// ReportMerged 记录一次合并发送（如摘要）的结果：merged中每条消息的配额占用都标记为已报告，
+// 熔断状态按实际的发送请求只更新一次，不会因为合并的消息多而一次失败就达到阈值
+func ReportMerged(merged []context.Context, err error) {
+	var first *Ticket
+	for _, ctx := range merged {
+		ticket, ok := ctx.Value(ticketKey{}).(*Ticket)
+		if !ok {
+			continue
+		}
+		ticket.mu.Lock()
+		ticket.reported = true
+		ticket.mu.Unlock()
+
+		l := ticket.limiter
+		l.mu.Lock()
+		ticket.endProbe()
+		l.mu.Unlock()
+		if first == nil {
+			first = ticket
+		}
+	}
+	if first != nil {
+		first.limiter.ReportTarget(first.target, err)
+	}
+}
@@ -0,0 +1,107 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTicketRelease(t *testing.T) {
+	tests := []struct {
+		name        string
+		finish      func(ctx context.Context)
+		wantRefund  bool
+		wantBreaker bool
+	}{
+		{"未发送退还配额", func(ctx context.Context) {}, true, false},
+		{"发送成功", func(ctx context.Context) { Report(ctx, nil) }, false, false},
+		{"发送失败", func(ctx context.Context) { Report(ctx, errors.New("发送失败")) }, false, true},
+		// 加入摘要的消息在Release之后才发送
+		{"稍后发送不退还配额", func(ctx context.Context) { Hold(ctx) }, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(Config{PerMinute: 1, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+			now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+			l.now = func() time.Time { return now }
+
+			ticket, err := l.Acquire("client:test", "webhook")
+			if err != nil {
+				t.Fatalf("Acquire() error = %v", err)
+			}
+			tt.finish(WithTicket(context.Background(), ticket))
+			ticket.Release()
+
+			if tt.wantBreaker {
+				// 熔断后任何主体都不能发送
+				_, err = l.Acquire("client:other", "webhook")
+			} else {
+				_, err = l.Acquire("client:test", "webhook")
+			}
+			var exceeded *ExceededError
+			switch {
+			case tt.wantBreaker:
+				if !errors.As(err, &exceeded) || exceeded.Reason != ReasonBreaker {
+					t.Errorf("熔断后Acquire() error = %v, want breaker", err)
+				}
+			case tt.wantRefund && err != nil:
+				t.Errorf("退还配额后Acquire() error = %v", err)
+			case !tt.wantRefund && (!errors.As(err, &exceeded) || exceeded.Reason != ReasonMinute):
+				t.Errorf("配额用尽后Acquire() error = %v, want minute", err)
+			}
+		})
+	}
+}
+
+func TestReportMerged(t *testing.T) {
+	l := New(Config{BreakerThreshold: 3, BreakerCooldown: time.Minute})
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	// 超过熔断阈值的消息加入同一个摘要，摘要发送失败只算一次失败
+	var merged []context.Context
+	for i := 0; i < 5; i++ {
+		ticket, err := l.Acquire("client:test", "webhook")
+		if err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+		ctx := WithTicket(context.Background(), ticket)
+		Hold(ctx)
+		ticket.Release()
+		merged = append(merged, ctx)
+	}
+	ReportMerged(merged, errors.New("发送失败"))
+
+	if _, err := l.Acquire("client:test", "webhook"); err != nil {
+		t.Fatalf("摘要发送失败一次后Acquire() error = %v", err)
+	}
+	if got := l.breakers["webhook"].failures; got != 1 {
+		t.Errorf("连续失败次数 = %d, want 1", got)
+	}
+	for i, ctx := range merged {
+		ticket := ctx.Value(ticketKey{}).(*Ticket)
+		if !ticket.reported {
+			t.Errorf("第%d条消息的配额占用未标记为已报告", i)
+		}
+	}
+}
+
+func TestHoldProbe(t *testing.T) {
+	l := New(Config{BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	now := time.Date(2024, 1, 1, 12, 0, 30, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	l.ReportTarget("webhook", errors.New("发送失败"))
+	now = now.Add(time.Minute)
+
+	probe, err := l.Acquire("client:test", "webhook")
+	if err != nil || !probe.probe {
+		t.Fatalf("暂停结束后Acquire() = %+v, %v, want 试探消息", probe, err)
+	}
+	// 试探消息加入摘要后，其他调用方不必等到摘要发送
+	Hold(WithTicket(context.Background(), probe))
+	if _, err := l.Acquire("client:other", "webhook"); err != nil {
+		t.Errorf("试探消息加入摘要后Acquire() error = %v", err)
+	}
+}
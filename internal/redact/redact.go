@@ -0,0 +1,86 @@
+// Package redact 识别文本中的密钥和个人信息，供内容过滤和审计日志脱敏共用。
+package redact
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Placeholder 脱敏后的占位符
+const Placeholder = "[已脱敏]"
+
+// Detector 一类敏感内容的检测项
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate 对正则匹配结果做进一步校验（如身份证校验位），为nil时全部匹配都有效
+	Validate func(match string) bool
+}
+
+// 内置检测项
+var (
+	Credentials = Detector{Name: "credentials", Pattern: regexp.MustCompile(strings.Join([]string{
+		`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,                                      // AWS AccessKey
+		`\bLTAI[0-9A-Za-z]{12,20}\b`,                                         // 阿里云AccessKey
+		`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,                                     // GitHub令牌
+		`\bgithub_pat_[A-Za-z0-9_]{22,}\b`,                                   // GitHub细粒度令牌
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}`,                                     // Slack令牌
+		`\bsk-[A-Za-z0-9_-]{20,}`,                                            // OpenAI等API密钥
+		`\bAIza[0-9A-Za-z_-]{35}\b`,                                          // Google API密钥
+		`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`, // JWT
+		`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z ]*PRIVATE KEY-----|$)`, // 私钥
+		`https://open\.(?:feishu\.cn|larksuite\.com)/open-apis/bot/v2/hook/[A-Za-z0-9-]+`,  // 飞书Webhook地址
+	}, "|"))}
+
+	IDCard = Detector{
+		Name:     "id_card",
+		Pattern:  regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`),
+		Validate: validIDCard,
+	}
+
+	Phone = Detector{Name: "phone", Pattern: regexp.MustCompile(`(?:\+86[- ]?|\b)1[3-9]\d{9}\b`)}
+
+	Email = Detector{Name: "email", Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)}
+)
+
+// Builtin 全部内置检测项
+var Builtin = []Detector{Credentials, IDCard, Phone, Email}
+
+// validIDCard 校验18位身份证号的校验位，减少把订单号等长数字误判为身份证号
+func validIDCard(id string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, weight := range weights {
+		sum += int(id[i]-'0') * weight
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(id[17:])[0]
+}
+
+// Matches 返回文本中命中检测项的内容
+func (d Detector) Matches(text string) []string {
+	var result []string
+	for _, match := range d.Pattern.FindAllString(text, -1) {
+		if d.Validate == nil || d.Validate(match) {
+			result = append(result, match)
+		}
+	}
+	return result
+}
+
+// Replace 将文本中命中检测项的内容替换为Placeholder
+func (d Detector) Replace(text string) string {
+	return d.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if d.Validate != nil && !d.Validate(match) {
+			return match
+		}
+		return Placeholder
+	})
+}
+
+// Text 将文本中命中任一内置检测项的内容替换为Placeholder
+func Text(text string) string {
+	for _, detector := range Builtin {
+		text = detector.Replace(text)
+	}
+	return text
+}
@@ -0,0 +1,27 @@
+package redact
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"GitHub令牌", "token ghp_" + "abcdefghijklmnopqrstuvwxyz0123456789", "token [已脱敏]"},
+		{"飞书Webhook地址", "回调 https://open.feishu.cn/open-apis/bot/v2/hook/abc-123", "回调 [已脱敏]"},
+		{"手机号", "联系 13812345678", "联系 [已脱敏]"},
+		{"带区号的手机号", "联系 +86 13812345678", "联系 [已脱敏]"},
+		{"邮箱", "发给 alice@example.com", "发给 [已脱敏]"},
+		{"校验位正确的身份证号", "身份证 11010519491231002X", "身份证 [已脱敏]"},
+		{"校验位错误的长数字不处理", "订单 110105194912310021", "订单 110105194912310021"},
+		{"普通文本不变", "部署完成 v2.3.0", "部署完成 v2.3.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.text); got != tt.want {
+				t.Errorf("Text(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+// Package tracing 基于OpenTelemetry SDK配置链路追踪，并在MCP请求的_meta和HTTP请求头之间传播W3C trace上下文。
+//
+// 调用方通过Tracer创建span；未调用Setup时使用OpenTelemetry的空实现，span不会被记录，
+// 但调用方传入的trace上下文仍会传播到飞书请求和审计日志。
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName 本服务创建span时使用的instrumentation scope
+const ScopeName = "mcp-feishu"
+
+// propagator 只使用W3C Trace Context，不依赖全局的propagator设置
+var propagator = propagation.TraceContext{}
+
+// Tracer 返回本服务使用的Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// Setup 使用给定的导出器创建TracerProvider并设置为全局，sampleRatio为新trace的采样比例，
+// 接入调用方trace时沿用调用方的采样决定。span在后台批量导出，退出前调用Shutdown导出剩余的span并关闭导出器
+func Setup(exporter sdktrace.SpanExporter, serviceName string, sampleRatio float64) *sdktrace.TracerProvider {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	// 导出失败只记录警告日志，不影响消息发送
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		log.Warn().Err(err).Msg("链路追踪出错")
+	}))
+	return provider
+}
+
+// NewOTLPExporter 创建OTLP/HTTP导出器，endpoint为接收端地址（如 http://localhost:4318），
+// 未以 /v1/traces 结尾时自动追加
+func NewOTLPExporter(ctx context.Context, endpoint string) (sdktrace.SpanExporter, error) {
+	endpoint = strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("创建OTLP导出器失败: %w", err)
+	}
+	return exporter, nil
+}
+
+// NewFileExporter 创建将span以JSON追加写入本地文件的导出器，用于离线排查；
+// stdio传输占用了标准输出，因此不直接写到标准输出
+func NewFileExporter(path string) (sdktrace.SpanExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开追踪文件失败: %w", err)
+	}
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("创建文件导出器失败: %w", err)
+	}
+	return &fileExporter{SpanExporter: exporter, file: file}, nil
+}
+
+// fileExporter 关闭导出器时一并关闭文件
+type fileExporter struct {
+	sdktrace.SpanExporter
+	file *os.File
+}
+
+// Shutdown 关闭导出器和文件
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Extract 从MCP请求的_meta中读取调用方的trace上下文（traceparent、tracestate），
+// 不存在或格式无效时返回原context，由服务器开始新的trace
+func Extract(ctx context.Context, meta map[string]interface{}) context.Context {
+	carrier := propagation.MapCarrier{}
+	for _, key := range propagator.Fields() {
+		if value, ok := meta[key].(string); ok {
+			carrier[key] = value
+		}
+	}
+	return propagator.Extract(ctx, carrier)
+}
+
+// Inject 将context中的trace上下文写入HTTP请求头
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// RecordError err非nil时记录异常事件并将span标记为失败
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
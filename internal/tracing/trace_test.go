@@ -0,0 +1,151 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testTraceparent = "00-" + testTraceID + "-00f067aa0ba902b7-01"
+)
+
+func TestExtractInject(t *testing.T) {
+	tests := []struct {
+		name  string
+		meta  map[string]interface{}
+		want  string // 注入到请求头的traceparent，为空表示没有有效的trace上下文
+		state string
+	}{
+		{"W3C示例", map[string]interface{}{"traceparent": testTraceparent}, testTraceparent, ""},
+		{"未采样", map[string]interface{}{"traceparent": "00-" + testTraceID + "-00f067aa0ba902b7-00"}, "00-" + testTraceID + "-00f067aa0ba902b7-00", ""},
+		{"携带tracestate", map[string]interface{}{"traceparent": testTraceparent, "tracestate": "vendor=abc"}, testTraceparent, "vendor=abc"},
+		{"版本ff无效", map[string]interface{}{"traceparent": "ff-" + testTraceID + "-00f067aa0ba902b7-01"}, "", ""},
+		{"大写十六进制", map[string]interface{}{"traceparent": "00-" + strings.ToUpper(testTraceID) + "-00f067aa0ba902b7-01"}, "", ""},
+		{"trace-id全为0", map[string]interface{}{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"}, "", ""},
+		{"不是字符串", map[string]interface{}{"traceparent": 1}, "", ""},
+		{"没有_meta", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := Extract(context.Background(), tt.meta)
+			header := http.Header{}
+			Inject(ctx, header)
+			if got := header.Get("traceparent"); got != tt.want {
+				t.Errorf("traceparent = %q, want %q", got, tt.want)
+			}
+			if got := header.Get("tracestate"); got != tt.state {
+				t.Errorf("tracestate = %q, want %q", got, tt.state)
+			}
+		})
+	}
+}
+
+// setupTest 使用内存导出器启用追踪，返回导出全部span并读取的函数，测试结束后恢复空实现
+func setupTest(t *testing.T, sampleRatio float64) func() tracetest.SpanStubs {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := Setup(exporter, "mcp-feishu-test", sampleRatio)
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+	return func() tracetest.SpanStubs {
+		if err := provider.ForceFlush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return exporter.GetSpans()
+	}
+}
+
+func TestSetupExportsChildSpans(t *testing.T) {
+	spans := setupTest(t, 1)
+
+	ctx := Extract(context.Background(), map[string]interface{}{"traceparent": testTraceparent})
+	ctx, parent := Tracer().Start(ctx, "tool send_text_message")
+	_, child := Tracer().Start(ctx, "feishu.send webhook", trace.WithSpanKind(trace.SpanKindClient))
+	RecordError(child, os.ErrDeadlineExceeded)
+	child.End()
+	parent.End()
+
+	got := spans()
+	if len(got) != 2 {
+		t.Fatalf("导出了%d个span，want 2", len(got))
+	}
+	// 先结束的子span先导出
+	sent, tool := got[0], got[1]
+	if sent.Parent.SpanID() != tool.SpanContext.SpanID() || tool.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("父子关系错误: %s <- %s <- %s", tool.Parent.SpanID(), tool.SpanContext.SpanID(), sent.Parent.SpanID())
+	}
+	for _, s := range got {
+		if s.SpanContext.TraceID().String() != testTraceID {
+			t.Errorf("%s的traceId = %s, want %s", s.Name, s.SpanContext.TraceID(), testTraceID)
+		}
+	}
+	if sent.Status.Description != os.ErrDeadlineExceeded.Error() || len(sent.Events) != 1 || sent.Events[0].Name != "exception" {
+		t.Errorf("失败的span status = %+v, events = %+v", sent.Status, sent.Events)
+	}
+	if service := tool.Resource.Attributes(); len(service) != 1 || service[0].Value.AsString() != "mcp-feishu-test" {
+		t.Errorf("resource = %v", service)
+	}
+}
+
+func TestSetupSampling(t *testing.T) {
+	spans := setupTest(t, 0)
+
+	// 新trace按比例采样，比例为0时不导出，但仍然传播上下文
+	ctx, span := Tracer().Start(context.Background(), "tools/call")
+	header := http.Header{}
+	Inject(ctx, header)
+	span.End()
+	if header.Get("traceparent") == "" {
+		t.Error("未采样的trace没有传播traceparent")
+	}
+
+	// 调用方已采样时沿用调用方的决定
+	ctx = Extract(context.Background(), map[string]interface{}{"traceparent": testTraceparent})
+	_, span = Tracer().Start(ctx, "tools/call")
+	span.End()
+
+	got := spans()
+	if len(got) != 1 || got[0].SpanContext.TraceID().String() != testTraceID {
+		t.Errorf("导出的span = %+v, want 只有调用方trace中的1个", got)
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := Setup(exporter, "mcp-feishu-test", 1)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	_, span := Tracer().Start(context.Background(), "tools/call")
+	span.End()
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported struct {
+		Name string
+	}
+	if err := json.Unmarshal(data, &exported); err != nil || exported.Name != "tools/call" {
+		t.Errorf("文件内容 = %s, err = %v", data, err)
+	}
+}
@@ -1,11 +1,103 @@
 package types
 
+import "strings"
+
 // FeishuConfig 飞书配置
 type FeishuConfig struct {
 	WebhookURL   string   `json:"webhook_url"`
 	Secret       string   `json:"secret,omitempty"`   // 签名校验密钥
 	Keywords     []string `json:"keywords,omitempty"` // 自定义关键词
-	SecurityType string   `json:"security_type"`      // none, signature, keyword
+	SecurityType string   `json:"security_type"`      // none, signature, keyword，可用逗号组合
+
+	// 组合安全策略列表，如 ["signature", "keyword"]，配置后优先于security_type
+	SecurityPolicies []string `json:"security_policies,omitempty"`
+
+	// 签名密钥的其他来源，secret为空时依次尝试
+	SecretFile    string `json:"secret_file,omitempty"`    // 从文件读取密钥
+	SecretCommand string `json:"secret_command,omitempty"` // 执行外部命令获取密钥，如vault CLI
+
+	// 消息缺少关键词时的处理策略：reject(默认), prepend, append
+	KeywordPolicy string `json:"keyword_policy,omitempty"`
+
+	// 应用机器人配置（可选），用于私聊等需要开放平台API的功能
+	AppID      string `json:"app_id,omitempty"`
+	AppSecret  string `json:"app_secret,omitempty"`
+	APIBaseURL string `json:"api_base_url,omitempty"` // 默认 https://open.feishu.cn/open-apis
+
+	// 用户目录文件，用于将@名字或@邮箱解析为at标签
+	MentionDirectory string `json:"mention_directory,omitempty"`
+
+	// 预览模式：只构建并签名消息，在工具结果中返回最终请求体而不实际发送
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// 内容过滤：发送前检查消息中的密钥、身份证号、手机号、邮箱和自定义禁用内容
+	ContentFilter ContentFilterConfig `json:"content_filter"`
+
+	// 摘要模式：窗口内通过send_text_message发送到Webhook的文本合并为一条消息
+	Digest DigestConfig `json:"digest"`
+
+	// 命名的群机器人目标和目标组，供broadcast_message向多个群发送同一条消息
+	Targets      []WebhookTarget     `json:"targets,omitempty"`
+	TargetGroups map[string][]string `json:"target_groups,omitempty"` // 组名 -> 目标名称列表，webhook表示默认Webhook
+
+	// 广播时同时发送的目标数上限，默认4
+	BroadcastConcurrency int `json:"broadcast_concurrency,omitempty"`
+}
+
+// DefaultTargetName 广播目标中表示默认Webhook(feishu.webhook_url)的名称
+const DefaultTargetName = "webhook"
+
+// WebhookTarget 命名的群机器人目标，每个目标使用各自的Webhook地址和安全设置
+type WebhookTarget struct {
+	Name             string   `json:"name"`
+	WebhookURL       string   `json:"webhook_url"`
+	Secret           string   `json:"secret,omitempty"`
+	Keywords         []string `json:"keywords,omitempty"`
+	SecurityType     string   `json:"security_type,omitempty"`
+	SecurityPolicies []string `json:"security_policies,omitempty"`
+	KeywordPolicy    string   `json:"keyword_policy,omitempty"`
+
+	Digest DigestConfig `json:"digest,omitempty"` // 目标自己的摘要模式，不沿用feishu.digest
+}
+
+// FeishuConfig 返回目标使用的飞书配置，内容过滤和预览模式沿用parent，不启用应用机器人
+func (t WebhookTarget) FeishuConfig(parent FeishuConfig) FeishuConfig {
+	return FeishuConfig{
+		WebhookURL:       t.WebhookURL,
+		Secret:           t.Secret,
+		Keywords:         t.Keywords,
+		SecurityType:     t.SecurityType,
+		SecurityPolicies: t.SecurityPolicies,
+		KeywordPolicy:    t.KeywordPolicy,
+		Digest:           t.Digest,
+		DryRun:           parent.DryRun,
+		ContentFilter:    parent.ContentFilter,
+	}
+}
+
+// DigestConfig 摘要模式配置
+type DigestConfig struct {
+	WindowSeconds int    `json:"window_seconds,omitempty"` // 合并窗口（秒），0表示不启用
+	Format        string `json:"format,omitempty"`         // post(默认) 或 card
+	MaxMessages   int    `json:"max_messages,omitempty"`   // 单条摘要最多合并的消息数，达到后立即发送，默认50
+}
+
+// ContentFilterConfig 内容过滤配置，内置检测项的值为处理动作，为空时不检查
+type ContentFilterConfig struct {
+	Credentials string `json:"credentials,omitempty"` // 常见密钥格式：云厂商AccessKey、GitHub/Slack令牌、私钥、JWT、飞书Webhook地址等
+	IDCard      string `json:"id_card,omitempty"`     // 18位居民身份证号（校验位正确）
+	Phone       string `json:"phone,omitempty"`       // 中国大陆手机号
+	Email       string `json:"email,omitempty"`       // 邮箱地址
+
+	// 自定义正则规则，按顺序在内置检测项之后执行
+	Rules []ContentFilterRule `json:"rules,omitempty"`
+}
+
+// ContentFilterRule 自定义内容过滤规则
+type ContentFilterRule struct {
+	Name    string `json:"name"`    // 规则名称，出现在错误信息和日志中
+	Pattern string `json:"pattern"` // Go正则表达式(RE2)
+	Action  string `json:"action"`  // block, redact, warn
 }
 
 // MessageType 消息类型
@@ -26,8 +118,91 @@ const (
 	SecurityTypeNone      SecurityType = "none"
 	SecurityTypeSignature SecurityType = "signature"
 	SecurityTypeKeyword   SecurityType = "keyword"
+	// SecurityTypeIPAllowlist IP白名单由飞书服务端校验请求来源，客户端无需处理，
+	// 启用时需确保服务器的出口IP已加入机器人的IP白名单
+	SecurityTypeIPAllowlist SecurityType = "ip_allowlist"
+)
+
+// EnabledSecurityPolicies 返回启用的安全策略列表
+//
+// 优先使用security_policies，否则解析security_type（支持"signature,keyword"形式），
+// none和空值不会出现在结果中。
+func (c FeishuConfig) EnabledSecurityPolicies() []SecurityType {
+	names := c.SecurityPolicies
+	if len(names) == 0 {
+		names = strings.Split(c.SecurityType, ",")
+	}
+
+	var policies []SecurityType
+	seen := make(map[SecurityType]bool)
+	for _, name := range names {
+		policy := SecurityType(strings.TrimSpace(name))
+		if policy == "" || policy == SecurityTypeNone || seen[policy] {
+			continue
+		}
+		seen[policy] = true
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// KeywordPolicy 关键词缺失时的处理策略
+type KeywordPolicy string
+
+const (
+	KeywordPolicyReject  KeywordPolicy = "reject"  // 拒绝发送
+	KeywordPolicyPrepend KeywordPolicy = "prepend" // 在开头注入第一个关键词
+	KeywordPolicyAppend  KeywordPolicy = "append"  // 在末尾注入第一个关键词
+)
+
+// FilterAction 内容过滤命中后的处理动作
+type FilterAction string
+
+const (
+	FilterActionBlock  FilterAction = "block"  // 拒绝发送
+	FilterActionRedact FilterAction = "redact" // 替换为脱敏占位符后发送
+	FilterActionWarn   FilterAction = "warn"   // 记录警告日志后照常发送
 )
 
+// DigestFormat 摘要消息的格式
+type DigestFormat string
+
+const (
+	DigestFormatPost DigestFormat = "post" // 富文本消息，每条一行
+	DigestFormatCard DigestFormat = "card" // 卡片消息，每条一个文本块
+)
+
+// ReceiveIDType 消息接收者ID类型
+type ReceiveIDType string
+
+const (
+	ReceiveIDTypeEmail   ReceiveIDType = "email"
+	ReceiveIDTypeOpenID  ReceiveIDType = "open_id"
+	ReceiveIDTypeUserID  ReceiveIDType = "user_id"
+	ReceiveIDTypeUnionID ReceiveIDType = "union_id"
+)
+
+// Locale 消息语言，富文本和卡片可以同时提供多种语言，飞书按读者客户端的语言展示
+type Locale string
+
+const (
+	LocaleZhCN Locale = "zh_cn"
+	LocaleEnUS Locale = "en_us"
+	LocaleJaJP Locale = "ja_jp"
+)
+
+// DefaultLocale 未指定语言时使用的语言
+const DefaultLocale = LocaleZhCN
+
+// SupportedLocales 支持的语言
+var SupportedLocales = []Locale{LocaleZhCN, LocaleEnUS, LocaleJaJP}
+
+// PostLocale 一种语言的富文本标题和内容
+type PostLocale struct {
+	Title   string      `json:"title,omitempty"`
+	Content interface{} `json:"content"` // 段落二维数组，每个段落是一行元素
+}
+
 // TextMessage 文本消息
 type TextMessage struct {
 	Text string `json:"text"`
@@ -46,8 +221,11 @@ type ImageMessage struct {
 // InteractiveMessage 消息卡片
 type InteractiveMessage struct {
 	Config   interface{}   `json:"config,omitempty"`
-	Elements []interface{} `json:"elements"`
+	Elements []interface{} `json:"elements,omitempty"`
 	Header   interface{}   `json:"header,omitempty"`
+
+	// 按语言提供的卡片内容，如 {"zh_cn": [...], "en_us": [...]}，配置后飞书按读者语言展示，代替elements
+	I18nElements map[string]interface{} `json:"i18n_elements,omitempty"`
 }
 
 // ShareChatMessage 群名片
@@ -70,6 +248,30 @@ type FeishuWebhookResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
+// DirectMessageRequest 应用机器人im/v1/messages接口的请求体，content为消息内容的JSON字符串
+type DirectMessageRequest struct {
+	ReceiveID string `json:"receive_id"`
+	MsgType   string `json:"msg_type"`
+	Content   string `json:"content"`
+}
+
+// ChatInfo 机器人所在群聊信息
+type ChatInfo struct {
+	ChatID      string `json:"chat_id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	OwnerID     string `json:"owner_id,omitempty"`
+	External    bool   `json:"external,omitempty"`
+	ChatStatus  string `json:"chat_status,omitempty"`
+}
+
+// ChatList 群聊分页列表
+type ChatList struct {
+	Items     []ChatInfo `json:"items"`
+	HasMore   bool       `json:"has_more"`
+	PageToken string     `json:"page_token,omitempty"`
+}
+
 // MCPRequest MCP请求结构
 type MCPRequest struct {
 	JSONRPC string      `json:"jsonrpc"`
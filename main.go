@@ -7,37 +7,60 @@
 // - Interactive message cards
 // - Share chat messages
 //
-// It supports three security modes:
+// It supports composable security policies, which may be combined:
 // - None: No security validation
 // - Signature: HMAC-SHA256 signature verification
 // - Keyword: Message content must contain specified keywords
+// - IP allowlist: Enforced by Feishu on the server's egress IP
 //
-// The server can be configured via environment variables or JSON configuration files,
-// with environment variables taking precedence.
+// The server is configured in layers: defaults < configuration file (JSON, YAML
+// or TOML) < environment variables < command-line flags.
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"mcp-feishu/internal/acl"
+	"mcp-feishu/internal/audit"
 	"mcp-feishu/internal/config"
 	"mcp-feishu/internal/feishu"
+	"mcp-feishu/internal/instrument"
 	"mcp-feishu/internal/mcp"
+	"mcp-feishu/internal/quota"
+	"mcp-feishu/internal/tracing"
+	"mcp-feishu/internal/types"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func main() {
+	// 子命令（send、validate-config、doctor）使用独立的参数集合
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+
 	// 解析命令行参数
+	common := registerCommonFlags(flag.CommandLine)
 	var (
-		configPath = flag.String("config", "", "配置文件路径（作为环境变量的备用选项）")
-		useEnv     = flag.Bool("env", true, "强制仅使用环境变量（忽略配置文件）")
-		debug      = flag.Bool("debug", false, "启用调试日志")
-		version    = flag.Bool("version", false, "显示版本信息")
+		watch       = flag.Bool("watch", true, "监听配置文件变化并自动重新加载")
+		printConfig = flag.Bool("print-config", false, "输出生效的配置及每个字段的来源后退出")
+		version     = flag.Bool("version", false, "显示版本信息")
 	)
+	flag.Usage = usage
 	flag.Parse()
 
 	// 显示版本信息
@@ -47,66 +70,129 @@ func main() {
 		return
 	}
 
-	// 配置日志级别
-	if *debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	} else {
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	}
-
-	// 配置日志输出格式
-	log.Logger = log.Output(zerolog.ConsoleWriter{
-		Out:        os.Stderr,
-		TimeFormat: "15:04:05",
-	})
+	setupLogging(*common.debug)
 
 	log.Info().Msg("启动MCP飞书服务器")
 
-	// 加载配置 - 优先从环境变量读取
-	var cfg *config.Config
-
-	// 首先尝试从环境变量加载配置
-	cfg = config.LoadFromEnv()
+	opts, err := common.loadOptions()
+	if err != nil {
+		log.Fatal().Err(err).Msg("加载env文件失败")
+	}
+	cfg, provenance, err := config.Load(opts)
+	if err != nil {
+		log.Fatal().Err(err).Msg("加载配置失败")
+	}
 
-	// 如果环境变量配置不完整且提供了配置文件，则从配置文件补充
-	if cfg.Feishu.WebhookURL == "" && (*configPath != "" || !*useEnv) {
-		log.Info().Str("config_path", *configPath).Msg("环境变量配置不完整，尝试从配置文件加载")
-		fileCfg, err := config.LoadConfig(*configPath)
-		if err != nil {
-			log.Fatal().Err(err).Msg("加载配置文件失败")
+	if *printConfig {
+		if err := config.WriteEffectiveConfig(os.Stdout, cfg, provenance); err != nil {
+			log.Fatal().Err(err).Msg("输出配置失败")
 		}
-
-		// 用配置文件补充环境变量中缺失的配置
-		cfg = config.MergeConfig(cfg, fileCfg)
-		log.Info().Msg("已合并环境变量和配置文件设置")
-	} else {
-		log.Info().Msg("从环境变量加载配置")
+		return
 	}
-
-	log.Info().
-		Str("webhook_url", cfg.Feishu.WebhookURL).
-		Str("security_type", cfg.Feishu.SecurityType).
-		Msg("配置加载成功")
+	logConfiguration(cfg)
 
 	// 创建飞书客户端
 	feishuClient := feishu.NewClient(cfg.Feishu)
+	feishuClient.SetHooks(instrument.Hooks())
 	log.Info().Msg("飞书客户端创建成功")
 
 	// 创建MCP服务器
 	mcpServer := mcp.NewServer(feishuClient)
 	log.Info().Msg("MCP服务器创建成功")
 
-	// 设置信号处理
+	// 加载访问控制策略，重新加载配置时一并重新读取
+	if cfg.Server.PolicyFile != "" {
+		policy, err := acl.Load(cfg.Server.PolicyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("加载访问控制策略失败")
+		}
+		mcpServer.SetPolicy(policy)
+		log.Info().Str("file", cfg.Server.PolicyFile).Msg("已开启访问控制")
+	}
+
+	// 配额计数在重新加载配置时保留，只更新限制
+	limiter := quota.New(quotaConfig(cfg.Quota))
+	mcpServer.SetQuota(limiter)
+
+	// 启动指标端点，监听地址只在启动时读取，修改后需要重启；退出时由mcpServer.Shutdown关闭
+	if cfg.Server.MetricsAddr != "" {
+		go func() {
+			if err := mcpServer.ServeMetrics(cfg.Server.MetricsAddr); err != nil {
+				log.Error().Err(err).Msg("指标端点运行失败")
+			}
+		}()
+		log.Info().Str("addr", cfg.Server.MetricsAddr).Msg("已开启指标端点 /metrics")
+	}
+
+	// 启动链路追踪，与指标端点一样只在启动时读取配置
+	if cfg.Tracing.Exporter != "" {
+		exporter, err := newTraceExporter(cfg.Tracing)
+		if err != nil {
+			log.Fatal().Err(err).Msg("启动链路追踪失败")
+		}
+		provider := tracing.Setup(exporter, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := provider.Shutdown(ctx); err != nil {
+				log.Warn().Err(err).Msg("导出剩余的追踪数据失败")
+			}
+		}()
+		log.Info().Str("exporter", cfg.Tracing.Exporter).Float64("sample_ratio", cfg.Tracing.SampleRatio).Msg("已开启链路追踪")
+	}
+
+	// 打开审计日志，重新加载配置会替换飞书客户端，审计日志只在启动时打开一次以保证哈希链连续
+	if cfg.Audit.File != "" {
+		auditLog, err := audit.Open(cfg.Audit.File, cfg.Audit.MaxSizeMB, cfg.Audit.MaxBackups)
+		if err != nil {
+			log.Fatal().Err(err).Msg("打开审计日志失败")
+		}
+		audit.SetLogger(auditLog)
+		defer auditLog.Close()
+		log.Info().Str("file", cfg.Audit.File).Msg("已开启审计日志")
+	}
+
+	// 设置信号处理，SIGHUP用于重新加载配置
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+
+	// 监听配置文件变化
+	if opts.ConfigPath != "" && *watch {
+		watcher := config.NewFileWatcher(opts.ConfigPath, config.DefaultWatchInterval)
+		watcher.Start(func() {
+			reloadConfiguration(mcpServer, limiter, opts, "配置文件已修改")
+		})
+		defer watcher.Stop()
+		log.Info().Str("config_path", opts.ConfigPath).Msg("已开启配置文件监听")
+	}
 
-	// 启动服务器
 	go func() {
-		if err := mcpServer.Run(); err != nil {
-			log.Fatal().Err(err).Msg("MCP服务器运行失败")
+		for range reloadChan {
+			reloadConfiguration(mcpServer, limiter, opts, "收到SIGHUP信号")
 		}
 	}()
 
+	// 启动服务器，传输方式、监听地址和认证配置只在启动时读取
+	if cfg.Server.Transport == config.TransportHTTP {
+		httpOptions, err := newHTTPOptions(cfg.Server)
+		if err != nil {
+			log.Fatal().Err(err).Msg("配置HTTP传输失败")
+		}
+		go func() {
+			if err := mcpServer.RunHTTP(httpOptions); err != nil {
+				log.Fatal().Err(err).Msg("MCP服务器运行失败")
+			}
+		}()
+	} else {
+		go func() {
+			if err := mcpServer.Run(os.Stdin, os.Stdout); err != nil {
+				log.Fatal().Err(err).Msg("MCP服务器运行失败")
+			}
+		}()
+	}
+
 	log.Info().Msg("MCP飞书服务器启动成功，等待请求...")
 
 	// 等待退出信号
@@ -114,6 +200,217 @@ func main() {
 	log.Info().Str("signal", sig.String()).Msg("收到退出信号")
 
 	// 优雅关闭
+	signal.Stop(reloadChan)
 	mcpServer.Shutdown()
 	log.Info().Msg("MCP飞书服务器已关闭")
 }
+
+// newTraceExporter 按配置创建链路追踪导出器
+func newTraceExporter(cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case config.TraceExporterOTLP:
+		return tracing.NewOTLPExporter(context.Background(), cfg.Endpoint)
+	case config.TraceExporterFile:
+		return tracing.NewFileExporter(cfg.File)
+	default:
+		return nil, fmt.Errorf("不支持的导出方式: %s", cfg.Exporter)
+	}
+}
+
+// quotaConfig 将配置文件中的配额配置转换为quota.Config
+func quotaConfig(cfg config.QuotaConfig) quota.Config {
+	return quota.Config{
+		PerMinute:        cfg.PerMinute,
+		PerHour:          cfg.PerHour,
+		PerDay:           cfg.PerDay,
+		BreakerThreshold: cfg.BreakerThreshold,
+		BreakerCooldown:  time.Duration(cfg.BreakerCooldownSeconds) * time.Second,
+	}
+}
+
+// newHTTPOptions 按配置创建HTTP传输选项，加载服务器证书和校验客户端证书的CA
+func newHTTPOptions(cfg config.ServerConfig) (mcp.HTTPOptions, error) {
+	opts := mcp.HTTPOptions{
+		Addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+	}
+	for _, key := range cfg.APIKeys {
+		opts.APIKeys = append(opts.APIKeys, mcp.APIKey{Name: key.Name, Key: key.Key})
+	}
+
+	if cfg.TLS.CertFile == "" {
+		return opts, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return opts, fmt.Errorf("加载服务器证书失败: %w", err)
+	}
+	opts.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLS.ClientCAFile != "" {
+		data, err := os.ReadFile(cfg.TLS.ClientCAFile)
+		if err != nil {
+			return opts, fmt.Errorf("读取客户端CA失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return opts, fmt.Errorf("客户端CA文件%s中没有有效的PEM证书", cfg.TLS.ClientCAFile)
+		}
+		opts.TLSConfig.ClientCAs = pool
+		opts.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return opts, nil
+}
+
+// setupLogging 配置日志级别和输出格式，日志输出到标准错误以免干扰MCP协议
+func setupLogging(debug bool) {
+	if debug {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	} else {
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+
+	log.Logger = log.Output(zerolog.ConsoleWriter{
+		Out:        os.Stderr,
+		TimeFormat: "15:04:05",
+	})
+}
+
+// configFlag 可覆盖配置字段的命令行参数
+type configFlag struct {
+	path  string // 配置字段路径
+	usage string
+}
+
+// configFlags 命令行参数名到配置字段的映射，命令行参数的优先级最高
+var configFlags = map[string]configFlag{
+	"webhook-url":    {path: "feishu.webhook_url", usage: "飞书Webhook URL"},
+	"security-type":  {path: "feishu.security_type", usage: "安全类型，可用逗号组合，如 signature,keyword"},
+	"keyword-policy": {path: "feishu.keyword_policy", usage: "缺少关键词时的策略: reject, prepend, append"},
+	"transport":      {path: "server.transport", usage: "传输方式: stdio, http"},
+	"host":           {path: "server.host", usage: "服务器主机"},
+	"port":           {path: "server.port", usage: "服务器端口"},
+	"metrics-addr":   {path: "server.metrics_addr", usage: "Prometheus指标端点监听地址，如 127.0.0.1:9464"},
+	"policy-file":    {path: "server.policy_file", usage: "访问控制策略文件路径，限制客户端可调用的工具和目标"},
+	"trace-exporter": {path: "tracing.exporter", usage: "链路追踪导出方式: otlp, file"},
+	"trace-file":     {path: "tracing.file", usage: "链路追踪写入的文件路径（file导出方式）"},
+	"audit-file":     {path: "audit.file", usage: "审计日志文件路径，记录每条发出的消息"},
+}
+
+// commonFlags 服务器模式和子命令共用的配置相关参数
+type commonFlags struct {
+	fs         *flag.FlagSet
+	configPath *string
+	envFile    *string
+	useEnv     *bool
+	debug      *bool
+}
+
+// registerCommonFlags 在fs上注册配置相关参数
+func registerCommonFlags(fs *flag.FlagSet) *commonFlags {
+	cf := &commonFlags{
+		fs:         fs,
+		configPath: fs.String("config", "", "配置文件路径，支持JSON/YAML/TOML，优先级低于环境变量"),
+		envFile:    fs.String("env-file", "", ".env文件路径，其中的变量不会覆盖已有环境变量"),
+		useEnv:     fs.Bool("env", false, "仅使用环境变量和命令行参数（忽略配置文件）"),
+		debug:      fs.Bool("debug", false, "启用调试日志"),
+	}
+	for name, field := range configFlags {
+		fs.String(name, "", field.usage)
+	}
+	return cf
+}
+
+// loadOptions 加载.env文件并构造配置加载选项，只有显式设置的参数才会覆盖配置
+func (cf *commonFlags) loadOptions() (config.LoadOptions, error) {
+	opts := config.LoadOptions{
+		Flags:     make(map[string]string),
+		FlagNames: make(map[string]string),
+	}
+
+	// 加载.env文件到环境变量
+	if *cf.envFile != "" {
+		if err := config.LoadEnvFile(*cf.envFile); err != nil {
+			return opts, err
+		}
+	}
+
+	if !*cf.useEnv {
+		opts.ConfigPath = *cf.configPath
+	}
+
+	cf.fs.Visit(func(f *flag.Flag) {
+		if field, ok := configFlags[f.Name]; ok {
+			opts.Flags[field.path] = f.Value.String()
+			opts.FlagNames[field.path] = f.Name
+		}
+	})
+
+	return opts, nil
+}
+
+// load 按命令行参数加载配置
+func (cf *commonFlags) load() (*config.Config, config.Provenance, error) {
+	opts, err := cf.loadOptions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载env文件失败: %w", err)
+	}
+	return config.Load(opts)
+}
+
+// reloadMu 保证同一时间只有一次重新加载
+var reloadMu sync.Mutex
+
+// reloadConfiguration 重新加载并验证配置，成功后原子替换服务器使用的飞书客户端。
+// 新配置无效时保留当前配置继续运行。
+func reloadConfiguration(server *mcp.Server, limiter *quota.Limiter, opts config.LoadOptions, reason string) {
+	// 文件监听和SIGHUP可能同时触发，串行执行重新加载
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	log.Info().Str("reason", reason).Msg("重新加载配置")
+
+	cfg, _, err := config.Load(opts)
+	if err != nil {
+		log.Error().Err(err).Msg("重新加载配置失败，继续使用当前配置")
+		return
+	}
+
+	logConfiguration(cfg)
+	client := feishu.NewClient(cfg.Feishu)
+	client.SetHooks(instrument.Hooks())
+	server.UpdateFeishuClient(client)
+	limiter.Update(quotaConfig(cfg.Quota))
+
+	// 策略文件无效时保留当前策略，避免因为一次编辑错误放开或关闭全部访问
+	var policy *acl.Policy
+	if cfg.Server.PolicyFile != "" {
+		policy, err = acl.Load(cfg.Server.PolicyFile)
+		if err != nil {
+			log.Error().Err(err).Msg("重新加载访问控制策略失败，继续使用当前策略")
+			return
+		}
+	}
+	server.SetPolicy(policy)
+}
+
+// logConfiguration 输出脱敏后的配置摘要
+func logConfiguration(cfg *config.Config) {
+	log.Info().
+		Str("webhook_url", config.RedactWebhookURL(cfg.Feishu.WebhookURL)).
+		Bool("secret_configured", cfg.Feishu.Secret != "").
+		Interface("security_policies", cfg.Feishu.EnabledSecurityPolicies()).
+		Msg("配置加载成功")
+
+	if cfg.Feishu.DryRun {
+		log.Warn().Msg("已开启预览模式(dry_run)，消息只会构建并返回请求体，不会实际发送")
+	}
+
+	for _, policy := range cfg.Feishu.EnabledSecurityPolicies() {
+		if policy == types.SecurityTypeIPAllowlist {
+			log.Info().Msg("已启用IP白名单策略，请确认服务器出口IP已加入飞书机器人的IP白名单")
+		}
+	}
+}
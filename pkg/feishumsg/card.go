@@ -0,0 +1,330 @@
+package feishumsg
+
+// CardElement 卡片中的组件
+type CardElement interface {
+	cardElement()
+}
+
+// 卡片标题颜色
+const (
+	TemplateBlue      = "blue"
+	TemplateWathet    = "wathet"
+	TemplateTurquoise = "turquoise"
+	TemplateGreen     = "green"
+	TemplateYellow    = "yellow"
+	TemplateOrange    = "orange"
+	TemplateRed       = "red"
+	TemplateCarmine   = "carmine"
+	TemplateViolet    = "violet"
+	TemplatePurple    = "purple"
+	TemplateIndigo    = "indigo"
+	TemplateGrey      = "grey"
+)
+
+// Card 交互式消息卡片
+type Card struct {
+	Config   *CardConfig   `json:"config,omitempty"`
+	Header   *Header       `json:"header,omitempty"`
+	Elements []CardElement `json:"elements,omitempty"`
+
+	// 按语言提供的卡片内容，配置后飞书按读者语言展示，代替Elements
+	I18nElements map[Locale][]CardElement `json:"i18n_elements,omitempty"`
+}
+
+// CardConfig 卡片全局配置
+type CardConfig struct {
+	WideScreenMode bool  `json:"wide_screen_mode,omitempty"`
+	EnableForward  *bool `json:"enable_forward,omitempty"` // 为nil时使用飞书默认值（允许转发）
+	UpdateMulti    bool  `json:"update_multi,omitempty"`
+}
+
+// NewCard 创建卡片
+func NewCard() *Card {
+	return &Card{}
+}
+
+// MsgType 返回interactive
+func (c *Card) MsgType() string { return MsgTypeInteractive }
+
+// WithHeader 设置标题栏
+func (c *Card) WithHeader(header *Header) *Card {
+	c.Header = header
+	return c
+}
+
+// WithConfig 设置全局配置
+func (c *Card) WithConfig(config *CardConfig) *Card {
+	c.Config = config
+	return c
+}
+
+// Add 追加组件
+func (c *Card) Add(elements ...CardElement) *Card {
+	c.Elements = append(c.Elements, elements...)
+	return c
+}
+
+// AddIn 为指定语言追加组件
+func (c *Card) AddIn(locale Locale, elements ...CardElement) *Card {
+	if c.I18nElements == nil {
+		c.I18nElements = make(map[Locale][]CardElement)
+	}
+	c.I18nElements[locale] = append(c.I18nElements[locale], elements...)
+	return c
+}
+
+// TextObject 卡片文本对象，Tag为plain_text或lark_md
+type TextObject struct {
+	Tag     string            `json:"tag"`
+	Content string            `json:"content"`
+	I18n    map[Locale]string `json:"i18n,omitempty"` // 按语言提供的文本，飞书按读者语言展示
+}
+
+// PlainText 创建纯文本对象
+func PlainText(content string) *TextObject {
+	return &TextObject{Tag: "plain_text", Content: content}
+}
+
+// Markdown 创建lark_md文本对象，支持加粗、链接、<at id=all></at>等Markdown语法
+func Markdown(content string) *TextObject {
+	return &TextObject{Tag: "lark_md", Content: content}
+}
+
+// WithI18n 设置指定语言的文本
+func (t *TextObject) WithI18n(locale Locale, content string) *TextObject {
+	if t.I18n == nil {
+		t.I18n = make(map[Locale]string)
+	}
+	t.I18n[locale] = content
+	return t
+}
+
+// 文本对象可以直接用在备注中
+func (t *TextObject) cardElement() {}
+
+// Header 卡片标题栏
+type Header struct {
+	Title    *TextObject `json:"title"`
+	Subtitle *TextObject `json:"subtitle,omitempty"`
+	Template string      `json:"template,omitempty"`
+}
+
+// NewHeader 创建标题栏
+func NewHeader(title string) *Header {
+	return &Header{Title: PlainText(title)}
+}
+
+// WithSubtitle 设置副标题
+func (h *Header) WithSubtitle(subtitle string) *Header {
+	h.Subtitle = PlainText(subtitle)
+	return h
+}
+
+// WithTemplate 设置标题颜色，如TemplateBlue
+func (h *Header) WithTemplate(template string) *Header {
+	h.Template = template
+	return h
+}
+
+// WithTitleI18n 设置指定语言的标题
+func (h *Header) WithTitleI18n(locale Locale, title string) *Header {
+	h.Title.WithI18n(locale, title)
+	return h
+}
+
+// DivElement 文本组件，可以包含一段文本和若干字段
+type DivElement struct {
+	Text   *TextObject `json:"text,omitempty"`
+	Fields []*Field    `json:"fields,omitempty"`
+	Extra  CardElement `json:"extra,omitempty"` // 显示在文本右侧的附加组件，如按钮
+}
+
+// Field Div中的字段，IsShort为true时两个字段并排显示
+type Field struct {
+	IsShort bool        `json:"is_short"`
+	Text    *TextObject `json:"text"`
+}
+
+// Div 创建文本组件
+func Div(text *TextObject) *DivElement {
+	return &DivElement{Text: text}
+}
+
+// AddField 追加字段
+func (e *DivElement) AddField(isShort bool, text *TextObject) *DivElement {
+	e.Fields = append(e.Fields, &Field{IsShort: isShort, Text: text})
+	return e
+}
+
+// WithExtra 设置附加组件
+func (e *DivElement) WithExtra(extra CardElement) *DivElement {
+	e.Extra = extra
+	return e
+}
+
+func (e *DivElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "div", ...}
+func (e *DivElement) MarshalJSON() ([]byte, error) {
+	type plain DivElement
+	return marshalTagged("div", (*plain)(e))
+}
+
+// MarkdownElement Markdown组件
+type MarkdownElement struct {
+	Content string `json:"content"`
+}
+
+// MarkdownBlock 创建Markdown组件
+func MarkdownBlock(content string) *MarkdownElement {
+	return &MarkdownElement{Content: content}
+}
+
+func (e *MarkdownElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "markdown", ...}
+func (e *MarkdownElement) MarshalJSON() ([]byte, error) {
+	type plain MarkdownElement
+	return marshalTagged("markdown", (*plain)(e))
+}
+
+// ActionElement 交互组件，包含一组按钮等可交互组件
+type ActionElement struct {
+	Actions []CardElement `json:"actions"`
+	Layout  string        `json:"layout,omitempty"` // bisected、trisection、flow
+}
+
+// Action 创建交互组件
+func Action(actions ...CardElement) *ActionElement {
+	return &ActionElement{Actions: actions}
+}
+
+// WithLayout 设置排列方式
+func (e *ActionElement) WithLayout(layout string) *ActionElement {
+	e.Layout = layout
+	return e
+}
+
+func (e *ActionElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "action", ...}
+func (e *ActionElement) MarshalJSON() ([]byte, error) {
+	type plain ActionElement
+	return marshalTagged("action", (*plain)(e))
+}
+
+// 按钮类型
+const (
+	ButtonDefault = "default"
+	ButtonPrimary = "primary"
+	ButtonDanger  = "danger"
+)
+
+// ButtonElement 按钮，设置URL时点击跳转链接，设置Value时点击回传数据
+type ButtonElement struct {
+	Text  *TextObject            `json:"text"`
+	Type  string                 `json:"type,omitempty"`
+	URL   string                 `json:"url,omitempty"`
+	Value map[string]interface{} `json:"value,omitempty"`
+}
+
+// Button 创建按钮
+func Button(text string) *ButtonElement {
+	return &ButtonElement{Text: PlainText(text), Type: ButtonDefault}
+}
+
+// WithType 设置按钮类型，如ButtonPrimary
+func (e *ButtonElement) WithType(buttonType string) *ButtonElement {
+	e.Type = buttonType
+	return e
+}
+
+// WithURL 设置跳转链接
+func (e *ButtonElement) WithURL(url string) *ButtonElement {
+	e.URL = url
+	return e
+}
+
+// WithValue 设置点击时回传的数据
+func (e *ButtonElement) WithValue(value map[string]interface{}) *ButtonElement {
+	e.Value = value
+	return e
+}
+
+func (e *ButtonElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "button", ...}
+func (e *ButtonElement) MarshalJSON() ([]byte, error) {
+	type plain ButtonElement
+	return marshalTagged("button", (*plain)(e))
+}
+
+// ColumnSetElement 多列布局
+type ColumnSetElement struct {
+	FlexMode        string    `json:"flex_mode,omitempty"` // none、stretch、flow、bisect、trisect
+	BackgroundStyle string    `json:"background_style,omitempty"`
+	Columns         []*Column `json:"columns"`
+}
+
+// ColumnSet 创建多列布局
+func ColumnSet(columns ...*Column) *ColumnSetElement {
+	return &ColumnSetElement{FlexMode: "none", Columns: columns}
+}
+
+// WithFlexMode 设置窄屏时的自适应方式
+func (e *ColumnSetElement) WithFlexMode(mode string) *ColumnSetElement {
+	e.FlexMode = mode
+	return e
+}
+
+func (e *ColumnSetElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "column_set", ...}
+func (e *ColumnSetElement) MarshalJSON() ([]byte, error) {
+	type plain ColumnSetElement
+	return marshalTagged("column_set", (*plain)(e))
+}
+
+// Column 多列布局中的一列
+type Column struct {
+	Width         string        `json:"width,omitempty"` // auto或weighted
+	Weight        int           `json:"weight,omitempty"`
+	VerticalAlign string        `json:"vertical_align,omitempty"`
+	Elements      []CardElement `json:"elements"`
+}
+
+// NewColumn 创建一列
+func NewColumn(elements ...CardElement) *Column {
+	return &Column{Width: "weighted", Weight: 1, Elements: elements}
+}
+
+// WithWeight 设置列宽权重
+func (c *Column) WithWeight(weight int) *Column {
+	c.Width = "weighted"
+	c.Weight = weight
+	return c
+}
+
+// MarshalJSON 序列化为 {"tag": "column", ...}
+func (c *Column) MarshalJSON() ([]byte, error) {
+	type plain Column
+	return marshalTagged("column", (*plain)(c))
+}
+
+// NoteElement 备注组件，以较小的灰色字体显示文本
+type NoteElement struct {
+	Elements []CardElement `json:"elements"`
+}
+
+// Note 创建备注组件，元素通常为PlainText或Markdown文本对象
+func Note(elements ...CardElement) *NoteElement {
+	return &NoteElement{Elements: elements}
+}
+
+func (e *NoteElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "note", ...}
+func (e *NoteElement) MarshalJSON() ([]byte, error) {
+	type plain NoteElement
+	return marshalTagged("note", (*plain)(e))
+}
@@ -0,0 +1,149 @@
+// Package feishumsg 以类型化的方式构建飞书机器人消息，供需要直接发送飞书消息的Go服务使用。
+//
+// 各消息类型序列化为JSON后即为自定义机器人Webhook请求体中的content字段，
+// 通过NewRequest包装成完整的请求体，签名校验的机器人再调用Request.Sign：
+//
+//	post := feishumsg.NewPost().
+//		Title("发布公告").
+//		Paragraph(feishumsg.Text("v2.3.0 已上线，"), feishumsg.Link("查看说明", "https://example.com/release"))
+//	post.In(feishumsg.EnUS).
+//		Title("Release").
+//		Paragraph(feishumsg.Text("v2.3.0 is live"))
+//
+//	req := feishumsg.NewRequest(post)
+//	req.Sign(secret, time.Now())
+//	body, err := json.Marshal(req)
+package feishumsg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Locale 消息语言，富文本和卡片可以同时提供多种语言，飞书按读者客户端的语言展示
+type Locale string
+
+const (
+	ZhCN Locale = "zh_cn"
+	EnUS Locale = "en_us"
+	JaJP Locale = "ja_jp"
+)
+
+// DefaultLocale 未指定语言时使用的语言
+const DefaultLocale = ZhCN
+
+// 消息类型，与Webhook请求体中的msg_type一致
+const (
+	MsgTypeText        = "text"
+	MsgTypePost        = "post"
+	MsgTypeImage       = "image"
+	MsgTypeInteractive = "interactive"
+	MsgTypeShareChat   = "share_chat"
+)
+
+// Message 一条消息的内容，序列化后为Webhook请求体的content字段
+type Message interface {
+	MsgType() string
+}
+
+// Request 自定义机器人Webhook请求体
+type Request struct {
+	MsgType   string  `json:"msg_type"`
+	Content   Message `json:"content"`
+	Timestamp int64   `json:"timestamp,omitempty"`
+	Signature string  `json:"sign,omitempty"`
+}
+
+// NewRequest 将消息包装为Webhook请求体
+func NewRequest(msg Message) *Request {
+	return &Request{
+		MsgType: msg.MsgType(),
+		Content: msg,
+	}
+}
+
+// Sign 按签名校验的要求设置时间戳和签名，飞书要求时间戳在一小时内有效
+func (r *Request) Sign(secret string, now time.Time) *Request {
+	r.Timestamp = now.Unix()
+	r.Signature = Sign(secret, r.Timestamp)
+	return r
+}
+
+// Sign 按飞书自定义机器人的签名算法计算签名
+//
+// 以 timestamp + "\n" + secret 作为HMAC-SHA256的密钥对空内容计算摘要，再进行Base64编码。
+func Sign(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	h := hmac.New(sha256.New, []byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// TextMessage 纯文本消息，文本中可以直接书写 <at user_id="ou_xxx">名字</at> 提及用户
+type TextMessage struct {
+	Text string `json:"text"`
+}
+
+// NewTextMessage 创建纯文本消息
+func NewTextMessage(text string) *TextMessage {
+	return &TextMessage{Text: text}
+}
+
+// MsgType 返回text
+func (m *TextMessage) MsgType() string { return MsgTypeText }
+
+// ImageMessage 图片消息，ImageKey通过飞书上传图片接口获取
+type ImageMessage struct {
+	ImageKey string `json:"image_key"`
+}
+
+// NewImageMessage 创建图片消息
+func NewImageMessage(imageKey string) *ImageMessage {
+	return &ImageMessage{ImageKey: imageKey}
+}
+
+// MsgType 返回image
+func (m *ImageMessage) MsgType() string { return MsgTypeImage }
+
+// ShareChatMessage 群名片消息
+type ShareChatMessage struct {
+	ShareChatID string `json:"share_chat_id"`
+}
+
+// NewShareChatMessage 创建群名片消息
+func NewShareChatMessage(shareChatID string) *ShareChatMessage {
+	return &ShareChatMessage{ShareChatID: shareChatID}
+}
+
+// MsgType 返回share_chat
+func (m *ShareChatMessage) MsgType() string { return MsgTypeShareChat }
+
+// marshalTagged 序列化元素并在开头加上tag字段
+func marshalTagged(tag string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	tagJSON, err := json.Marshal(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(`{"tag":`), tagJSON...)
+	if len(data) > 2 {
+		out = append(out, ',')
+		out = append(out, data[1:]...)
+	} else {
+		out = append(out, '}')
+	}
+	return out, nil
+}
+
+// RawElement 未提供类型的元素，按原样序列化，可以用在富文本段落和卡片中，如 {"tag": "media", "file_key": "..."}
+type RawElement map[string]interface{}
+
+func (RawElement) postElement() {}
+func (RawElement) cardElement() {}
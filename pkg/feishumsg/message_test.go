@@ -0,0 +1,160 @@
+package feishumsg
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp int64
+		want      string
+	}{
+		{"demo", 1599360473, "l1N0gAcBjdwBvGm1xMjOF0XSyaLRpR7tuO5dHfhAYc8="},
+		{"SEC5f2a9c1e7b3d", 1700000000, "y+mP49zpMix+GhULQkUZSJJkLt4MTQMppeDPUgZb3CY="},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp); got != tt.want {
+			t.Errorf("Sign(%q, %d) = %q, 期望 %q", tt.secret, tt.timestamp, got, tt.want)
+		}
+	}
+}
+
+func TestRequestSign(t *testing.T) {
+	req := NewRequest(NewTextMessage("你好")).Sign("demo", time.Unix(1599360473, 0))
+
+	got, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("序列化失败: %v", err)
+	}
+	want := `{"msg_type":"text","content":{"text":"你好"},"timestamp":1599360473,"sign":"l1N0gAcBjdwBvGm1xMjOF0XSyaLRpR7tuO5dHfhAYc8="}`
+	if string(got) != want {
+		t.Errorf("请求体 = %s\n期望 %s", got, want)
+	}
+}
+
+func TestMarshalTagged(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		v    interface{}
+		want string
+	}{
+		{
+			name: "字段跟在tag之后",
+			tag:  "text",
+			v:    struct{ Text string }{"你好"},
+			want: `{"tag":"text","Text":"你好"}`,
+		},
+		{
+			name: "没有字段时只有tag",
+			tag:  "hr",
+			v:    struct{}{},
+			want: `{"tag":"hr"}`,
+		},
+		{
+			name: "tag中的特殊字符被转义",
+			tag:  `a"b`,
+			v:    struct{}{},
+			want: `{"tag":"a\"b"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := marshalTagged(tt.tag, tt.v)
+			if err != nil {
+				t.Fatalf("序列化失败: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("marshalTagged = %s, 期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalMessages(t *testing.T) {
+	post := NewPost().Title("发布公告").Paragraph(
+		Text("v2.3.0").Bold(), Link("说明", "https://example.com"), AtAll(),
+	).Paragraph(CodeBlock("GO", "x := 1"))
+	post.In(EnUS).Title("Release").Paragraph(Emotion("OK"), Hr(), RawElement{"tag": "media", "file_key": "file_1"})
+
+	card := NewCard().WithConfig(&CardConfig{WideScreenMode: true}).
+		WithHeader(NewHeader("告警").WithTemplate(TemplateRed)).
+		Add(
+			Div(Markdown("**CPU** 过高")).AddField(true, PlainText("主机: web-1")),
+			Action(Button("确认").WithType(ButtonPrimary).WithURL("https://example.com")),
+			Note(PlainText("自动发送")),
+			Hr(),
+		)
+	card.AddIn(EnUS, MarkdownBlock("CPU high"))
+
+	tests := []struct {
+		name string
+		msg  Message
+		want string
+	}{
+		{
+			name: "文本",
+			msg:  NewTextMessage("你好"),
+			want: `{"msg_type":"text","content":{"text":"你好"}}`,
+		},
+		{
+			name: "图片",
+			msg:  NewImageMessage("img_1"),
+			want: `{"msg_type":"image","content":{"image_key":"img_1"}}`,
+		},
+		{
+			name: "群名片",
+			msg:  NewShareChatMessage("oc_1"),
+			want: `{"msg_type":"share_chat","content":{"share_chat_id":"oc_1"}}`,
+		},
+		{
+			name: "多语言富文本",
+			msg:  post,
+			want: `{"msg_type":"post","content":{"post":{` +
+				`"en_us":{"title":"Release","content":[[{"tag":"emotion","emoji_type":"OK"},{"tag":"hr"},{"file_key":"file_1","tag":"media"}]]},` +
+				`"zh_cn":{"title":"发布公告","content":[` +
+				`[{"tag":"text","text":"v2.3.0","style":["bold"]},{"tag":"a","text":"说明","href":"https://example.com"},{"tag":"at","user_id":"all","user_name":"所有人"}],` +
+				`[{"tag":"code_block","language":"GO","text":"x := 1"}]]}}}}`,
+		},
+		{
+			name: "卡片",
+			msg:  card,
+			want: `{"msg_type":"interactive","content":{` +
+				`"config":{"wide_screen_mode":true},` +
+				`"header":{"title":{"tag":"plain_text","content":"告警"},"template":"red"},` +
+				`"elements":[` +
+				`{"tag":"div","text":{"tag":"lark_md","content":"**CPU** 过高"},"fields":[{"is_short":true,"text":{"tag":"plain_text","content":"主机: web-1"}}]},` +
+				`{"tag":"action","actions":[{"tag":"button","text":{"tag":"plain_text","content":"确认"},"type":"primary","url":"https://example.com"}]},` +
+				`{"tag":"note","elements":[{"tag":"plain_text","content":"自动发送"}]},` +
+				`{"tag":"hr"}],` +
+				`"i18n_elements":{"en_us":[{"tag":"markdown","content":"CPU high"}]}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(NewRequest(tt.msg))
+			if err != nil {
+				t.Fatalf("序列化失败: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("请求体 = %s\n期望 %s", got, tt.want)
+			}
+
+			// 反序列化后按结构比较，字段顺序以外的差异也能定位
+			var decoded, wantDecoded interface{}
+			if err := json.Unmarshal(got, &decoded); err != nil {
+				t.Fatalf("反序列化失败: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantDecoded); err != nil {
+				t.Fatalf("期望值不是合法JSON: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, wantDecoded) {
+				t.Errorf("反序列化结果 = %v, 期望 %v", decoded, wantDecoded)
+			}
+		})
+	}
+}
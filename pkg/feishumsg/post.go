@@ -0,0 +1,273 @@
+package feishumsg
+
+import "encoding/json"
+
+// PostElement 富文本段落中的元素
+type PostElement interface {
+	postElement()
+}
+
+// Paragraph 富文本中的一行，由若干元素组成
+type Paragraph []PostElement
+
+// PostLocale 一种语言的富文本标题和段落
+type PostLocale struct {
+	title      string
+	paragraphs []Paragraph
+}
+
+// Title 设置标题
+func (l *PostLocale) Title(title string) *PostLocale {
+	l.title = title
+	return l
+}
+
+// Paragraph 追加一行
+func (l *PostLocale) Paragraph(elements ...PostElement) *PostLocale {
+	l.paragraphs = append(l.paragraphs, Paragraph(elements))
+	return l
+}
+
+// MarshalJSON 序列化为 {"title": "...", "content": [[...]]}
+func (l *PostLocale) MarshalJSON() ([]byte, error) {
+	paragraphs := l.paragraphs
+	if paragraphs == nil {
+		paragraphs = []Paragraph{}
+	}
+	return json.Marshal(struct {
+		Title   string      `json:"title,omitempty"`
+		Content []Paragraph `json:"content"`
+	}{l.title, paragraphs})
+}
+
+// Post 富文本消息，按语言保存标题和段落
+type Post struct {
+	locales map[Locale]*PostLocale
+}
+
+// NewPost 创建富文本消息
+func NewPost() *Post {
+	return &Post{locales: make(map[Locale]*PostLocale)}
+}
+
+// MsgType 返回post
+func (p *Post) MsgType() string { return MsgTypePost }
+
+// In 返回指定语言的内容，不存在时创建
+func (p *Post) In(locale Locale) *PostLocale {
+	l, ok := p.locales[locale]
+	if !ok {
+		l = &PostLocale{}
+		p.locales[locale] = l
+	}
+	return l
+}
+
+// Title 设置默认语言(zh_cn)的标题
+func (p *Post) Title(title string) *Post {
+	p.In(DefaultLocale).Title(title)
+	return p
+}
+
+// Paragraph 为默认语言(zh_cn)追加一行
+func (p *Post) Paragraph(elements ...PostElement) *Post {
+	p.In(DefaultLocale).Paragraph(elements...)
+	return p
+}
+
+// Locales 返回按语言组织的内容，即Webhook请求体中post字段的值
+func (p *Post) Locales() map[Locale]*PostLocale {
+	return p.locales
+}
+
+// MarshalJSON 序列化为 {"post": {"zh_cn": {...}, "en_us": {...}}}
+func (p *Post) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"post": p.locales,
+	})
+}
+
+// 文本样式
+const (
+	StyleBold        = "bold"
+	StyleItalic      = "italic"
+	StyleUnderline   = "underline"
+	StyleLineThrough = "lineThrough"
+)
+
+// TextElement 文本元素
+type TextElement struct {
+	Text     string   `json:"text"`
+	UnEscape bool     `json:"un_escape,omitempty"` // 为true时解析文本中的HTML转义字符
+	Style    []string `json:"style,omitempty"`
+}
+
+// Text 创建文本元素
+func Text(text string) *TextElement {
+	return &TextElement{Text: text}
+}
+
+// Bold 加粗
+func (e *TextElement) Bold() *TextElement {
+	e.Style = append(e.Style, StyleBold)
+	return e
+}
+
+// Italic 斜体
+func (e *TextElement) Italic() *TextElement {
+	e.Style = append(e.Style, StyleItalic)
+	return e
+}
+
+// Underline 下划线
+func (e *TextElement) Underline() *TextElement {
+	e.Style = append(e.Style, StyleUnderline)
+	return e
+}
+
+// LineThrough 删除线
+func (e *TextElement) LineThrough() *TextElement {
+	e.Style = append(e.Style, StyleLineThrough)
+	return e
+}
+
+func (e *TextElement) postElement() {}
+
+// MarshalJSON 序列化为 {"tag": "text", ...}
+func (e *TextElement) MarshalJSON() ([]byte, error) {
+	type plain TextElement
+	return marshalTagged("text", (*plain)(e))
+}
+
+// LinkElement 超链接元素
+type LinkElement struct {
+	Text  string   `json:"text"`
+	Href  string   `json:"href"`
+	Style []string `json:"style,omitempty"`
+}
+
+// Link 创建超链接元素
+func Link(text, href string) *LinkElement {
+	return &LinkElement{Text: text, Href: href}
+}
+
+// Bold 加粗
+func (e *LinkElement) Bold() *LinkElement {
+	e.Style = append(e.Style, StyleBold)
+	return e
+}
+
+func (e *LinkElement) postElement() {}
+
+// MarshalJSON 序列化为 {"tag": "a", ...}
+func (e *LinkElement) MarshalJSON() ([]byte, error) {
+	type plain LinkElement
+	return marshalTagged("a", (*plain)(e))
+}
+
+// AtAllUserID 提及所有人时使用的user_id
+const AtAllUserID = "all"
+
+// AtElement @用户元素，UserID为open_id、user_id或all
+type AtElement struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name,omitempty"`
+}
+
+// At 创建@用户元素
+func At(userID, userName string) *AtElement {
+	return &AtElement{UserID: userID, UserName: userName}
+}
+
+// AtAll 创建@所有人元素
+func AtAll() *AtElement {
+	return &AtElement{UserID: AtAllUserID, UserName: "所有人"}
+}
+
+func (e *AtElement) postElement() {}
+
+// MarshalJSON 序列化为 {"tag": "at", ...}
+func (e *AtElement) MarshalJSON() ([]byte, error) {
+	type plain AtElement
+	return marshalTagged("at", (*plain)(e))
+}
+
+// ImgElement 图片元素，图片需要单独成行
+type ImgElement struct {
+	ImageKey string `json:"image_key"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+// Img 创建图片元素
+func Img(imageKey string) *ImgElement {
+	return &ImgElement{ImageKey: imageKey}
+}
+
+// Size 设置展示的宽高（像素）
+func (e *ImgElement) Size(width, height int) *ImgElement {
+	e.Width = width
+	e.Height = height
+	return e
+}
+
+func (e *ImgElement) postElement() {}
+
+// MarshalJSON 序列化为 {"tag": "img", ...}
+func (e *ImgElement) MarshalJSON() ([]byte, error) {
+	type plain ImgElement
+	return marshalTagged("img", (*plain)(e))
+}
+
+// CodeBlockElement 代码块元素，需要单独成行
+type CodeBlockElement struct {
+	Language string `json:"language,omitempty"` // 如 GO、PYTHON、SHELL，为空时不高亮
+	Text     string `json:"text"`
+}
+
+// CodeBlock 创建代码块元素
+func CodeBlock(language, code string) *CodeBlockElement {
+	return &CodeBlockElement{Language: language, Text: code}
+}
+
+func (e *CodeBlockElement) postElement() {}
+
+// MarshalJSON 序列化为 {"tag": "code_block", ...}
+func (e *CodeBlockElement) MarshalJSON() ([]byte, error) {
+	type plain CodeBlockElement
+	return marshalTagged("code_block", (*plain)(e))
+}
+
+// EmotionElement 表情元素
+type EmotionElement struct {
+	EmojiType string `json:"emoji_type"` // 如 SMILE、THUMBSUP、OK
+}
+
+// Emotion 创建表情元素
+func Emotion(emojiType string) *EmotionElement {
+	return &EmotionElement{EmojiType: emojiType}
+}
+
+func (e *EmotionElement) postElement() {}
+
+// MarshalJSON 序列化为 {"tag": "emotion", ...}
+func (e *EmotionElement) MarshalJSON() ([]byte, error) {
+	type plain EmotionElement
+	return marshalTagged("emotion", (*plain)(e))
+}
+
+// HrElement 分割线，可以用在富文本段落和卡片中
+type HrElement struct{}
+
+// Hr 创建分割线
+func Hr() *HrElement {
+	return &HrElement{}
+}
+
+func (e *HrElement) postElement() {}
+func (e *HrElement) cardElement() {}
+
+// MarshalJSON 序列化为 {"tag": "hr"}
+func (e *HrElement) MarshalJSON() ([]byte, error) {
+	return marshalTagged("hr", struct{}{})
+}